@@ -0,0 +1,17 @@
+package api
+
+import "github.com/laplasd/inforo/model"
+
+// AuditSink receives a record for every mutating call made through an
+// audited Core.
+type AuditSink interface {
+	Write(record *model.AuditRecord) error
+}
+
+// AuditLog is an AuditSink that keeps the records and can be queried.
+type AuditLog interface {
+	AuditSink
+	// Query returns the matching records in the order they were written
+	Query(query model.AuditQuery) ([]*model.AuditRecord, error)
+	Close() error
+}
@@ -0,0 +1,19 @@
+package api
+
+import "github.com/laplasd/inforo/model"
+
+// EventBus delivers status transitions and event entries of components,
+// tasks, plans, monitorings and checks, and controller calls, to subscribers.
+type EventBus interface {
+	// Publish never blocks: a subscriber whose buffer is full misses the event
+	Publish(event model.BusEvent)
+	Subscribe(filter model.EventFilter) Subscription
+}
+
+type Subscription interface {
+	Events() <-chan model.BusEvent
+	// Dropped returns how many events were missed because the buffer was full
+	Dropped() uint64
+	// Close unsubscribes and closes the Events channel
+	Close()
+}
@@ -4,7 +4,7 @@ import "github.com/laplasd/inforo/model"
 
 type ComponentRegistry interface {
 	StatusProvider
-	Register(comp model.Component) (*model.Component, error)
+	Register(comp *model.Component) (*model.Component, error)
 	Get(id string) (*model.Component, error)
 	Update(id string, comp *model.Component) error
 	Delete(id string) error
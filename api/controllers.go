@@ -1,5 +1,7 @@
 package api
 
+import "context"
+
 type ControllerRegistry interface {
 	Register(componentType string, controller Controller) error
 	Get(componentType string) (Controller, error)
@@ -15,3 +17,19 @@ type Controller interface {
 	ValideComponent(ComponentMeta map[string]string) error
 	CheckComponent(ComponentMeta map[string]string) error
 }
+
+// ContextController is implemented by controllers that can abort RunTask
+// when the caller's context is cancelled or its deadline expires.
+type ContextController interface {
+	Controller
+	RunTaskContext(ctx context.Context, TaskMeta map[string]string, ComponentMeta map[string]string) error
+}
+
+// SnapshotController is implemented by controllers that can capture the
+// state of a component before a task changes it and bring it back on
+// rollback. Plans snapshot every component of a task before running it.
+type SnapshotController interface {
+	Controller
+	Snapshot(ctx context.Context, ComponentMeta map[string]string) (map[string]string, error)
+	Restore(ctx context.Context, ComponentMeta map[string]string, State map[string]string) error
+}
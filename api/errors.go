@@ -0,0 +1,42 @@
+package api
+
+// PermanentError marks an error that must not be retried, whatever the
+// retry policy of the task or check says.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that it is never retried. Controllers use it for
+// failures that another attempt cannot fix, e.g. invalid metadata.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// ForbiddenError is returned by an authorized Core when the identity of the
+// caller may not perform the operation.
+type ForbiddenError struct {
+	Actor     string
+	Operation string
+	Entity    string
+	EntityID  string
+	Reason    string
+}
+
+func (e *ForbiddenError) Error() string {
+	target := e.Entity
+	if e.EntityID != "" {
+		target += " " + e.EntityID
+	}
+	return "forbidden: " + e.Actor + " may not " + e.Operation + " " + target + ": " + e.Reason
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+
+	"github.com/laplasd/inforo/model"
+)
+
+type ExecutionRegistry interface {
+	// Tracking methods
+	Reserve(id string, kind model.ExecutionKind, planID string, taskID string) error
+	Start(id string, kind model.ExecutionKind, planID string, taskID string) bool
+	Finish(id string, status model.Status, err error)
+	// Query methods
+	Get(id string) (*model.Execution, error)
+	List() ([]*model.Execution, error)
+	ListByTask(taskID string) ([]*model.Execution, error)
+	ListByPlan(planID string) ([]*model.Execution, error)
+	Wait(ctx context.Context, id string) (*model.Execution, error)
+}
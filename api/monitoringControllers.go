@@ -1,5 +1,7 @@
 package api
 
+import "context"
+
 type MonitoringControllerRegistry interface {
 	Register(monitorType string, controller MonitoringController) error
 	Get(componentType string) (MonitoringController, error)
@@ -14,3 +16,10 @@ type MonitoringController interface {
 	ValidateCheck(monitorMeta map[string]string) error
 	ValidateMonitoring(config map[string]string) error
 }
+
+// ContextMonitoringController is implemented by monitoring controllers that
+// can abort RunCheck when the caller's context is cancelled.
+type ContextMonitoringController interface {
+	MonitoringController
+	RunCheckContext(ctx context.Context, monitorMeta map[string]string) error
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/laplasd/inforo/model"
 )
 
@@ -9,13 +11,17 @@ type PlanRegistry interface {
 	// CRUD methods
 	Register(tasks []*model.Task) (*model.Plan, error)
 	Get(id string) (*model.Plan, error)
-	Update(id string, comp model.Plan) error
+	Update(id string, plan *model.Plan) error
 	Delete(id string) error
 	List() ([]*model.Plan, error)
+	SetMaxParallelism(planID string, graphID string, limit int) error
 	// Process methods
 	RunAsync(planID string, executionID string) (string, error)
 	Run(planID string, executionID string) (string, error)
+	RunContext(ctx context.Context, planID string, executionID string) (string, error)
 	Status(planID string) (model.Status, error)
 	Stop(planID string) error
 	Pause(planID string) error
+	Resume(planID string) (string, error)
+	Recover(policy model.RecoveryPolicy) ([]string, error)
 }
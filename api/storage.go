@@ -0,0 +1,10 @@
+package api
+
+// Storage persists the state of the registries. Values are JSON documents
+// grouped into collections, one collection per registry.
+type Storage interface {
+	Put(collection string, id string, value []byte) error
+	Delete(collection string, id string) error
+	List(collection string) (map[string][]byte, error)
+	Close() error
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/laplasd/inforo/model"
 )
 
@@ -15,9 +17,14 @@ type TaskRegistry interface {
 	// Process methods
 	ForkAsync(TaskID string, executionID string) (string, error)
 	Fork(TaskID string, executionID string) (string, error)
+	ForkContext(ctx context.Context, TaskID string, executionID string) (string, error)
 	RollBackAsync(TaskID string, executionID string) (string, error)
 	RollBack(TaskID string, executionID string) (string, error)
-	Status(TaskID string) (string, error)
+	RollBackContext(ctx context.Context, TaskID string, executionID string) (string, error)
+	ApproveRollBack(TaskID string, executionID string) (string, error)
+	Status(TaskID string) (model.Status, string, error)
 	Stop(TaskID string) error
 	Pause(TaskID string) error
+	Resume(TaskID string) error
+	Recover() ([]string, error)
 }
@@ -0,0 +1,141 @@
+// Package audit records who changed what in a Core: every mutating call
+// made through an audited Core is written to an audit sink with the actor,
+// the operation, the changed fields of the entity, the execution it
+// started and its result.
+//
+//	auditLog, err := audit.NewFileLog("/var/log/inforo/audit.log")
+//	audited, err := audit.NewAuditedCore(audit.AuditOptions{Core: core, Sink: auditLog, Actor: "alice"})
+//	audited.Plans.Run(planID, "")
+//
+// The audited Core shares its state with the original one; calls made
+// through the original Core are not recorded.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditOptions provides configuration options for initializing an audited Core.
+type AuditOptions struct {
+	Core   *inforo.Core
+	Sink   api.AuditSink
+	Actor  string         // Who makes the calls, "system" by default
+	Logger *logrus.Logger // Custom logger instance, the logger of the Core by default
+}
+
+// NewAuditedCore returns a Core whose component, monitoring, task and plan
+// registries record their mutating calls to opts.Sink, along with the tasks
+// registered by Plans.Register.
+//
+// What the original Core does on its own is not recorded: calls made
+// through it, Core.Recover, and the tasks a fork or a plan run starts,
+// which belong to the record of that fork or run.
+func NewAuditedCore(opts AuditOptions) (*inforo.Core, error) {
+	if opts.Core == nil {
+		return nil, errors.New("audit requires a core")
+	}
+	if opts.Sink == nil {
+		return nil, errors.New("audit requires a sink")
+	}
+	if opts.Actor == "" {
+		opts.Actor = "system"
+	}
+	if opts.Logger == nil {
+		opts.Logger = opts.Core.Logger
+	}
+	if opts.Logger == nil {
+		opts.Logger = inforo.NewNullLogger()
+	}
+
+	a := &auditor{sink: opts.Sink, actor: opts.Actor, logger: opts.Logger}
+	core := *opts.Core
+	core.Components = &components{ComponentRegistry: opts.Core.Components, a: a}
+	core.Monitorings = &monitorings{MonitoringRegistry: opts.Core.Monitorings, a: a}
+	auditedTasks := &tasks{TaskRegistry: opts.Core.Tasks, a: a}
+	core.Tasks = auditedTasks
+	core.Plans = &plans{PlanRegistry: opts.Core.Plans, tasks: auditedTasks, a: a}
+	return &core, nil
+}
+
+type auditor struct {
+	sink   api.AuditSink
+	actor  string
+	logger *logrus.Logger
+}
+
+// record writes the record of an operation. The operation has already
+// happened, so a sink failure is logged rather than returned.
+func (a *auditor) record(op model.AuditOperation, entity model.EntityType, id, executionID string, before, after map[string]interface{}, err error) {
+	record := &model.AuditRecord{
+		ID:          uuid.New().String(),
+		Timestamp:   time.Now(),
+		Actor:       a.actor,
+		Operation:   op,
+		Entity:      entity,
+		EntityID:    id,
+		ExecutionID: executionID,
+		Changes:     diff(before, after),
+		Result:      model.AuditSuccess,
+	}
+	if err != nil {
+		record.Result = model.AuditFailure
+		record.Error = err.Error()
+	}
+	if werr := a.sink.Write(record); werr != nil {
+		a.logger.Errorf("audit: failed to record %s of %s %s by %s: %v", op, entity, id, a.actor, werr)
+	}
+}
+
+// diff returns the fields whose values differ, sorted by name.
+func diff(before, after map[string]interface{}) []model.FieldChange {
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]model.FieldChange, 0)
+	for _, field := range fields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changes = append(changes, model.FieldChange{Field: field, Before: before[field], After: after[field]})
+		}
+	}
+	return changes
+}
+
+// fields converts a snapshot of an entity to its JSON field values, so that
+// values compare the same way before and after a round trip through the log.
+func fields(snapshot interface{}) map[string]interface{} {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+func lastStatus(history *model.StatusHistory) model.Status {
+	if history == nil {
+		return ""
+	}
+	return history.LastStatus
+}
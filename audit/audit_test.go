@@ -0,0 +1,183 @@
+package audit_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/audit"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- deploy controller: fails to deploy the "broken" image ---
+type deployController struct{}
+
+func (d *deployController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "broken" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+func (d *deployController) ValideTask(r map[string]string) error      { return nil }
+func (d *deployController) ValideComponent(m map[string]string) error { return nil }
+func (d *deployController) CheckComponent(m map[string]string) error  { return nil }
+
+func setupAudited(t *testing.T, log api.AuditLog, actor string) *inforo.Core {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	audited, err := audit.NewAuditedCore(audit.AuditOptions{Core: c, Sink: log, Actor: actor})
+	require.NoError(t, err)
+	return audited
+}
+
+func query(t *testing.T, log api.AuditLog, q model.AuditQuery) []*model.AuditRecord {
+	records, err := log.Query(q)
+	require.NoError(t, err)
+	return records
+}
+
+func TestAudit_ComponentChanges(t *testing.T) {
+	log := audit.NewMemoryLog()
+	c := setupAudited(t, log, "alice")
+
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	require.NoError(t, c.Components.Update("api", &model.Component{ID: "api", Type: "deploy", Version: "1.1.0"}))
+	require.NoError(t, c.Components.Disable("api"))
+	require.Error(t, c.Components.Delete("missing"))
+
+	records := query(t, log, model.AuditQuery{Entity: model.EntityComponent, EntityID: "api"})
+	require.Len(t, records, 3)
+	for i, op := range []model.AuditOperation{model.AuditRegister, model.AuditUpdate, model.AuditDisable} {
+		assert.Equal(t, op, records[i].Operation)
+		assert.Equal(t, "alice", records[i].Actor)
+		assert.Equal(t, model.AuditSuccess, records[i].Result)
+	}
+	assert.Contains(t, records[0].Changes, model.FieldChange{Field: "Version", After: "1.0.0"})
+	assert.Equal(t, []model.FieldChange{{Field: "Version", Before: "1.0.0", After: "1.1.0"}}, records[1].Changes)
+	assert.Equal(t, []model.FieldChange{{Field: "Status", Before: "pending", After: "disable"}}, records[2].Changes)
+
+	failed := query(t, log, model.AuditQuery{EntityID: "missing"})
+	require.Len(t, failed, 1)
+	assert.Equal(t, model.AuditDelete, failed[0].Operation)
+	assert.Equal(t, model.AuditFailure, failed[0].Result)
+	assert.NotEmpty(t, failed[0].Error)
+	assert.Empty(t, failed[0].Changes)
+}
+
+func TestAudit_Registrations(t *testing.T) {
+	log := audit.NewMemoryLog()
+	c := setupAudited(t, log, "alice")
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	// Повторная регистрация не создаёт сущность
+	_, err = c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "2.0.0"})
+	require.Error(t, err)
+	records := query(t, log, model.AuditQuery{Entity: model.EntityComponent, EntityID: "api"})
+	require.Len(t, records, 2)
+	assert.Equal(t, model.AuditFailure, records[1].Result)
+	assert.Empty(t, records[1].Changes)
+
+	// Задачи плана записываются, даже если сам план не зарегистрирован
+	_, err = c.Plans.Register([]*model.Task{
+		{ID: "deploy-api", Type: model.UpdateTask, Components: []string{"api"}},
+		{ID: "migrate", Type: model.UpdateTask, Components: []string{"api"},
+			DependsOn: []model.Depends{{ID: "missing"}}},
+	})
+	require.Error(t, err)
+	records = query(t, log, model.AuditQuery{Entity: model.EntityTask})
+	require.Len(t, records, 1)
+	assert.Equal(t, model.AuditRegister, records[0].Operation)
+	assert.Equal(t, "deploy-api", records[0].EntityID)
+	assert.Equal(t, model.AuditSuccess, records[0].Result)
+	assert.NotEmpty(t, records[0].Changes)
+	failed := query(t, log, model.AuditQuery{Entity: model.EntityPlan})
+	require.Len(t, failed, 1)
+	assert.Equal(t, model.AuditFailure, failed[0].Result)
+	assert.Empty(t, failed[0].Changes)
+}
+
+func TestAudit_ExecutionsAreLinked(t *testing.T) {
+	log := audit.NewMemoryLog()
+	c := setupAudited(t, log, "bob")
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "deploy-api", Type: model.UpdateTask, Components: []string{"api"},
+		Metadata: map[string]string{"image": "broken"}})
+	require.NoError(t, err)
+
+	// Выполнение завершилось ошибкой, но запись всё равно связана с ним
+	_, err = c.Tasks.Fork("deploy-api", "")
+	require.Error(t, err)
+
+	records := query(t, log, model.AuditQuery{Entity: model.EntityTask})
+	require.Len(t, records, 2)
+	fork := records[1]
+	assert.Equal(t, model.AuditFork, fork.Operation)
+	assert.Equal(t, model.AuditFailure, fork.Result)
+	assert.Equal(t, "deploy failed", fork.Error)
+	require.NotEmpty(t, fork.ExecutionID)
+	_, err = c.Executions.Get(fork.ExecutionID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.FieldChange{{Field: "Status", Before: "created", After: "failed"}}, fork.Changes)
+
+	plan, err := c.Plans.Register([]*model.Task{{ID: "deploy-ok", Type: model.UpdateTask, Components: []string{"api"},
+		Metadata: map[string]string{"image": "v2"}}})
+	require.NoError(t, err)
+	executionID, err := c.Plans.Run(plan.ID, "")
+	require.NoError(t, err)
+
+	runs := query(t, log, model.AuditQuery{Entity: model.EntityPlan, EntityID: plan.ID})
+	require.Len(t, runs, 2)
+	assert.Equal(t, model.AuditRun, runs[1].Operation)
+	assert.Equal(t, executionID, runs[1].ExecutionID)
+}
+
+func TestFileLog_AppendsAndQueriesByTimeRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	log, err := audit.NewFileLog(path)
+	require.NoError(t, err)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"api", "db", "api"} {
+		require.NoError(t, log.Write(&model.AuditRecord{
+			ID: id + string(rune('0'+i)), Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Actor: "alice", Operation: model.AuditUpdate, Entity: model.EntityComponent, EntityID: id,
+			Changes: []model.FieldChange{{Field: "Version", Before: "1.0.0", After: "1.1.0"}},
+			Result:  model.AuditSuccess,
+		}))
+	}
+	require.NoError(t, log.Close())
+
+	// Повторное открытие дописывает в конец, а не перезаписывает журнал
+	log, err = audit.NewFileLog(path)
+	require.NoError(t, err)
+	defer log.Close()
+	require.NoError(t, log.Write(&model.AuditRecord{ID: "late", Timestamp: start.Add(5 * time.Hour),
+		Entity: model.EntityTask, EntityID: "deploy", Result: model.AuditSuccess}))
+
+	all := query(t, log, model.AuditQuery{})
+	require.Len(t, all, 4)
+	assert.Equal(t, "late", all[3].ID)
+
+	component := query(t, log, model.AuditQuery{Entity: model.EntityComponent, EntityID: "api"})
+	require.Len(t, component, 2)
+	assert.Equal(t, []model.FieldChange{{Field: "Version", Before: "1.0.0", After: "1.1.0"}}, component[0].Changes)
+
+	window := query(t, log, model.AuditQuery{Since: start.Add(time.Hour), Until: start.Add(5 * time.Hour)})
+	require.Len(t, window, 2)
+	assert.Equal(t, "db1", window[0].ID)
+	assert.Equal(t, "api2", window[1].ID)
+}
+
+func TestNewAuditedCore_RequiresSink(t *testing.T) {
+	_, err := audit.NewAuditedCore(audit.AuditOptions{Core: inforo.NewDefaultCore()})
+	assert.EqualError(t, err, "audit requires a sink")
+}
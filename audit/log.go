@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// FileLog appends records to a file, one JSON document per line. Records
+// are never rewritten: the file is opened with O_APPEND and synced after
+// every record.
+type FileLog struct {
+	path string
+	file *os.File
+	mu   *sync.Mutex
+}
+
+// NewFileLog opens (and creates if needed) the audit log at path.
+func NewFileLog(path string) (api.AuditLog, error) {
+	if path == "" {
+		return nil, errors.New("audit log path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileLog{
+		path: path,
+		file: file,
+		mu:   &sync.Mutex{},
+	}, nil
+}
+
+func (fl *FileLog) Write(record *model.AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if _, err := fl.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := fl.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return nil
+}
+
+func (fl *FileLog) Query(query model.AuditQuery) ([]*model.AuditRecord, error) {
+	// Запись идёт под той же блокировкой, поэтому строки читаются целиком
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	file, err := os.Open(fl.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	records := make([]*model.AuditRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		record := &model.AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		if query.Match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, nil
+}
+
+func (fl *FileLog) Close() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.file.Close()
+}
+
+// MemoryLog keeps records in memory; useful for tests.
+type MemoryLog struct {
+	records []*model.AuditRecord
+	mu      *sync.RWMutex
+}
+
+func NewMemoryLog() api.AuditLog {
+	return &MemoryLog{mu: &sync.RWMutex{}}
+}
+
+func (ml *MemoryLog) Write(record *model.AuditRecord) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.records = append(ml.records, record)
+	return nil
+}
+
+func (ml *MemoryLog) Query(query model.AuditQuery) ([]*model.AuditRecord, error) {
+	ml.mu.RLock()
+	defer ml.mu.RUnlock()
+	records := make([]*model.AuditRecord, 0)
+	for _, record := range ml.records {
+		if query.Match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (ml *MemoryLog) Close() error {
+	return nil
+}
@@ -0,0 +1,398 @@
+package audit
+
+import (
+	"context"
+	"sort"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/google/uuid"
+)
+
+// Обёртки реестров: чтение проходит напрямую, изменяющие методы
+// записываются в журнал вместе с состоянием сущности до и после вызова.
+
+type components struct {
+	api.ComponentRegistry
+	a *auditor
+}
+
+func (c *components) Register(comp *model.Component) (*model.Component, error) {
+	registered, err := c.ComponentRegistry.Register(comp)
+	id := comp.ID
+	var after map[string]interface{}
+	if err == nil {
+		id = registered.ID
+		after = c.state(id)
+	}
+	c.a.record(model.AuditRegister, model.EntityComponent, id, "", nil, after, err)
+	return registered, err
+}
+
+func (c *components) Update(id string, comp *model.Component) error {
+	return c.mutate(model.AuditUpdate, id, func() error { return c.ComponentRegistry.Update(id, comp) })
+}
+
+func (c *components) Delete(id string) error {
+	return c.mutate(model.AuditDelete, id, func() error { return c.ComponentRegistry.Delete(id) })
+}
+
+func (c *components) Disable(id string) error {
+	return c.mutate(model.AuditDisable, id, func() error { return c.ComponentRegistry.Disable(id) })
+}
+
+func (c *components) Enable(id string) error {
+	return c.mutate(model.AuditEnable, id, func() error { return c.ComponentRegistry.Enable(id) })
+}
+
+func (c *components) mutate(op model.AuditOperation, id string, call func() error) error {
+	before := c.state(id)
+	err := call()
+	c.a.record(op, model.EntityComponent, id, "", before, c.state(id), err)
+	return err
+}
+
+func (c *components) state(id string) map[string]interface{} {
+	comp, err := c.ComponentRegistry.Get(id)
+	if err != nil {
+		return nil
+	}
+	comp.MU.RLock()
+	defer comp.MU.RUnlock()
+	return fields(struct {
+		Name     string            `json:"Name"`
+		Type     string            `json:"Type"`
+		Version  string            `json:"Version"`
+		Metadata map[string]string `json:"MetaData,omitempty"`
+		Status   model.Status      `json:"Status"`
+	}{comp.Name, comp.Type, comp.Version, comp.Metadata, lastStatus(comp.StatusHistory)})
+}
+
+type monitorings struct {
+	api.MonitoringRegistry
+	a *auditor
+}
+
+func (m *monitorings) Register(id string, monitoring *model.Monitoring) (*model.Monitoring, error) {
+	registered, err := m.MonitoringRegistry.Register(id, monitoring)
+	monitoringID := monitoring.ID
+	var after map[string]interface{}
+	if err == nil {
+		monitoringID = registered.ID
+		after = m.state(monitoringID)
+	}
+	m.a.record(model.AuditRegister, model.EntityMonitoring, monitoringID, "", nil, after, err)
+	return registered, err
+}
+
+func (m *monitorings) Update(id string, monitoring *model.Monitoring) error {
+	return m.mutate(model.AuditUpdate, id, func() error { return m.MonitoringRegistry.Update(id, monitoring) })
+}
+
+func (m *monitorings) Delete(id string) error {
+	return m.mutate(model.AuditDelete, id, func() error { return m.MonitoringRegistry.Delete(id) })
+}
+
+func (m *monitorings) mutate(op model.AuditOperation, id string, call func() error) error {
+	before := m.state(id)
+	err := call()
+	m.a.record(op, model.EntityMonitoring, id, "", before, m.state(id), err)
+	return err
+}
+
+func (m *monitorings) state(id string) map[string]interface{} {
+	monitoring, err := m.MonitoringRegistry.Get(id)
+	if err != nil {
+		return nil
+	}
+	monitoring.MU.RLock()
+	defer monitoring.MU.RUnlock()
+	return fields(struct {
+		Name   string            `json:"Name"`
+		Type   string            `json:"Type"`
+		Config map[string]string `json:"Config,omitempty"`
+		Status model.Status      `json:"Status"`
+	}{monitoring.Name, monitoring.Type, monitoring.Config, lastStatus(monitoring.StatusHistory)})
+}
+
+type tasks struct {
+	api.TaskRegistry
+	a *auditor
+}
+
+func (t *tasks) Register(task *model.Task) (*model.Task, error) {
+	registered, err := t.TaskRegistry.Register(task)
+	id := task.ID
+	var after map[string]interface{}
+	if err == nil {
+		id = registered.ID
+		after = t.state(id)
+	}
+	t.a.record(model.AuditRegister, model.EntityTask, id, "", nil, after, err)
+	return registered, err
+}
+
+func (t *tasks) Update(id string, task *model.Task) error {
+	return t.mutate(model.AuditUpdate, id, func() error { return t.TaskRegistry.Update(id, task) })
+}
+
+func (t *tasks) Delete(id string) error {
+	return t.mutate(model.AuditDelete, id, func() error { return t.TaskRegistry.Delete(id) })
+}
+
+func (t *tasks) ForkAsync(taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditFork, taskID, executionID, t.TaskRegistry.ForkAsync)
+}
+
+func (t *tasks) Fork(taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditFork, taskID, executionID, t.TaskRegistry.Fork)
+}
+
+func (t *tasks) ForkContext(ctx context.Context, taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditFork, taskID, executionID, func(taskID, executionID string) (string, error) {
+		return t.TaskRegistry.ForkContext(ctx, taskID, executionID)
+	})
+}
+
+func (t *tasks) RollBackAsync(taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditRollBack, taskID, executionID, t.TaskRegistry.RollBackAsync)
+}
+
+func (t *tasks) RollBack(taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditRollBack, taskID, executionID, t.TaskRegistry.RollBack)
+}
+
+func (t *tasks) RollBackContext(ctx context.Context, taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditRollBack, taskID, executionID, func(taskID, executionID string) (string, error) {
+		return t.TaskRegistry.RollBackContext(ctx, taskID, executionID)
+	})
+}
+
+func (t *tasks) ApproveRollBack(taskID string, executionID string) (string, error) {
+	return t.execute(model.AuditApproveRollBack, taskID, executionID, t.TaskRegistry.ApproveRollBack)
+}
+
+func (t *tasks) Stop(taskID string) error {
+	return t.mutate(model.AuditStop, taskID, func() error { return t.TaskRegistry.Stop(taskID) })
+}
+
+func (t *tasks) Pause(taskID string) error {
+	return t.mutate(model.AuditPause, taskID, func() error { return t.TaskRegistry.Pause(taskID) })
+}
+
+func (t *tasks) Resume(taskID string) error {
+	return t.mutate(model.AuditResume, taskID, func() error { return t.TaskRegistry.Resume(taskID) })
+}
+
+func (t *tasks) Recover() ([]string, error) {
+	ids, err := t.TaskRegistry.Recover()
+	if err != nil {
+		t.a.record(model.AuditRecover, model.EntityTask, "", "", nil, nil, err)
+	}
+	for _, id := range ids {
+		t.a.record(model.AuditRecover, model.EntityTask, id, "", nil, nil, nil)
+	}
+	return ids, err
+}
+
+// execute records a call that starts an execution. The execution ID is
+// chosen up front, so that failed calls are recorded with it too.
+func (t *tasks) execute(op model.AuditOperation, taskID, executionID string, call func(taskID, executionID string) (string, error)) (string, error) {
+	if executionID == "" {
+		executionID = uuid.New().String()
+	}
+	before := t.state(taskID)
+	result, err := call(taskID, executionID)
+	t.a.record(op, model.EntityTask, taskID, executionID, before, t.state(taskID), err)
+	return result, err
+}
+
+func (t *tasks) mutate(op model.AuditOperation, id string, call func() error) error {
+	before := t.state(id)
+	err := call()
+	t.a.record(op, model.EntityTask, id, "", before, t.state(id), err)
+	return err
+}
+
+func (t *tasks) state(id string) map[string]interface{} {
+	task, err := t.TaskRegistry.Get(id)
+	if err != nil {
+		return nil
+	}
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	var rollback *model.Rollback
+	if task.RollBack != nil {
+		rollback = &model.Rollback{Type: task.RollBack.Type, Metadata: task.RollBack.Metadata}
+	}
+	return fields(struct {
+		Name            string                 `json:"Name"`
+		Type            model.TaskType         `json:"Type"`
+		Components      []string               `json:"Components"`
+		DependsOn       []model.Depends        `json:"DependsOn,omitempty"`
+		PreChecks       []string               `json:"PreChecks,omitempty"`
+		PostChecks      []string               `json:"PostChecks,omitempty"`
+		RollBack        *model.Rollback        `json:"RollBack,omitempty"`
+		Retry           *model.RetryPolicy     `json:"Retry,omitempty"`
+		FailurePolicy   model.FailurePolicy    `json:"FailurePolicy,omitempty"`
+		MinSuccessRatio float64                `json:"MinSuccessRatio,omitempty"`
+		Strategy        *model.RolloutStrategy `json:"Strategy,omitempty"`
+		Metadata        map[string]string      `json:"MetaData,omitempty"`
+		Status          model.Status           `json:"Status"`
+	}{
+		task.Name, task.Type, task.Components, task.DependsOn, checkIDs(task.PreChecks), checkIDs(task.PostChecks),
+		rollback, task.Retry, task.FailurePolicy, task.MinSuccessRatio, task.Strategy, task.Metadata,
+		lastStatus(task.StatusHistory),
+	})
+}
+
+func checkIDs(checks []*model.Check) []string {
+	if len(checks) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(checks))
+	for _, check := range checks {
+		ids = append(ids, check.ID)
+	}
+	return ids
+}
+
+type plans struct {
+	api.PlanRegistry
+	tasks *tasks
+	a     *auditor
+}
+
+// Register also records the tasks the plan registers, including those left
+// registered when the plan itself fails.
+func (p *plans) Register(tasks []*model.Task) (*model.Plan, error) {
+	existing := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		_, err := p.tasks.TaskRegistry.Get(task.ID)
+		existing[task.ID] = err == nil
+	}
+
+	plan, err := p.PlanRegistry.Register(tasks)
+
+	for _, task := range tasks {
+		if existing[task.ID] {
+			continue
+		}
+		if after := p.tasks.state(task.ID); after != nil {
+			p.a.record(model.AuditRegister, model.EntityTask, task.ID, "", nil, after, nil)
+		}
+	}
+	id := ""
+	var after map[string]interface{}
+	if err == nil {
+		id = plan.ID
+		after = p.state(id)
+	}
+	p.a.record(model.AuditRegister, model.EntityPlan, id, "", nil, after, err)
+	return plan, err
+}
+
+func (p *plans) Update(id string, plan *model.Plan) error {
+	return p.mutate(model.AuditUpdate, id, func() error { return p.PlanRegistry.Update(id, plan) })
+}
+
+func (p *plans) Delete(id string) error {
+	return p.mutate(model.AuditDelete, id, func() error { return p.PlanRegistry.Delete(id) })
+}
+
+func (p *plans) SetMaxParallelism(planID string, graphID string, limit int) error {
+	return p.mutate(model.AuditSetParallelism, planID, func() error {
+		return p.PlanRegistry.SetMaxParallelism(planID, graphID, limit)
+	})
+}
+
+func (p *plans) RunAsync(planID string, executionID string) (string, error) {
+	return p.execute(model.AuditRun, planID, executionID, p.PlanRegistry.RunAsync)
+}
+
+func (p *plans) Run(planID string, executionID string) (string, error) {
+	return p.execute(model.AuditRun, planID, executionID, p.PlanRegistry.Run)
+}
+
+func (p *plans) RunContext(ctx context.Context, planID string, executionID string) (string, error) {
+	return p.execute(model.AuditRun, planID, executionID, func(planID, executionID string) (string, error) {
+		return p.PlanRegistry.RunContext(ctx, planID, executionID)
+	})
+}
+
+func (p *plans) Stop(planID string) error {
+	return p.mutate(model.AuditStop, planID, func() error { return p.PlanRegistry.Stop(planID) })
+}
+
+func (p *plans) Pause(planID string) error {
+	return p.mutate(model.AuditPause, planID, func() error { return p.PlanRegistry.Pause(planID) })
+}
+
+func (p *plans) Resume(planID string) (string, error) {
+	before := p.state(planID)
+	executionID, err := p.PlanRegistry.Resume(planID)
+	p.a.record(model.AuditResume, model.EntityPlan, planID, executionID, before, p.state(planID), err)
+	return executionID, err
+}
+
+func (p *plans) Recover(policy model.RecoveryPolicy) ([]string, error) {
+	ids, err := p.PlanRegistry.Recover(policy)
+	if err != nil {
+		p.a.record(model.AuditRecover, model.EntityPlan, "", "", nil, nil, err)
+	}
+	for _, id := range ids {
+		p.a.record(model.AuditRecover, model.EntityPlan, id, "", nil, p.state(id), nil)
+	}
+	return ids, err
+}
+
+// execute records a call that starts an execution, see tasks.execute.
+func (p *plans) execute(op model.AuditOperation, planID, executionID string, call func(planID, executionID string) (string, error)) (string, error) {
+	if executionID == "" {
+		executionID = uuid.New().String()
+	}
+	before := p.state(planID)
+	result, err := call(planID, executionID)
+	p.a.record(op, model.EntityPlan, planID, executionID, before, p.state(planID), err)
+	return result, err
+}
+
+func (p *plans) mutate(op model.AuditOperation, id string, call func() error) error {
+	before := p.state(id)
+	err := call()
+	p.a.record(op, model.EntityPlan, id, "", before, p.state(id), err)
+	return err
+}
+
+func (p *plans) state(id string) map[string]interface{} {
+	if id == "" {
+		return nil
+	}
+	plan, err := p.PlanRegistry.Get(id)
+	if err != nil {
+		return nil
+	}
+	plan.MU.RLock()
+	defer plan.MU.RUnlock()
+	taskIDs := make([]string, 0)
+	var graphParallelism map[string]int
+	for _, graph := range plan.TaskGraphs {
+		for taskID := range graph.Tasks {
+			taskIDs = append(taskIDs, taskID)
+		}
+		if graph.MaxParallelism != 0 {
+			if graphParallelism == nil {
+				graphParallelism = make(map[string]int)
+			}
+			graphParallelism[graph.RootTaskID] = graph.MaxParallelism
+		}
+	}
+	sort.Strings(taskIDs)
+	return fields(struct {
+		Tasks            []string       `json:"Tasks"`
+		MaxParallelism   int            `json:"MaxParallelism,omitempty"`
+		GraphParallelism map[string]int `json:"GraphParallelism,omitempty"`
+		Status           model.Status   `json:"Status"`
+	}{taskIDs, plan.MaxParallelism, graphParallelism, lastStatus(plan.StatusHistory)})
+}
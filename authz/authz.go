@@ -0,0 +1,145 @@
+package authz
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+)
+
+// AuthzOptions provides configuration options for initializing an
+// authorized Core.
+type AuthzOptions struct {
+	Core     *inforo.Core
+	Policy   *Policy         // Roles only if nil
+	Identity *model.Identity // The caller; nil is allowed nothing
+}
+
+// NewAuthorizedCore returns a Core acting on behalf of opts.Identity: its
+// component, monitoring, task, plan and execution registries check every
+// call against the policy and return an *api.ForbiddenError when it is not
+// allowed. Lists only contain the entities the identity may read.
+//
+// The authorized Core shares its state with the original one, which stays
+// unrestricted.
+func NewAuthorizedCore(opts AuthzOptions) (*inforo.Core, error) {
+	if opts.Core == nil {
+		return nil, errors.New("authz requires a core")
+	}
+	g := &guard{policy: opts.Policy, identity: opts.Identity, core: opts.Core}
+	core := *opts.Core
+	core.Components = &components{ComponentRegistry: opts.Core.Components, g: g}
+	core.Monitorings = &monitorings{MonitoringRegistry: opts.Core.Monitorings, g: g}
+	core.Tasks = &tasks{TaskRegistry: opts.Core.Tasks, g: g}
+	core.Plans = &plans{PlanRegistry: opts.Core.Plans, g: g}
+	core.Executions = &executions{ExecutionRegistry: opts.Core.Executions, g: g}
+	return &core, nil
+}
+
+// guard authorizes the calls of one identity. Component types are looked
+// up in the original Core, so that rules apply whatever the caller may read.
+type guard struct {
+	policy   *Policy
+	identity *model.Identity
+	core     *inforo.Core
+}
+
+func (g *guard) authorize(op model.AuditOperation, entity model.EntityType, id string, componentTypes []string) error {
+	return g.policy.Authorize(Request{
+		Identity:       g.identity,
+		Operation:      op,
+		Entity:         entity,
+		EntityID:       id,
+		ComponentTypes: componentTypes,
+	})
+}
+
+// componentTypes returns the sorted distinct types of the components.
+func (g *guard) componentTypes(componentIDs []string) []string {
+	seen := make(map[string]bool, len(componentIDs))
+	types := make([]string, 0, len(componentIDs))
+	for _, id := range componentIDs {
+		comp, err := g.core.Components.Get(id)
+		if err != nil {
+			continue
+		}
+		comp.MU.RLock()
+		componentType := comp.Type
+		comp.MU.RUnlock()
+		if !seen[componentType] {
+			seen[componentType] = true
+			types = append(types, componentType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (g *guard) taskComponentTypes(tasks ...*model.Task) []string {
+	componentIDs := make([]string, 0)
+	for _, task := range tasks {
+		task.MU.RLock()
+		componentIDs = append(componentIDs, task.Components...)
+		task.MU.RUnlock()
+	}
+	return g.componentTypes(componentIDs)
+}
+
+func (g *guard) taskTypes(taskID string) []string {
+	task, err := g.core.Tasks.Get(taskID)
+	if err != nil {
+		return nil
+	}
+	return g.taskComponentTypes(task)
+}
+
+func (g *guard) planTypes(planID string) []string {
+	plan, err := g.core.Plans.Get(planID)
+	if err != nil {
+		return nil
+	}
+	plan.MU.RLock()
+	tasks := make([]*model.Task, 0)
+	for _, graph := range plan.TaskGraphs {
+		for _, task := range graph.Tasks {
+			tasks = append(tasks, task)
+		}
+	}
+	plan.MU.RUnlock()
+	return g.taskComponentTypes(tasks...)
+}
+
+// forkTypes returns the component types of the task and of every task it
+// depends on, directly or not: forking a task forks its dependencies through
+// the original Core. The tasks of a plan only depend on each other, so
+// planTypes covers a plan run.
+func (g *guard) forkTypes(taskID string) []string {
+	seen := make(map[string]bool)
+	tasks := make([]*model.Task, 0)
+	queue := []string{taskID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		task, err := g.core.Tasks.Get(id)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, task)
+
+		task.MU.RLock()
+		for _, depends := range task.DependsOn {
+			queue = append(queue, depends.ID)
+		}
+		task.MU.RUnlock()
+	}
+	return g.taskComponentTypes(tasks...)
+}
+
+func (g *guard) componentType(id string) []string {
+	return g.componentTypes([]string{id})
+}
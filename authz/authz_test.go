@@ -0,0 +1,218 @@
+package authz_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/authz"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- mock controller: does nothing ---
+type noopController struct{}
+
+func (n *noopController) RunTask(r map[string]string, p map[string]string) error { return nil }
+func (n *noopController) ValideTask(r map[string]string) error                   { return nil }
+func (n *noopController) ValideComponent(m map[string]string) error              { return nil }
+func (n *noopController) CheckComponent(m map[string]string) error               { return nil }
+
+var (
+	viewer   = &model.Identity{Name: "victor", Roles: []model.Role{model.RoleViewer}}
+	operator = &model.Identity{Name: "olga", Roles: []model.Role{model.RoleOperator}}
+	admin    = &model.Identity{Name: "anna", Roles: []model.Role{model.RoleAdmin}}
+)
+
+// setupCore registers a "web" and a "database" component with a plan each.
+func setupCore(t *testing.T) (*inforo.Core, map[string]string) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("web", &noopController{}))
+	require.NoError(t, c.Controllers.Register("database", &noopController{}))
+
+	plans := make(map[string]string)
+	for _, componentType := range []string{"web", "database"} {
+		_, err := c.Components.Register(&model.Component{ID: componentType + "-1", Type: componentType, Version: "1.0.0"})
+		require.NoError(t, err)
+		plan, err := c.Plans.Register([]*model.Task{{ID: "update-" + componentType, Type: model.UpdateTask,
+			Components: []string{componentType + "-1"}}})
+		require.NoError(t, err)
+		plans[componentType] = plan.ID
+	}
+	return c, plans
+}
+
+func authorized(t *testing.T, c *inforo.Core, policy *authz.Policy, identity *model.Identity) *inforo.Core {
+	scoped, err := authz.NewAuthorizedCore(authz.AuthzOptions{Core: c, Policy: policy, Identity: identity})
+	require.NoError(t, err)
+	return scoped
+}
+
+func assertForbidden(t *testing.T, err error) {
+	t.Helper()
+	var forbidden *api.ForbiddenError
+	assert.True(t, errors.As(err, &forbidden), "expected a forbidden error, got %v", err)
+}
+
+func TestAuthz_Roles(t *testing.T) {
+	c, plans := setupCore(t)
+
+	asViewer := authorized(t, c, nil, viewer)
+	_, err := asViewer.Components.Get("web-1")
+	assert.NoError(t, err)
+	_, err = asViewer.Plans.Run(plans["web"], "")
+	assertForbidden(t, err)
+	assert.EqualError(t, asViewer.Components.Delete("web-1"),
+		"forbidden: victor may not delete component web-1: requires role admin")
+
+	asOperator := authorized(t, c, nil, operator)
+	_, err = asOperator.Plans.Run(plans["web"], "")
+	assert.NoError(t, err)
+	assertForbidden(t, asOperator.Components.Disable("web-1"))
+	_, err = asOperator.Tasks.Register(&model.Task{ID: "new", Type: model.UpdateTask, Components: []string{"web-1"}})
+	assertForbidden(t, err)
+
+	asAdmin := authorized(t, c, nil, admin)
+	assert.NoError(t, asAdmin.Components.Disable("web-1"))
+
+	// Без вызывающего ничего нельзя, даже чтение
+	components, err := authorized(t, c, nil, nil).Components.List()
+	require.NoError(t, err)
+	assert.Empty(t, components)
+	_, err = authorized(t, c, nil, nil).Components.Get("web-1")
+	assert.EqualError(t, err, "forbidden: anonymous may not read component web-1: requires role viewer")
+}
+
+func TestAuthz_RuleOnComponentType(t *testing.T) {
+	c, plans := setupCore(t)
+	policy, err := authz.NewPolicy(authz.PolicyOptions{Rules: []model.AccessRule{{
+		Operations:     []model.AuditOperation{model.AuditRun},
+		ComponentTypes: []string{"database"},
+		Role:           model.RoleAdmin,
+	}}})
+	require.NoError(t, err)
+
+	asOperator := authorized(t, c, policy, operator)
+	_, err = asOperator.Plans.Run(plans["web"], "")
+	assert.NoError(t, err)
+	_, err = asOperator.Plans.Run(plans["database"], "")
+	assert.EqualError(t, err, "forbidden: olga may not run plan "+plans["database"]+": requires role admin by access rule")
+	// Правило касается только запуска
+	_, err = asOperator.Plans.Get(plans["database"])
+	assert.NoError(t, err)
+
+	_, err = authorized(t, c, policy, admin).Plans.Run(plans["database"], "")
+	assert.NoError(t, err)
+}
+
+func TestAuthz_ForkChecksDependencies(t *testing.T) {
+	c, _ := setupCore(t)
+	policy, err := authz.NewPolicy(authz.PolicyOptions{Rules: []model.AccessRule{{
+		Operations:     []model.AuditOperation{model.AuditFork},
+		ComponentTypes: []string{"database"},
+		Role:           model.RoleAdmin,
+	}}})
+	require.NoError(t, err)
+	// web зависит от database через промежуточную задачу
+	_, err = c.Tasks.Register(&model.Task{ID: "prepare-web", Type: model.UpdateTask, Components: []string{"web-1"},
+		DependsOn: []model.Depends{{ID: "update-database", Type: model.Strict}}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "deploy-web", Type: model.UpdateTask, Components: []string{"web-1"},
+		DependsOn: []model.Depends{{ID: "prepare-web", Type: model.Advisory}}})
+	require.NoError(t, err)
+
+	asOperator := authorized(t, c, policy, operator)
+	_, err = asOperator.Tasks.Fork("update-web", "")
+	assert.NoError(t, err)
+	_, err = asOperator.Tasks.Fork("deploy-web", "")
+	assert.EqualError(t, err, "forbidden: olga may not fork task deploy-web: requires role admin by access rule")
+
+	_, err = authorized(t, c, policy, admin).Tasks.Fork("deploy-web", "")
+	assert.NoError(t, err)
+}
+
+func TestAuthz_ListsOnlyReadableEntities(t *testing.T) {
+	c, plans := setupCore(t)
+	policy, err := authz.NewPolicy(authz.PolicyOptions{Rules: []model.AccessRule{{
+		Operations:     []model.AuditOperation{model.AccessRead},
+		ComponentTypes: []string{"database"},
+		Role:           model.RoleOperator,
+	}}})
+	require.NoError(t, err)
+	_, err = c.Plans.Run(plans["database"], "")
+	require.NoError(t, err)
+
+	asViewer := authorized(t, c, policy, viewer)
+	components, err := asViewer.Components.List()
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	assert.Equal(t, "web-1", components[0].ID)
+
+	list, err := asViewer.Plans.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, plans["web"], list[0].ID)
+
+	executions, err := asViewer.Executions.List()
+	require.NoError(t, err)
+	assert.Empty(t, executions)
+	_, err = asViewer.Executions.ListByPlan(plans["database"])
+	assertForbidden(t, err)
+
+	executions, err = authorized(t, c, policy, operator).Executions.List()
+	require.NoError(t, err)
+	assert.Len(t, executions, 1)
+}
+
+func TestAuthz_RegisterAndUpdate(t *testing.T) {
+	c, plans := setupCore(t)
+
+	comp := &model.Component{ID: "web-2", Type: "web", Version: "1.0.0"}
+	_, err := authorized(t, c, nil, operator).Components.Register(comp)
+	assertForbidden(t, err)
+	_, err = c.Components.Get("web-2")
+	assert.Error(t, err)
+
+	registered, err := authorized(t, c, nil, admin).Components.Register(comp)
+	require.NoError(t, err)
+	assert.Equal(t, "web-2", registered.ID)
+
+	plan, err := c.Plans.Get(plans["web"])
+	require.NoError(t, err)
+	assertForbidden(t, authorized(t, c, nil, viewer).Plans.Update(plan.ID, plan))
+	assert.NoError(t, authorized(t, c, nil, admin).Plans.Update(plan.ID, plan))
+}
+
+func TestNewPolicy_RejectsInvalidRole(t *testing.T) {
+	_, err := authz.NewPolicy(authz.PolicyOptions{Rules: []model.AccessRule{{Role: "root"}}})
+	assert.EqualError(t, err, "rule 0: invalid role 'root'")
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+tokens:
+  - token: secret
+    name: alice
+    roles: [operator]
+rules:
+  - operations: [run]
+    componentTypes: [database]
+    role: admin
+`), 0o600))
+
+	cfg, err := authz.LoadConfig(path)
+	require.NoError(t, err)
+	identities, err := cfg.Identities()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*model.Identity{"secret": {Name: "alice", Roles: []model.Role{model.RoleOperator}}}, identities)
+
+	require.NoError(t, os.WriteFile(path, []byte("tokens:\n  - token: secret\n    name: bob\n    roles: [owner]\n"), 0o600))
+	_, err = authz.LoadConfig(path)
+	assert.EqualError(t, err, path+": tokens[0]: invalid role 'owner'")
+}
@@ -0,0 +1,109 @@
+package authz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/laplasd/inforo/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of an access file: the API tokens of the callers
+// and the access rules, in YAML or JSON.
+//
+//	tokens:
+//	  - token: 3f6c...
+//	    name: alice
+//	    roles: [admin]
+//	rules:
+//	  - operations: [run]
+//	    componentTypes: [database]
+//	    role: admin
+type Config struct {
+	Tokens []*TokenConfig `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	Rules  []*RuleConfig  `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+type TokenConfig struct {
+	Token string   `yaml:"token" json:"token"`
+	Name  string   `yaml:"name" json:"name"`
+	Roles []string `yaml:"roles" json:"roles"`
+}
+
+type RuleConfig struct {
+	Operations     []string `yaml:"operations,omitempty" json:"operations,omitempty"`
+	Entities       []string `yaml:"entities,omitempty" json:"entities,omitempty"`
+	EntityIDs      []string `yaml:"entityIDs,omitempty" json:"entityIDs,omitempty"`
+	ComponentTypes []string `yaml:"componentTypes,omitempty" json:"componentTypes,omitempty"`
+	Role           string   `yaml:"role" json:"role"`
+}
+
+// LoadConfig reads an access file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if _, err := cfg.Identities(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if _, err := cfg.Policy(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+// Identities returns the identities by token.
+func (c *Config) Identities() (map[string]*model.Identity, error) {
+	identities := make(map[string]*model.Identity, len(c.Tokens))
+	for i, token := range c.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("tokens[%d]: token is required", i)
+		}
+		if token.Name == "" {
+			return nil, fmt.Errorf("tokens[%d]: name is required", i)
+		}
+		if _, ok := identities[token.Token]; ok {
+			return nil, fmt.Errorf("tokens[%d]: duplicate token", i)
+		}
+		identity := &model.Identity{Name: token.Name}
+		for _, role := range token.Roles {
+			if !model.IsValidRole(model.Role(role)) {
+				return nil, fmt.Errorf("tokens[%d]: invalid role '%s'", i, role)
+			}
+			identity.Roles = append(identity.Roles, model.Role(role))
+		}
+		identities[token.Token] = identity
+	}
+	return identities, nil
+}
+
+// Policy returns the policy of the rules.
+func (c *Config) Policy() (*Policy, error) {
+	rules := make([]model.AccessRule, 0, len(c.Rules))
+	for _, rule := range c.Rules {
+		accessRule := model.AccessRule{
+			EntityIDs:      rule.EntityIDs,
+			ComponentTypes: rule.ComponentTypes,
+			Role:           model.Role(rule.Role),
+		}
+		for _, op := range rule.Operations {
+			accessRule.Operations = append(accessRule.Operations, model.AuditOperation(op))
+		}
+		for _, entity := range rule.Entities {
+			accessRule.Entities = append(accessRule.Entities, model.EntityType(entity))
+		}
+		rules = append(rules, accessRule)
+	}
+	return NewPolicy(PolicyOptions{Rules: rules})
+}
@@ -0,0 +1,129 @@
+// Package authz puts role-based authorization in front of the registries
+// of a Core.
+//
+// Every identity gets what its role allows: viewers read, operators also
+// run and control executions, admins also change definitions. Access rules
+// narrow this down per resource, e.g. only admins run plans touching
+// components of type "database":
+//
+//	policy, err := authz.NewPolicy(authz.PolicyOptions{Rules: []model.AccessRule{{
+//		Operations:     []model.AuditOperation{model.AuditRun},
+//		ComponentTypes: []string{"database"},
+//		Role:           model.RoleAdmin,
+//	}}})
+//	scoped, err := authz.NewAuthorizedCore(authz.AuthzOptions{Core: core, Policy: policy, Identity: caller})
+//	scoped.Plans.Run(planID, "") // *api.ForbiddenError for operators
+package authz
+
+import (
+	"fmt"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// PolicyOptions provides configuration options for initializing a Policy.
+type PolicyOptions struct {
+	Rules []model.AccessRule
+}
+
+// Policy decides whether an identity may perform an operation.
+type Policy struct {
+	rules []model.AccessRule
+}
+
+// NewPolicy returns a policy enforcing the roles and the rules. A nil
+// *Policy enforces the roles only.
+func NewPolicy(opts PolicyOptions) (*Policy, error) {
+	for i, rule := range opts.Rules {
+		if !model.IsValidRole(rule.Role) {
+			return nil, fmt.Errorf("rule %d: invalid role '%s'", i, rule.Role)
+		}
+	}
+	return &Policy{rules: opts.Rules}, nil
+}
+
+// Request describes an operation to authorize.
+type Request struct {
+	Identity  *model.Identity
+	Operation model.AuditOperation
+	Entity    model.EntityType
+	EntityID  string
+	// ComponentTypes — типы компонентов, которые затрагивает операция
+	ComponentTypes []string
+}
+
+// Authorize returns an *api.ForbiddenError if the identity may not perform
+// the operation: its role is below the one the operation needs, or a
+// matching rule requires a higher role.
+func (p *Policy) Authorize(req Request) error {
+	if required := requiredRole(req.Operation); !req.Identity.HasRole(required) {
+		return forbidden(req, fmt.Sprintf("requires role %s", required))
+	}
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.rules {
+		if matches(rule, req) && !req.Identity.HasRole(rule.Role) {
+			return forbidden(req, fmt.Sprintf("requires role %s by access rule", rule.Role))
+		}
+	}
+	return nil
+}
+
+// requiredRole returns the lowest role allowed to perform the operation.
+func requiredRole(op model.AuditOperation) model.Role {
+	switch op {
+	case model.AccessRead:
+		return model.RoleViewer
+	case model.AuditFork, model.AuditRun, model.AuditRollBack, model.AuditApproveRollBack,
+		model.AuditStop, model.AuditPause, model.AuditResume, model.AuditSetParallelism:
+		return model.RoleOperator
+	default:
+		return model.RoleAdmin
+	}
+}
+
+func matches(rule model.AccessRule, req Request) bool {
+	if len(rule.Operations) != 0 && !contains(rule.Operations, req.Operation) {
+		return false
+	}
+	if len(rule.Entities) != 0 && !contains(rule.Entities, req.Entity) {
+		return false
+	}
+	if len(rule.EntityIDs) != 0 && !contains(rule.EntityIDs, req.EntityID) {
+		return false
+	}
+	if len(rule.ComponentTypes) != 0 {
+		for _, componentType := range req.ComponentTypes {
+			if contains(rule.ComponentTypes, componentType) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func forbidden(req Request, reason string) error {
+	actor := "anonymous"
+	if req.Identity != nil && req.Identity.Name != "" {
+		actor = req.Identity.Name
+	}
+	return &api.ForbiddenError{
+		Actor:     actor,
+		Operation: string(req.Operation),
+		Entity:    string(req.Entity),
+		EntityID:  req.EntityID,
+		Reason:    reason,
+	}
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
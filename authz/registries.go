@@ -0,0 +1,469 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// Обёртки реестров: каждый вызов сначала проходит проверку политики.
+
+type components struct {
+	api.ComponentRegistry
+	g *guard
+}
+
+func (c *components) Register(comp *model.Component) (*model.Component, error) {
+	if err := c.g.authorize(model.AuditRegister, model.EntityComponent, comp.ID, []string{comp.Type}); err != nil {
+		return nil, err
+	}
+	return c.ComponentRegistry.Register(comp)
+}
+
+func (c *components) Get(id string) (*model.Component, error) {
+	if err := c.g.authorize(model.AccessRead, model.EntityComponent, id, c.g.componentType(id)); err != nil {
+		return nil, err
+	}
+	return c.ComponentRegistry.Get(id)
+}
+
+func (c *components) Update(id string, comp *model.Component) error {
+	types := append(c.g.componentType(id), comp.Type)
+	if err := c.g.authorize(model.AuditUpdate, model.EntityComponent, id, types); err != nil {
+		return err
+	}
+	return c.ComponentRegistry.Update(id, comp)
+}
+
+func (c *components) Delete(id string) error {
+	if err := c.g.authorize(model.AuditDelete, model.EntityComponent, id, c.g.componentType(id)); err != nil {
+		return err
+	}
+	return c.ComponentRegistry.Delete(id)
+}
+
+func (c *components) Disable(id string) error {
+	if err := c.g.authorize(model.AuditDisable, model.EntityComponent, id, c.g.componentType(id)); err != nil {
+		return err
+	}
+	return c.ComponentRegistry.Disable(id)
+}
+
+func (c *components) Enable(id string) error {
+	if err := c.g.authorize(model.AuditEnable, model.EntityComponent, id, c.g.componentType(id)); err != nil {
+		return err
+	}
+	return c.ComponentRegistry.Enable(id)
+}
+
+func (c *components) List() ([]*model.Component, error) {
+	list, err := c.ComponentRegistry.List()
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]*model.Component, 0, len(list))
+	for _, comp := range list {
+		if c.g.authorize(model.AccessRead, model.EntityComponent, comp.ID, c.g.componentType(comp.ID)) == nil {
+			allowed = append(allowed, comp)
+		}
+	}
+	return allowed, nil
+}
+
+type monitorings struct {
+	api.MonitoringRegistry
+	g *guard
+}
+
+func (m *monitorings) Register(id string, monitoring *model.Monitoring) (*model.Monitoring, error) {
+	if err := m.g.authorize(model.AuditRegister, model.EntityMonitoring, monitoring.ID, nil); err != nil {
+		return nil, err
+	}
+	return m.MonitoringRegistry.Register(id, monitoring)
+}
+
+func (m *monitorings) Get(id string) (*model.Monitoring, error) {
+	if err := m.g.authorize(model.AccessRead, model.EntityMonitoring, id, nil); err != nil {
+		return nil, err
+	}
+	return m.MonitoringRegistry.Get(id)
+}
+
+func (m *monitorings) Update(id string, monitoring *model.Monitoring) error {
+	if err := m.g.authorize(model.AuditUpdate, model.EntityMonitoring, id, nil); err != nil {
+		return err
+	}
+	return m.MonitoringRegistry.Update(id, monitoring)
+}
+
+func (m *monitorings) Delete(id string) error {
+	if err := m.g.authorize(model.AuditDelete, model.EntityMonitoring, id, nil); err != nil {
+		return err
+	}
+	return m.MonitoringRegistry.Delete(id)
+}
+
+func (m *monitorings) List() ([]*model.Monitoring, error) {
+	list, err := m.MonitoringRegistry.List()
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]*model.Monitoring, 0, len(list))
+	for _, monitoring := range list {
+		if m.g.authorize(model.AccessRead, model.EntityMonitoring, monitoring.ID, nil) == nil {
+			allowed = append(allowed, monitoring)
+		}
+	}
+	return allowed, nil
+}
+
+type tasks struct {
+	api.TaskRegistry
+	g *guard
+}
+
+func (t *tasks) Validate(task *model.Task) error {
+	if err := t.g.authorize(model.AccessRead, model.EntityTask, task.ID, t.g.taskComponentTypes(task)); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Validate(task)
+}
+
+func (t *tasks) Register(task *model.Task) (*model.Task, error) {
+	if err := t.g.authorize(model.AuditRegister, model.EntityTask, task.ID, t.g.taskComponentTypes(task)); err != nil {
+		return nil, err
+	}
+	return t.TaskRegistry.Register(task)
+}
+
+func (t *tasks) Get(id string) (*model.Task, error) {
+	if err := t.check(model.AccessRead, id); err != nil {
+		return nil, err
+	}
+	return t.TaskRegistry.Get(id)
+}
+
+func (t *tasks) Update(id string, task *model.Task) error {
+	types := append(t.g.taskTypes(id), t.g.taskComponentTypes(task)...)
+	if err := t.g.authorize(model.AuditUpdate, model.EntityTask, id, types); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Update(id, task)
+}
+
+func (t *tasks) Delete(id string) error {
+	if err := t.check(model.AuditDelete, id); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Delete(id)
+}
+
+func (t *tasks) List() ([]*model.Task, error) {
+	list, err := t.TaskRegistry.List()
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]*model.Task, 0, len(list))
+	for _, task := range list {
+		if t.check(model.AccessRead, task.ID) == nil {
+			allowed = append(allowed, task)
+		}
+	}
+	return allowed, nil
+}
+
+func (t *tasks) ForkAsync(taskID string, executionID string) (string, error) {
+	if err := t.checkFork(taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.ForkAsync(taskID, executionID)
+}
+
+func (t *tasks) Fork(taskID string, executionID string) (string, error) {
+	if err := t.checkFork(taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.Fork(taskID, executionID)
+}
+
+func (t *tasks) ForkContext(ctx context.Context, taskID string, executionID string) (string, error) {
+	if err := t.checkFork(taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.ForkContext(ctx, taskID, executionID)
+}
+
+func (t *tasks) RollBackAsync(taskID string, executionID string) (string, error) {
+	if err := t.check(model.AuditRollBack, taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.RollBackAsync(taskID, executionID)
+}
+
+func (t *tasks) RollBack(taskID string, executionID string) (string, error) {
+	if err := t.check(model.AuditRollBack, taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.RollBack(taskID, executionID)
+}
+
+func (t *tasks) RollBackContext(ctx context.Context, taskID string, executionID string) (string, error) {
+	if err := t.check(model.AuditRollBack, taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.RollBackContext(ctx, taskID, executionID)
+}
+
+func (t *tasks) ApproveRollBack(taskID string, executionID string) (string, error) {
+	if err := t.check(model.AuditApproveRollBack, taskID); err != nil {
+		return "", err
+	}
+	return t.TaskRegistry.ApproveRollBack(taskID, executionID)
+}
+
+func (t *tasks) Status(taskID string) (model.Status, string, error) {
+	if err := t.check(model.AccessRead, taskID); err != nil {
+		return "", "", err
+	}
+	return t.TaskRegistry.Status(taskID)
+}
+
+func (t *tasks) Stop(taskID string) error {
+	if err := t.check(model.AuditStop, taskID); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Stop(taskID)
+}
+
+func (t *tasks) Pause(taskID string) error {
+	if err := t.check(model.AuditPause, taskID); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Pause(taskID)
+}
+
+func (t *tasks) Resume(taskID string) error {
+	if err := t.check(model.AuditResume, taskID); err != nil {
+		return err
+	}
+	return t.TaskRegistry.Resume(taskID)
+}
+
+func (t *tasks) Recover() ([]string, error) {
+	if err := t.g.authorize(model.AuditRecover, model.EntityTask, "", nil); err != nil {
+		return nil, err
+	}
+	return t.TaskRegistry.Recover()
+}
+
+func (t *tasks) check(op model.AuditOperation, taskID string) error {
+	return t.g.authorize(op, model.EntityTask, taskID, t.g.taskTypes(taskID))
+}
+
+// checkFork authorizes forking the task on the components of its
+// dependencies as well.
+func (t *tasks) checkFork(taskID string) error {
+	return t.g.authorize(model.AuditFork, model.EntityTask, taskID, t.g.forkTypes(taskID))
+}
+
+type plans struct {
+	api.PlanRegistry
+	g *guard
+}
+
+func (p *plans) Register(tasks []*model.Task) (*model.Plan, error) {
+	if err := p.g.authorize(model.AuditRegister, model.EntityPlan, "", p.g.taskComponentTypes(tasks...)); err != nil {
+		return nil, err
+	}
+	return p.PlanRegistry.Register(tasks)
+}
+
+func (p *plans) Get(id string) (*model.Plan, error) {
+	if err := p.check(model.AccessRead, id); err != nil {
+		return nil, err
+	}
+	return p.PlanRegistry.Get(id)
+}
+
+func (p *plans) Update(id string, plan *model.Plan) error {
+	if err := p.check(model.AuditUpdate, id); err != nil {
+		return err
+	}
+	return p.PlanRegistry.Update(id, plan)
+}
+
+func (p *plans) Delete(id string) error {
+	if err := p.check(model.AuditDelete, id); err != nil {
+		return err
+	}
+	return p.PlanRegistry.Delete(id)
+}
+
+func (p *plans) List() ([]*model.Plan, error) {
+	list, err := p.PlanRegistry.List()
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]*model.Plan, 0, len(list))
+	for _, plan := range list {
+		if p.check(model.AccessRead, plan.ID) == nil {
+			allowed = append(allowed, plan)
+		}
+	}
+	return allowed, nil
+}
+
+func (p *plans) SetMaxParallelism(planID string, graphID string, limit int) error {
+	if err := p.check(model.AuditSetParallelism, planID); err != nil {
+		return err
+	}
+	return p.PlanRegistry.SetMaxParallelism(planID, graphID, limit)
+}
+
+func (p *plans) RunAsync(planID string, executionID string) (string, error) {
+	if err := p.check(model.AuditRun, planID); err != nil {
+		return "", err
+	}
+	return p.PlanRegistry.RunAsync(planID, executionID)
+}
+
+func (p *plans) Run(planID string, executionID string) (string, error) {
+	if err := p.check(model.AuditRun, planID); err != nil {
+		return "", err
+	}
+	return p.PlanRegistry.Run(planID, executionID)
+}
+
+func (p *plans) RunContext(ctx context.Context, planID string, executionID string) (string, error) {
+	if err := p.check(model.AuditRun, planID); err != nil {
+		return "", err
+	}
+	return p.PlanRegistry.RunContext(ctx, planID, executionID)
+}
+
+func (p *plans) Status(planID string) (model.Status, error) {
+	if err := p.check(model.AccessRead, planID); err != nil {
+		return "", err
+	}
+	return p.PlanRegistry.Status(planID)
+}
+
+func (p *plans) Stop(planID string) error {
+	if err := p.check(model.AuditStop, planID); err != nil {
+		return err
+	}
+	return p.PlanRegistry.Stop(planID)
+}
+
+func (p *plans) Pause(planID string) error {
+	if err := p.check(model.AuditPause, planID); err != nil {
+		return err
+	}
+	return p.PlanRegistry.Pause(planID)
+}
+
+func (p *plans) Resume(planID string) (string, error) {
+	if err := p.check(model.AuditResume, planID); err != nil {
+		return "", err
+	}
+	return p.PlanRegistry.Resume(planID)
+}
+
+func (p *plans) Recover(policy model.RecoveryPolicy) ([]string, error) {
+	if err := p.g.authorize(model.AuditRecover, model.EntityPlan, "", nil); err != nil {
+		return nil, err
+	}
+	return p.PlanRegistry.Recover(policy)
+}
+
+func (p *plans) check(op model.AuditOperation, planID string) error {
+	return p.g.authorize(op, model.EntityPlan, planID, p.g.planTypes(planID))
+}
+
+// executions allows reading an execution to those who may read its plan or
+// task; tracking methods are left to admins.
+type executions struct {
+	api.ExecutionRegistry
+	g *guard
+}
+
+func (e *executions) Reserve(id string, kind model.ExecutionKind, planID string, taskID string) error {
+	if err := e.g.authorize(model.AuditUpdate, model.EntityExecution, id, nil); err != nil {
+		return err
+	}
+	return e.ExecutionRegistry.Reserve(id, kind, planID, taskID)
+}
+
+func (e *executions) Start(id string, kind model.ExecutionKind, planID string, taskID string) bool {
+	if e.g.authorize(model.AuditUpdate, model.EntityExecution, id, nil) != nil {
+		return false
+	}
+	return e.ExecutionRegistry.Start(id, kind, planID, taskID)
+}
+
+func (e *executions) Finish(id string, status model.Status, err error) {
+	if e.g.authorize(model.AuditUpdate, model.EntityExecution, id, nil) != nil {
+		return
+	}
+	e.ExecutionRegistry.Finish(id, status, err)
+}
+
+func (e *executions) Get(id string) (*model.Execution, error) {
+	execution, err := e.ExecutionRegistry.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.check(execution); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+func (e *executions) List() ([]*model.Execution, error) {
+	list, err := e.ExecutionRegistry.List()
+	if err != nil {
+		return nil, err
+	}
+	return e.filter(list), nil
+}
+
+func (e *executions) ListByTask(taskID string) ([]*model.Execution, error) {
+	if err := e.g.authorize(model.AccessRead, model.EntityTask, taskID, e.g.taskTypes(taskID)); err != nil {
+		return nil, err
+	}
+	return e.ExecutionRegistry.ListByTask(taskID)
+}
+
+func (e *executions) ListByPlan(planID string) ([]*model.Execution, error) {
+	if err := e.g.authorize(model.AccessRead, model.EntityPlan, planID, e.g.planTypes(planID)); err != nil {
+		return nil, err
+	}
+	return e.ExecutionRegistry.ListByPlan(planID)
+}
+
+func (e *executions) Wait(ctx context.Context, id string) (*model.Execution, error) {
+	if _, err := e.Get(id); err != nil {
+		return nil, err
+	}
+	return e.ExecutionRegistry.Wait(ctx, id)
+}
+
+func (e *executions) filter(list []*model.Execution) []*model.Execution {
+	allowed := make([]*model.Execution, 0, len(list))
+	for _, execution := range list {
+		if e.check(execution) == nil {
+			allowed = append(allowed, execution)
+		}
+	}
+	return allowed
+}
+
+// check authorizes reading the execution as reading its plan, or its task
+// when it does not belong to a plan.
+func (e *executions) check(execution *model.Execution) error {
+	if execution.PlanID != "" {
+		return e.g.authorize(model.AccessRead, model.EntityPlan, execution.PlanID, e.g.planTypes(execution.PlanID))
+	}
+	return e.g.authorize(model.AccessRead, model.EntityTask, execution.TaskID, e.g.taskTypes(execution.TaskID))
+}
@@ -0,0 +1,136 @@
+package inforo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultBufferSize — буфер подписчика по умолчанию
+const defaultBufferSize = 256
+
+// EventBus fans bus events out to subscribers without blocking the
+// publisher: an event that does not fit in the buffer of a subscriber is
+// dropped for that subscriber and counted.
+type EventBus struct {
+	subscribers map[*subscription]struct{}
+	bufferSize  int
+	mu          *sync.RWMutex
+	logger      *logrus.Logger
+}
+
+type EventBusOptions struct {
+	Logger *logrus.Logger
+	// BufferSize is the channel capacity of each subscriber, 256 by default
+	BufferSize int
+}
+
+type subscription struct {
+	bus     *EventBus
+	filter  model.EventFilter
+	events  chan model.BusEvent
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+func NewEventBus(opts EventBusOptions) api.EventBus {
+	if opts.Logger == nil {
+		opts.Logger = NewNullLogger()
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	return &EventBus{
+		subscribers: make(map[*subscription]struct{}),
+		bufferSize:  opts.BufferSize,
+		mu:          &sync.RWMutex{},
+		logger:      opts.Logger,
+	}
+}
+
+func (b *EventBus) Publish(event model.BusEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped.Add(1)
+			b.logger.Warnf("EventBus.Publish() - subscriber buffer is full, dropped %s %s of %s %s",
+				event.Type, event.Status, event.Entity, event.EntityID)
+		}
+	}
+}
+
+func (b *EventBus) Subscribe(filter model.EventFilter) api.Subscription {
+	sub := &subscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan model.BusEvent, b.bufferSize),
+	}
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (s *subscription) Events() <-chan model.BusEvent {
+	return s.events
+}
+
+func (s *subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *subscription) Close() {
+	s.once.Do(func() {
+		// Под блокировкой шины Publish не может писать в закрываемый канал
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		close(s.events)
+		s.bus.mu.Unlock()
+	})
+}
+
+// publishStatus publishes the transition to the last status of the history.
+func publishStatus(bus api.EventBus, entity model.EntityType, id, taskID string, history *model.StatusHistory) {
+	if bus == nil || history == nil {
+		return
+	}
+	event := model.BusEvent{
+		Type:      model.StatusChanged,
+		Entity:    entity,
+		EntityID:  id,
+		TaskID:    taskID,
+		Status:    history.LastStatus,
+		Timestamp: history.Timestamp,
+	}
+	if len(history.Previous) != 0 {
+		event.PreviousStatus = history.Previous[0].Status
+	}
+	bus.Publish(event)
+}
+
+// publishEvent publishes an entry added to the event history of an entity.
+func publishEvent(bus api.EventBus, entity model.EntityType, id, taskID, message string) {
+	if bus == nil {
+		return
+	}
+	bus.Publish(model.BusEvent{
+		Type:      model.EventAdded,
+		Entity:    entity,
+		EntityID:  id,
+		TaskID:    taskID,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
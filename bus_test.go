@@ -0,0 +1,152 @@
+package inforo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain collects the events published so far.
+func drain(sub api.Subscription) []model.BusEvent {
+	events := make([]model.BusEvent, 0)
+	for {
+		select {
+		case event := <-sub.Events():
+			events = append(events, event)
+		case <-time.After(50 * time.Millisecond):
+			return events
+		}
+	}
+}
+
+func TestEventBus_TaskLifecycle(t *testing.T) {
+	c := setupCoreWithComponent()
+	sub := c.Bus.Subscribe(model.EventFilter{EntityIDs: []string{"task-1"}})
+	defer sub.Close()
+
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+
+	statuses := make([]model.Status, 0)
+	messages := make([]string, 0)
+	for _, event := range drain(sub) {
+		switch {
+		case event.Type == model.StatusChanged && event.Entity == model.EntityTask:
+			statuses = append(statuses, event.Status)
+		case event.Type == model.StatusChanged && event.Entity == model.EntityComponent:
+			assert.Equal(t, "task-1", event.TaskID)
+			assert.Equal(t, "component-1", event.EntityID)
+		case event.Type == model.EventAdded:
+			messages = append(messages, event.Message)
+		}
+	}
+	assert.Equal(t, []model.Status{model.StatusCreated, model.StatusPending, model.StatusRunning, model.StatusSuccess}, statuses)
+	assert.Contains(t, messages, "Created task!")
+	assert.Contains(t, messages, "Success task!")
+}
+
+func TestEventBus_FiltersByTypeAndEntity(t *testing.T) {
+	c, _ := setupFleet(t, &model.Task{PostChecks: []*model.Check{
+		{ID: "errors", MonitoringID: "prometheus", Metadata: map[string]string{}},
+	}}, "node-3")
+	failures := c.Bus.Subscribe(model.EventFilter{
+		Types:    []model.BusEventType{model.StatusChanged},
+		Entities: []model.EntityType{model.EntityComponent},
+	})
+	defer failures.Close()
+	checks := c.Bus.Subscribe(model.EventFilter{Entities: []model.EntityType{model.EntityCheck}})
+	defer checks.Close()
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.Error(t, err)
+
+	failed := make([]string, 0)
+	for _, event := range drain(failures) {
+		assert.Equal(t, model.EntityComponent, event.Entity)
+		if event.Status == model.StatusFailed {
+			failed = append(failed, event.EntityID)
+			assert.Equal(t, model.StatusRunning, event.PreviousStatus)
+		}
+	}
+	assert.Equal(t, []string{"node-3"}, failed)
+
+	// Fail-fast останавливает задачу до пост-проверок
+	assert.Empty(t, drain(checks))
+}
+
+func TestEventBus_PlanAndCheckEvents(t *testing.T) {
+	c, _ := setupFleet(t, &model.Task{PostChecks: []*model.Check{
+		{ID: "errors", MonitoringID: "prometheus", Metadata: map[string]string{}},
+	}})
+	sub := c.Bus.Subscribe(model.EventFilter{
+		Types:    []model.BusEventType{model.StatusChanged},
+		Entities: []model.EntityType{model.EntityPlan, model.EntityCheck},
+	})
+	defer sub.Close()
+
+	task, err := c.Tasks.Get("fleet-update")
+	require.NoError(t, err)
+	require.NoError(t, c.Tasks.Delete(task.ID))
+	plan, err := c.Plans.Register([]*model.Task{{
+		ID: "fleet-update", Type: model.UpdateTask, Components: task.Components,
+		PostChecks: task.PostChecks, Metadata: map[string]string{},
+	}})
+	require.NoError(t, err)
+	_, err = c.Plans.Run(plan.ID, "")
+	require.NoError(t, err)
+
+	var planStatuses []model.Status
+	var checkEvents []model.BusEvent
+	for _, event := range drain(sub) {
+		if event.Entity == model.EntityPlan {
+			assert.Equal(t, plan.ID, event.EntityID)
+			planStatuses = append(planStatuses, event.Status)
+		} else {
+			checkEvents = append(checkEvents, event)
+		}
+	}
+	assert.Equal(t, []model.Status{model.StatusCreated, model.StatusRunning, model.StatusSuccess}, planStatuses)
+	require.Len(t, checkEvents, 1)
+	assert.Equal(t, "errors", checkEvents[0].EntityID)
+	assert.Equal(t, "fleet-update", checkEvents[0].TaskID)
+	assert.Equal(t, model.StatusSuccess, checkEvents[0].Status)
+}
+
+func TestEventBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := inforo.NewEventBus(inforo.EventBusOptions{BufferSize: 2})
+	slow := bus.Subscribe(model.EventFilter{})
+	other := bus.Subscribe(model.EventFilter{EntityIDs: []string{"b"}})
+
+	done := make(chan struct{})
+	go func() {
+		for _, id := range []string{"a", "a", "a", "b"} {
+			bus.Publish(model.BusEvent{Type: model.EventAdded, Entity: model.EntityTask, EntityID: id})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+
+	assert.Equal(t, uint64(2), slow.Dropped())
+	assert.Len(t, drain(slow), 2)
+	assert.Equal(t, uint64(0), other.Dropped())
+	assert.Len(t, drain(other), 1)
+
+	slow.Close()
+	slow.Close()
+	_, open := <-slow.Events()
+	assert.False(t, open)
+	bus.Publish(model.BusEvent{Type: model.EventAdded, Entity: model.EntityTask, EntityID: "a"})
+	other.Close()
+}
@@ -0,0 +1,176 @@
+package inforo_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- scripted monitoring controller: fails checks with "fail" metadata ---
+type scriptedMonitoringController struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *scriptedMonitoringController) RunCheck(meta map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[meta["name"]]++
+	// "once" проваливается только при первом вызове
+	if meta["fail"] == "always" || (meta["fail"] == "once" && s.calls[meta["name"]] == 1) {
+		return errors.New("error rate 5% above 1%")
+	}
+	// "fail_from" — проваливается, начиная с указанного вызова
+	if from, err := strconv.Atoi(meta["fail_from"]); err == nil && s.calls[meta["name"]] >= from {
+		return errors.New("error rate 5% above 1%")
+	}
+	return nil
+}
+
+func (s *scriptedMonitoringController) CheckMonitoring(config map[string]string) error    { return nil }
+func (s *scriptedMonitoringController) ValidateCheck(meta map[string]string) error        { return nil }
+func (s *scriptedMonitoringController) ValidateMonitoring(config map[string]string) error { return nil }
+
+func setupCheckCore(t *testing.T) *inforo.Core {
+	c := setupCoreWithComponent()
+	ctl := &scriptedMonitoringController{calls: make(map[string]int)}
+	require.NoError(t, c.MonitorControllers.Register("scripted", ctl))
+	_, err := c.Monitorings.Register("scripted", &model.Monitoring{ID: "prometheus", Type: "scripted"})
+	require.NoError(t, err)
+	return c
+}
+
+func newCheck(id string, severity model.CheckSeverity, fail string) *model.Check {
+	return &model.Check{
+		ID:           id,
+		MonitoringID: "prometheus",
+		Severity:     severity,
+		Metadata:     map[string]string{"name": id, "fail": fail},
+	}
+}
+
+func TestChecks_BlockingPreCheckFailsTask(t *testing.T) {
+	c := setupCheckCore(t)
+	check := newCheck("pre-1", "", "always")
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PreChecks: []*model.Check{check}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	assert.EqualError(t, err, "check pre-1 failed: error rate 5% above 1%")
+	assert.Equal(t, model.StatusFailed, task.StatusHistory.LastStatus)
+
+	require.NotNil(t, check.Result)
+	assert.Equal(t, model.StatusFailed, check.Result.Status)
+	assert.Equal(t, model.SeverityBlocking, check.Result.Severity)
+	assert.Equal(t, "error rate 5% above 1%", check.Result.Output)
+	assert.Equal(t, model.StatusFailed, check.StatusHistory.LastStatus)
+	assert.Equal(t, 1, eventsContaining(task, "Check pre-1 failed: error rate 5% above 1%"))
+}
+
+func TestChecks_NonBlockingFailuresAreRecorded(t *testing.T) {
+	c := setupCheckCore(t)
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PreChecks: []*model.Check{newCheck("pre-1", model.SeverityInfo, "always")},
+		PostChecks: []*model.Check{
+			newCheck("post-1", model.SeverityWarning, "always"),
+			newCheck("post-2", model.SeverityBlocking, ""),
+		}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+
+	assert.Equal(t, 1, eventsContaining(task, "Info: check pre-1 failed"))
+	assert.Equal(t, 1, eventsContaining(task, "Warning: check post-1 failed"))
+	assert.Equal(t, 1, eventsContaining(task, "Check post-2 passed"))
+	assert.Equal(t, model.StatusSuccess, task.PostChecks[1].StatusHistory.LastStatus)
+}
+
+func TestChecks_Retry(t *testing.T) {
+	c := setupCheckCore(t)
+	check := newCheck("post-1", model.SeverityBlocking, "once")
+	check.Retry = &model.RetryPolicy{MaxAttempts: 2, Delay: model.Duration(time.Millisecond)}
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PostChecks: []*model.Check{check}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, check.Result.Status)
+	assert.Equal(t, 1, eventsContaining(task, "Attempt 1/2 of check post-1 failed"))
+}
+
+func TestChecks_InvalidSeverity(t *testing.T) {
+	c := setupCheckCore(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PostChecks: []*model.Check{newCheck("post-1", "critical", "")}})
+	assert.EqualError(t, err, "check post-1: invalid severity 'critical'")
+}
+
+func newSoakCheck(id string, failFrom string) *model.Check {
+	check := newCheck(id, model.SeverityBlocking, "")
+	check.Metadata["fail_from"] = failFrom
+	check.Mode = model.CheckSoak
+	check.Interval = model.Duration(time.Millisecond)
+	check.Duration = model.Duration(time.Second)
+	return check
+}
+
+func TestChecks_SoakFailureRollsBack(t *testing.T) {
+	c := setupCheckCore(t)
+	check := newSoakCheck("soak-1", "3")
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Metadata:   map[string]string{"image": "app:2.0.0"},
+		PostChecks: []*model.Check{check},
+		RollBack:   &model.Rollback{Type: model.TriggerRollBack, Metadata: map[string]string{"image": "app:1.0.0"}}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "check soak-1 failed: soak failed after 3 runs: error rate 5% above 1%")
+
+	assert.Equal(t, 3, check.Result.Runs)
+	assert.Equal(t, model.StatusRollBack, task.StatusHistory.LastStatus)
+	assert.Equal(t, 1, eventsContaining(task, "Task failed, rolling back task..."))
+}
+
+func TestChecks_SoakPasses(t *testing.T) {
+	c := setupCheckCore(t)
+	early := newSoakCheck("soak-1", "")
+	early.SuccessThreshold = 2
+	window := newSoakCheck("soak-2", "")
+	window.Interval = model.Duration(5 * time.Millisecond)
+	window.Duration = model.Duration(20 * time.Millisecond)
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PostChecks: []*model.Check{early, window}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+
+	assert.Equal(t, 2, early.Result.Runs)
+	assert.Equal(t, 1, eventsContaining(task, "Soak check soak-1 passed early after 2 runs"))
+	// Окно 20ms с интервалом 5ms — не больше пяти запусков
+	assert.GreaterOrEqual(t, window.Result.Runs, 2)
+	assert.LessOrEqual(t, window.Result.Runs, 5)
+}
+
+func TestChecks_InvalidSoak(t *testing.T) {
+	c := setupCheckCore(t)
+	check := newSoakCheck("soak-1", "")
+	check.Interval = 0
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		PostChecks: []*model.Check{check}})
+	assert.EqualError(t, err, "check soak-1: soak needs a positive interval not longer than its duration")
+}
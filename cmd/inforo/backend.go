@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/server"
+)
+
+// backend is the Core the CLI operates on: embedded in the process or
+// reached over the HTTP API.
+type backend interface {
+	RegisterComponent(ctx context.Context, comp *model.Component) error
+	RegisterMonitoring(ctx context.Context, m *model.Monitoring) error
+	RegisterTask(ctx context.Context, task *model.Task) error
+	RegisterPlan(ctx context.Context, tasks []*model.Task) (*model.Plan, error)
+	SetMaxParallelism(ctx context.Context, planID string, limit int) error
+	RunPlan(ctx context.Context, planID string) (string, error)
+	StopPlan(ctx context.Context, planID string) error
+	Plan(ctx context.Context, planID string) (*model.Plan, error)
+	Task(ctx context.Context, taskID string) (*model.Task, error)
+	Execution(ctx context.Context, executionID string) (*model.Execution, error)
+}
+
+type embeddedBackend struct {
+	core *inforo.Core
+}
+
+func (b *embeddedBackend) RegisterComponent(ctx context.Context, comp *model.Component) error {
+	_, err := b.core.Components.Register(&model.Component{
+		ID:       comp.ID,
+		Name:     comp.Name,
+		Type:     comp.Type,
+		Version:  comp.Version,
+		Metadata: comp.Metadata,
+	})
+	return err
+}
+
+func (b *embeddedBackend) RegisterMonitoring(ctx context.Context, m *model.Monitoring) error {
+	_, err := b.core.Monitorings.Register(m.Type, m)
+	return err
+}
+
+func (b *embeddedBackend) RegisterTask(ctx context.Context, task *model.Task) error {
+	_, err := b.core.Tasks.Register(task)
+	return err
+}
+
+func (b *embeddedBackend) RegisterPlan(ctx context.Context, tasks []*model.Task) (*model.Plan, error) {
+	return b.core.Plans.Register(tasks)
+}
+
+func (b *embeddedBackend) SetMaxParallelism(ctx context.Context, planID string, limit int) error {
+	return b.core.Plans.SetMaxParallelism(planID, "", limit)
+}
+
+func (b *embeddedBackend) RunPlan(ctx context.Context, planID string) (string, error) {
+	return b.core.Plans.RunAsync(planID, "")
+}
+
+func (b *embeddedBackend) StopPlan(ctx context.Context, planID string) error {
+	return b.core.Plans.Stop(planID)
+}
+
+func (b *embeddedBackend) Plan(ctx context.Context, planID string) (*model.Plan, error) {
+	return b.core.Plans.Get(planID)
+}
+
+func (b *embeddedBackend) Task(ctx context.Context, taskID string) (*model.Task, error) {
+	return b.core.Tasks.Get(taskID)
+}
+
+func (b *embeddedBackend) Execution(ctx context.Context, executionID string) (*model.Execution, error) {
+	return b.core.Executions.Get(executionID)
+}
+
+type remoteBackend struct {
+	client *server.Client
+}
+
+func (b *remoteBackend) RegisterComponent(ctx context.Context, comp *model.Component) error {
+	_, err := b.client.RegisterComponent(ctx, comp)
+	return err
+}
+
+func (b *remoteBackend) RegisterMonitoring(ctx context.Context, m *model.Monitoring) error {
+	_, err := b.client.RegisterMonitoring(ctx, m)
+	return err
+}
+
+func (b *remoteBackend) RegisterTask(ctx context.Context, task *model.Task) error {
+	_, err := b.client.RegisterTask(ctx, task)
+	return err
+}
+
+func (b *remoteBackend) RegisterPlan(ctx context.Context, tasks []*model.Task) (*model.Plan, error) {
+	return b.client.RegisterPlan(ctx, tasks)
+}
+
+func (b *remoteBackend) SetMaxParallelism(ctx context.Context, planID string, limit int) error {
+	return b.client.SetMaxParallelism(ctx, planID, "", limit)
+}
+
+func (b *remoteBackend) RunPlan(ctx context.Context, planID string) (string, error) {
+	return b.client.RunPlan(ctx, planID)
+}
+
+func (b *remoteBackend) StopPlan(ctx context.Context, planID string) error {
+	return b.client.StopPlan(ctx, planID)
+}
+
+func (b *remoteBackend) Plan(ctx context.Context, planID string) (*model.Plan, error) {
+	return b.client.GetPlan(ctx, planID)
+}
+
+func (b *remoteBackend) Task(ctx context.Context, taskID string) (*model.Task, error) {
+	return b.client.GetTask(ctx, taskID)
+}
+
+func (b *remoteBackend) Execution(ctx context.Context, executionID string) (*model.Execution, error) {
+	return b.client.GetExecution(ctx, executionID)
+}
+
+// backendTarget registers a manifest through a backend.
+type backendTarget struct {
+	ctx     context.Context
+	backend backend
+}
+
+func (t *backendTarget) RegisterComponent(comp *model.Component) error {
+	return t.backend.RegisterComponent(t.ctx, comp)
+}
+
+func (t *backendTarget) RegisterMonitoring(m *model.Monitoring) error {
+	return t.backend.RegisterMonitoring(t.ctx, m)
+}
+
+func (t *backendTarget) RegisterTask(task *model.Task) error {
+	return t.backend.RegisterTask(t.ctx, task)
+}
+
+func (t *backendTarget) RegisterPlan(tasks []*model.Task, maxParallelism int) (string, error) {
+	plan, err := t.backend.RegisterPlan(t.ctx, tasks)
+	if err != nil {
+		return "", err
+	}
+	if maxParallelism != 0 {
+		return plan.ID, t.backend.SetMaxParallelism(t.ctx, plan.ID, maxParallelism)
+	}
+	return plan.ID, nil
+}
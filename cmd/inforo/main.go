@@ -0,0 +1,449 @@
+// Command inforo operates a Core: it loads components, monitorings, tasks
+// and plans from manifest files, validates them, runs plans with live progress and
+// shows status and event history.
+//
+// Without -server the Core is embedded in the process; -data keeps its state
+// in a directory between invocations. With -server the CLI talks to the HTTP
+// API, for example one started with "inforo serve".
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/audit"
+	"github.com/laplasd/inforo/authz"
+	"github.com/laplasd/inforo/controllers"
+	"github.com/laplasd/inforo/manifest"
+	"github.com/laplasd/inforo/metrics"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/server"
+	"github.com/laplasd/inforo/storage"
+
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: inforo <command> [flags] [args]
+
+Commands:
+  validate -f FILE...          check the manifests against an empty Core
+  apply    -f FILE...          register the content of the manifests
+  run      [-f FILE...] PLAN   run a plan (by name from the manifests or by ID) and follow it
+  status   plan|task ID        show the status history
+  events   plan|task ID        show the event history
+  serve    [-addr ADDR] [-audit FILE] [-access FILE]
+                               serve the HTTP API and /metrics over an embedded Core
+
+Run "inforo <command> -h" for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// options are the flags shared by the commands.
+type options struct {
+	files      fileList
+	serverURL  string
+	dataDir    string
+	prometheus string
+	verbose    bool
+	interval   time.Duration
+	addr       string
+	auditLog   string
+	access     string
+	token      string
+	stderr     io.Writer
+}
+
+type fileList []string
+
+func (f *fileList) String() string { return strings.Join(*f, ",") }
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	command := args[0]
+	opts := &options{stderr: stderr}
+	fs := flag.NewFlagSet("inforo "+command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Var(&opts.files, "f", "YAML or JSON manifest with components, monitorings, tasks and plans (repeatable)")
+	fs.StringVar(&opts.serverURL, "server", os.Getenv("INFORO_SERVER"), "URL of the HTTP API; embedded Core if empty")
+	fs.StringVar(&opts.dataDir, "data", "", "state directory of the embedded Core; in memory if empty")
+	fs.StringVar(&opts.prometheus, "prometheus", "", "Prometheus API URL for 'prometheus' monitorings of the embedded Core")
+	fs.BoolVar(&opts.verbose, "v", false, "log the embedded Core to stderr")
+	fs.DurationVar(&opts.interval, "interval", 500*time.Millisecond, "progress polling interval")
+	fs.StringVar(&opts.addr, "addr", "127.0.0.1:8080", "listen address of serve")
+	fs.StringVar(&opts.auditLog, "audit", "", "append-only audit log of the changes made through serve")
+	fs.StringVar(&opts.access, "access", "", "YAML or JSON file with the API tokens and access rules of serve; no authentication if empty")
+	fs.StringVar(&opts.token, "token", os.Getenv("INFORO_TOKEN"), "bearer token sent to the HTTP API")
+
+	var err error
+	switch command {
+	case "validate", "apply", "run", "status", "events", "serve":
+		if err = fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+
+	switch command {
+	case "validate":
+		err = validateCommand(ctx, opts, stdout)
+	case "apply":
+		err = applyCommand(ctx, opts, stdout)
+	case "run":
+		err = runCommand(ctx, opts, fs.Args(), stdout)
+	case "status":
+		err = historyCommand(ctx, opts, fs.Args(), stdout, printStatus)
+	case "events":
+		err = historyCommand(ctx, opts, fs.Args(), stdout, printEvents)
+	case "serve":
+		err = serveCommand(ctx, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// newCore creates the embedded Core with the bundled controllers.
+func newCore(opts *options) (*inforo.Core, error) {
+	logger := inforo.NewNullLogger()
+	if opts.verbose {
+		logger = logrus.New()
+		logger.Out = opts.stderr
+		logger.Level = logrus.DebugLevel
+	}
+
+	coreOpts := inforo.CoreOptions{Logger: logger}
+	if opts.dataDir != "" {
+		store, err := storage.NewFileStorage(opts.dataDir)
+		if err != nil {
+			return nil, err
+		}
+		coreOpts.Storage = store
+	}
+	core := inforo.NewCore(coreOpts)
+
+	if err := core.Controllers.Register("kuber", &controllers.KuberController{Logger: logger}); err != nil {
+		return nil, err
+	}
+	if err := core.Controllers.Register("ssh", &controllers.SSHController{Logger: logger}); err != nil {
+		return nil, err
+	}
+	if opts.prometheus != "" {
+		err := core.MonitorControllers.Register("prometheus", controllers.NewPromQLMonitorController(logger, opts.prometheus))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return core, nil
+}
+
+func openBackend(opts *options) (backend, error) {
+	if opts.serverURL != "" {
+		client, err := server.NewClient(server.ClientOptions{URL: opts.serverURL, Token: opts.token})
+		if err != nil {
+			return nil, err
+		}
+		return &remoteBackend{client: client}, nil
+	}
+	core, err := newCore(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedBackend{core: core}, nil
+}
+
+// apply validates the manifest and registers it, printing what was
+// registered. It returns the IDs of the plans by name. The embedded Core is
+// validated against its controllers and state; a remote one only for the
+// content of the manifest, the server checks the rest.
+func apply(ctx context.Context, b backend, m *manifest.Manifest, out io.Writer) (map[string]string, error) {
+	var core *inforo.Core
+	if embedded, ok := b.(*embeddedBackend); ok {
+		core = embedded.core
+	}
+	if err := m.Validate(core); err != nil {
+		return nil, err
+	}
+
+	result, err := manifest.Register(&backendTarget{ctx: ctx, backend: b}, m)
+	for _, id := range result.Components {
+		fmt.Fprintf(out, "component %s registered\n", id)
+	}
+	for _, id := range result.Monitorings {
+		fmt.Fprintf(out, "monitoring %s registered\n", id)
+	}
+	for _, p := range m.Plans {
+		if id, ok := result.Plans[p.Name]; ok {
+			fmt.Fprintf(out, "plan %s registered as %s\n", p.Name, id)
+		}
+	}
+	for _, id := range result.Tasks {
+		fmt.Fprintf(out, "task %s registered\n", id)
+	}
+	return result.Plans, err
+}
+
+func validateCommand(ctx context.Context, opts *options, stdout io.Writer) error {
+	if len(opts.files) == 0 {
+		return errors.New("validate needs at least one -f FILE")
+	}
+	m, err := manifest.Load(opts.files...)
+	if err != nil {
+		return err
+	}
+
+	// Проверяем на пустом Core в памяти, чтобы не менять настоящее состояние
+	core, err := newCore(&options{prometheus: opts.prometheus, verbose: opts.verbose, stderr: opts.stderr})
+	if err != nil {
+		return err
+	}
+	if err := m.Validate(core); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d components, %d monitorings, %d tasks, %d plans are valid\n",
+		len(m.Components), len(m.Monitorings), len(m.Tasks), len(m.Plans))
+	return nil
+}
+
+func applyCommand(ctx context.Context, opts *options, stdout io.Writer) error {
+	if len(opts.files) == 0 {
+		return errors.New("apply needs at least one -f FILE")
+	}
+	m, err := manifest.Load(opts.files...)
+	if err != nil {
+		return err
+	}
+	b, err := openBackend(opts)
+	if err != nil {
+		return err
+	}
+	_, err = apply(ctx, b, m, stdout)
+	return err
+}
+
+func runCommand(ctx context.Context, opts *options, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("run needs exactly one PLAN")
+	}
+	b, err := openBackend(opts)
+	if err != nil {
+		return err
+	}
+
+	planID := args[0]
+	if len(opts.files) != 0 {
+		m, err := manifest.Load(opts.files...)
+		if err != nil {
+			return err
+		}
+		plans, err := apply(ctx, b, m, stdout)
+		if err != nil {
+			return err
+		}
+		if id, ok := plans[planID]; ok {
+			planID = id
+		}
+	}
+
+	executionID, err := b.RunPlan(ctx, planID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "plan %s started, execution %s\n", planID, executionID)
+
+	execution, err := watchPlan(ctx, b, planID, executionID, opts.interval, stdout)
+	if err != nil {
+		return err
+	}
+	if execution.Status != model.StatusSuccess {
+		return fmt.Errorf("plan %s finished with status %s: %s", planID, execution.Status, execution.Error)
+	}
+	return nil
+}
+
+// watchPlan prints the status changes and new events of the tasks of the plan
+// until the execution finishes. Interrupting ctx stops the plan.
+func watchPlan(ctx context.Context, b backend, planID, executionID string, interval time.Duration, out io.Writer) (*model.Execution, error) {
+	statuses := make(map[string]model.Status)
+	seenEvents := make(map[string]int)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pollCtx := context.Background()
+	for {
+		plan, err := b.Plan(pollCtx, planID)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range planTasks(plan) {
+			task.MU.RLock()
+			status := task.StatusHistory.LastStatus
+			task.MU.RUnlock()
+			if status != statuses[task.ID] {
+				statuses[task.ID] = status
+				fmt.Fprintf(out, "%s  %-24s %s\n", time.Now().Format("15:04:05"), task.ID, status)
+			}
+			events := eventsOf(task.EventHistory)
+			for _, event := range events[min(seenEvents[task.ID], len(events)):] {
+				fmt.Fprintf(out, "          %-24s %s\n", task.ID, event.Message)
+			}
+			seenEvents[task.ID] = len(events)
+		}
+
+		execution, err := b.Execution(pollCtx, executionID)
+		if err != nil {
+			return nil, err
+		}
+		if !execution.FinishedAt.IsZero() {
+			fmt.Fprintf(out, "plan %s %s in %s\n", planID, execution.Status,
+				execution.FinishedAt.Sub(execution.StartedAt).Round(time.Millisecond))
+			return execution, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// Прерывание останавливает план; дожидаемся, пока он свернётся
+			fmt.Fprintf(out, "stopping plan %s...\n", planID)
+			if err := b.StopPlan(pollCtx, planID); err != nil {
+				return nil, err
+			}
+			ctx = context.Background()
+		}
+	}
+}
+
+// planTasks returns the tasks of every graph of the plan, ordered by ID.
+func planTasks(plan *model.Plan) []*model.Task {
+	tasks := make([]*model.Task, 0)
+	for _, graph := range plan.TaskGraphs {
+		for _, task := range graph.Tasks {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+func eventsOf(history *model.EventHistory) []model.Event {
+	if history == nil {
+		return nil
+	}
+	history.MU.RLock()
+	defer history.MU.RUnlock()
+	return append([]model.Event{}, history.Event...)
+}
+
+func historyCommand(ctx context.Context, opts *options, args []string, stdout io.Writer,
+	print func(out io.Writer, status *model.StatusHistory, events *model.EventHistory)) error {
+	if len(args) != 2 || (args[0] != "plan" && args[0] != "task") {
+		return errors.New("expected 'plan ID' or 'task ID'")
+	}
+	b, err := openBackend(opts)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "plan" {
+		plan, err := b.Plan(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		plan.MU.RLock()
+		defer plan.MU.RUnlock()
+		print(stdout, plan.StatusHistory, plan.EventHistory)
+		return nil
+	}
+
+	task, err := b.Task(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	print(stdout, task.StatusHistory, task.EventHistory)
+	return nil
+}
+
+func printStatus(out io.Writer, status *model.StatusHistory, events *model.EventHistory) {
+	if status == nil {
+		return
+	}
+	fmt.Fprintf(out, "%s  %s\n", status.Timestamp.Format(time.RFC3339), status.LastStatus)
+	for _, prev := range status.Previous {
+		fmt.Fprintf(out, "%s  %s\n", prev.Timestamp.Format(time.RFC3339), prev.Status)
+	}
+}
+
+func printEvents(out io.Writer, status *model.StatusHistory, events *model.EventHistory) {
+	for _, event := range eventsOf(events) {
+		fmt.Fprintf(out, "%s  %s\n", event.Timestamp.Format(time.RFC3339), event.Message)
+	}
+}
+
+func serveCommand(ctx context.Context, opts *options) error {
+	core, err := newCore(opts)
+	if err != nil {
+		return err
+	}
+	m, err := metrics.NewMetrics(metrics.MetricsOptions{Core: core})
+	if err != nil {
+		return err
+	}
+	go m.Run(ctx)
+
+	serverOpts := server.ServerOptions{Core: core, Metrics: m.Handler()}
+	if opts.auditLog != "" {
+		log, err := audit.NewFileLog(opts.auditLog)
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		serverOpts.Audit = log
+	}
+	if opts.access != "" {
+		cfg, err := authz.LoadConfig(opts.access)
+		if err != nil {
+			return err
+		}
+		// Ошибки уже проверены LoadConfig
+		tokens, _ := cfg.Identities()
+		serverOpts.Authenticate = server.BearerTokens(tokens)
+		serverOpts.Policy, _ = cfg.Policy()
+	}
+
+	srv, err := server.NewServer(serverOpts)
+	if err != nil {
+		return err
+	}
+	return srv.ListenAndServe(ctx, opts.addr)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- deploy controller: fails to deploy the "broken" image ---
+type deployController struct{}
+
+func (d *deployController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "broken" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+func (d *deployController) ValideTask(r map[string]string) error      { return nil }
+func (d *deployController) ValideComponent(m map[string]string) error { return nil }
+func (d *deployController) CheckComponent(m map[string]string) error  { return nil }
+
+func writeSpec(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func startServer(t *testing.T) string {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	srv, err := server.NewServer(server.ServerOptions{Core: c})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestValidate_ReportsEveryError(t *testing.T) {
+	spec := writeSpec(t, `
+version: v1
+components:
+  - {id: api, type: unknown, version: 1.0.0}
+tasks:
+  - {id: deploy, type: update, components: [api, db]}
+`)
+
+	code, _, stderr := runCLI("validate", "-f", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `spec.yaml: components[0].type: no controller for component type "unknown"`)
+	assert.Contains(t, stderr, `spec.yaml: tasks[0].components[1]: component "db" is not defined`)
+}
+
+func TestValidate_Example(t *testing.T) {
+	code, stdout, stderr := runCLI("validate", "-f", "../../examples/cli/release.yaml")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "2 components, 0 monitorings, 3 tasks, 1 plans are valid\n", stdout)
+}
+
+func TestRun_RemotePlan(t *testing.T) {
+	url := startServer(t)
+	spec := writeSpec(t, `
+version: v1
+components:
+  - {id: api, type: deploy, version: 1.0.0}
+tasks:
+  - {id: migrate, type: update, components: [api]}
+  - {id: deploy, type: update, components: [api], dependsOn: [{id: migrate}]}
+plans:
+  - {name: release, tasks: [migrate, deploy]}
+`)
+
+	code, stdout, stderr := runCLI("run", "-server", url, "-interval", "10ms", "-f", spec, "release")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "component api registered")
+	assert.Contains(t, stdout, "plan release registered as")
+	assert.Contains(t, stdout, "Success task!")
+	assert.Contains(t, stdout, "success in")
+
+	code, stdout, _ = runCLI("status", "-server", url, "task", "deploy")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "success")
+
+	code, stdout, _ = runCLI("events", "-server", url, "task", "deploy")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Created task!")
+}
+
+func TestRun_FailedPlanExitsWithError(t *testing.T) {
+	url := startServer(t)
+	spec := writeSpec(t, `{
+  "version": "v1",
+  "components": [{"id": "api", "type": "deploy", "version": "1.0.0"}],
+  "tasks": [{"id": "deploy", "type": "update", "components": ["api"], "metadata": {"image": "broken"}}],
+  "plans": [{"name": "release", "tasks": ["deploy"]}]
+}`)
+
+	code, _, stderr := runCLI("run", "-server", url, "-interval", "10ms", "-f", spec, "release")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "finished with status failed")
+	assert.Contains(t, stderr, "deploy failed")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	code, _, stderr := runCLI("deploy")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "deploy"`)
+}
@@ -47,17 +47,23 @@ func newTestCore() *inforo.Core {
 // --- tests ---
 func TestRegisterComponent_Success(t *testing.T) {
 	c := newTestCore()
-	comp := model.Component{ID: "c1", Name: "Comp1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
+	comp := &model.Component{ID: "c1", Name: "Comp1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
 
 	registered, err := c.Components.Register(comp)
 	assert.NoError(t, err)
 	assert.Equal(t, "c1", registered.ID)
 	assert.Equal(t, model.StatusPending, registered.StatusHistory.LastStatus)
+	// Реестр хранит копию, компонент вызывающего не меняется
+	assert.NotSame(t, comp, registered)
+	assert.Nil(t, comp.StatusHistory)
+
+	_, err = c.Components.Register(nil)
+	assert.EqualError(t, err, "component is nil")
 }
 
 func TestRegisterComponent_AlreadyRegistered(t *testing.T) {
 	c := newTestCore()
-	comp := model.Component{ID: "c1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
+	comp := &model.Component{ID: "c1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
 	_, _ = c.Components.Register(comp)
 	_, err := c.Components.Register(comp)
 	assert.Error(t, err)
@@ -65,14 +71,14 @@ func TestRegisterComponent_AlreadyRegistered(t *testing.T) {
 
 func TestRegisterComponent_UnsupportedType(t *testing.T) {
 	c := newTestCore()
-	comp := model.Component{ID: "bad", Type: "unknown", Version: "1.0.0", Metadata: map[string]string{}}
+	comp := &model.Component{ID: "bad", Type: "unknown", Version: "1.0.0", Metadata: map[string]string{}}
 	_, err := c.Components.Register(comp)
 	assert.Error(t, err)
 }
 
 func TestGetComponent_Success(t *testing.T) {
 	c := newTestCore()
-	comp := model.Component{ID: "get1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
+	comp := &model.Component{ID: "get1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
 	got, err := c.Components.Register(comp)
 
 	assert.NoError(t, err)
@@ -87,7 +93,7 @@ func TestGetComponent_NotFound(t *testing.T) {
 
 func TestUpdateComponent_Success(t *testing.T) {
 	c := newTestCore()
-	orig := model.Component{ID: "upd1", Name: "Old", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
+	orig := &model.Component{ID: "upd1", Name: "Old", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
 	_, _ = c.Components.Register(orig)
 
 	updated := &model.Component{ID: "upd1", Name: "New", Type: "mock", Version: "1.0.0", Metadata: map[string]string{"foo": "bar"}}
@@ -108,7 +114,7 @@ func TestUpdateComponent_NotFound(t *testing.T) {
 func TestUpdateComponent_InvalidType(t *testing.T) {
 	c := newTestCore()
 	comp := &model.Component{ID: "x", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
-	_, _ = c.Components.Register(*comp)
+	_, _ = c.Components.Register(comp)
 
 	comp.Type = "unknown"
 	err := c.Components.Update("x", comp)
@@ -117,7 +123,7 @@ func TestUpdateComponent_InvalidType(t *testing.T) {
 
 func TestDeleteComponent_Success(t *testing.T) {
 	c := newTestCore()
-	comp := model.Component{ID: "del1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
+	comp := &model.Component{ID: "del1", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}}
 	_, _ = c.Components.Register(comp)
 
 	err := c.Components.Delete("del1")
@@ -132,8 +138,8 @@ func TestDeleteComponent_NotFound(t *testing.T) {
 
 func TestListComponents(t *testing.T) {
 	c := newTestCore()
-	_, _ = c.Components.Register(model.Component{ID: "a", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}})
-	_, _ = c.Components.Register(model.Component{ID: "b", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}})
+	_, _ = c.Components.Register(&model.Component{ID: "a", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}})
+	_, _ = c.Components.Register(&model.Component{ID: "b", Type: "mock", Version: "1.0.0", Metadata: map[string]string{}})
 
 	list, _ := c.Components.List()
 	assert.Len(t, list, 2)
@@ -146,7 +152,7 @@ func TestComponentRegistry_Register_CreatesEventHistory(t *testing.T) {
 	//err := c.Controllers.Register("mock", &MockController{})
 	//require.NoError(t, err)
 
-	testComp := model.Component{
+	testComp := &model.Component{
 		Name:     "test-component",
 		Version:  "1.0.0",
 		Type:     "mock",
@@ -1,6 +1,7 @@
 package inforo
 
 import (
+	"encoding/json"
 	"errors"
 	"reflect"
 	"strconv"
@@ -15,8 +16,10 @@ import (
 type ComponentRegistry struct {
 	Controllers api.ControllerRegistry
 	components  map[string]*model.Component
+	storage     api.Storage
 	*Events
 	*StatusManager
+	bus    api.EventBus
 	mu     *sync.RWMutex
 	logger *logrus.Logger
 }
@@ -24,18 +27,51 @@ type ComponentRegistry struct {
 type ComponentRegistryOptions struct {
 	Logger        *logrus.Logger
 	Controllers   api.ControllerRegistry
+	Storage       api.Storage
 	StatusManager *StatusManager
 	EventManager  *Events
+	Bus           api.EventBus // Получает изменения статусов и событий, если задан
 }
 
 func NewComponentRegistry(opts ComponentRegistryOptions) (api.ComponentRegistry, error) {
-	return &ComponentRegistry{
+	cr := &ComponentRegistry{
 		mu:            &sync.RWMutex{},
 		logger:        opts.Logger,
 		Controllers:   opts.Controllers,
+		storage:       opts.Storage,
 		StatusManager: opts.StatusManager,
+		bus:           opts.Bus,
 		components:    make(map[string]*model.Component),
-	}, nil
+	}
+	if err := cr.load(); err != nil {
+		return cr, err
+	}
+	return cr, nil
+}
+
+// load restores the components saved in the storage.
+func (cr *ComponentRegistry) load() error {
+	return loadDocuments(cr.storage, componentsCollection, func(id string, data []byte) error {
+		comp := &model.Component{}
+		if err := json.Unmarshal(data, comp); err != nil {
+			return err
+		}
+		if comp.StatusHistory == nil {
+			comp.StatusHistory = cr.NewStatus(model.StatusPending)
+		}
+		if comp.EventHistory == nil {
+			comp.EventHistory = &model.EventHistory{}
+		}
+		cr.components[comp.ID] = comp
+		return nil
+	})
+}
+
+// save persists the component; callers hold cr.mu.
+func (cr *ComponentRegistry) save(comp *model.Component) error {
+	comp.EventHistory.MU.RLock()
+	defer comp.EventHistory.MU.RUnlock()
+	return saveDocument(cr.storage, componentsCollection, comp.ID, comp)
 }
 
 func (cr *ComponentRegistry) Delete(id string) error {
@@ -47,6 +83,9 @@ func (cr *ComponentRegistry) Delete(id string) error {
 		return errors.New("component not found")
 	}
 
+	if err := deleteDocument(cr.storage, componentsCollection, id); err != nil {
+		return err
+	}
 	delete(cr.components, id)
 	return nil
 }
@@ -132,9 +171,20 @@ func (cr *ComponentRegistry) GetBy(key string, value string) ([]*model.Component
 	return result, nil
 }
 
-func (cr *ComponentRegistry) Register(comp model.Component) (*model.Component, error) {
-	cr.logger.Debugf("ComponentRegistry.Register: call(), args: comp[%v]", comp)
+func (cr *ComponentRegistry) Register(input *model.Component) (*model.Component, error) {
+	cr.logger.Debugf("ComponentRegistry.Register: call(), args: comp[%v]", input)
 
+	if input == nil {
+		return nil, errors.New("component is nil")
+	}
+	// Реестр хранит свою копию, компонент вызывающего не меняется
+	comp := &model.Component{
+		ID:       input.ID,
+		Name:     input.Name,
+		Type:     input.Type,
+		Version:  input.Version,
+		Metadata: input.Metadata,
+	}
 	if comp.ID == "" {
 		comp.ID = uuid.New().String()
 	}
@@ -161,11 +211,17 @@ func (cr *ComponentRegistry) Register(comp model.Component) (*model.Component, e
 	comp.StatusHistory = cr.NewStatus(model.StatusPending)
 	comp.EventHistory = &model.EventHistory{}
 	cr.AddEvent(comp.EventHistory, "Created component!")
+	publishStatus(cr.bus, model.EntityComponent, comp.ID, "", comp.StatusHistory)
+	publishEvent(cr.bus, model.EntityComponent, comp.ID, "", "Created component!")
 
-	cr.components[comp.ID] = &comp
+	if err := cr.save(comp); err != nil {
+		cr.logger.Errorf("ComponentRegistry.Register: return(error) -> '%v'", err)
+		return nil, err
+	}
+	cr.components[comp.ID] = comp
 
 	cr.logger.Debugf("ComponentRegistry.Register: return(error) -> '%v'", nil)
-	return &comp, nil
+	return comp, nil
 }
 
 func (cr *ComponentRegistry) Update(id string, updatedComp *model.Component) error {
@@ -176,9 +232,11 @@ func (cr *ComponentRegistry) Update(id string, updatedComp *model.Component) err
 	if !exists {
 		return errors.New("component not found")
 	}
+	updatedComp.MU.Lock()
 	updatedComp.ID = comp.ID // чтобы не изменить ID по ошибке
 	updatedComp.EventHistory = comp.EventHistory
 	updatedComp.StatusHistory = comp.StatusHistory
+	updatedComp.MU.Unlock()
 
 	if cr.Controllers != nil {
 		err := cr.checkMeta(updatedComp.Type, updatedComp.Metadata)
@@ -187,6 +245,9 @@ func (cr *ComponentRegistry) Update(id string, updatedComp *model.Component) err
 		}
 	}
 
+	if err := cr.save(updatedComp); err != nil {
+		return err
+	}
 	cr.components[id] = updatedComp
 
 	cr.logger.Infof("Component %s updated", id)
@@ -194,34 +255,34 @@ func (cr *ComponentRegistry) Update(id string, updatedComp *model.Component) err
 }
 
 func (cr *ComponentRegistry) Disable(id string) error {
-
-	comp, err := cr.Get(id)
-	if err != nil {
-		return err
-	}
-	comp.StatusHistory = cr.NextStatus(model.StatusDisable, comp.StatusHistory)
-
-	err = cr.Update(comp.ID, comp)
-	if err != nil {
-		return err
-	}
-	return nil
+	return cr.setStatus(id, model.StatusDisable)
 }
 
 func (cr *ComponentRegistry) Enable(id string) error {
+	return cr.setStatus(id, model.StatusPending)
+}
 
-	comp, err := cr.Get(id)
-	if err != nil {
-		return err
+// setStatus moves the component to the status. The history is replaced
+// under comp.MU, the lock readers such as the server hold.
+func (cr *ComponentRegistry) setStatus(id string, status model.Status) error {
+	cr.mu.Lock()
+	comp, exists := cr.components[id]
+	if !exists {
+		cr.mu.Unlock()
+		return errors.New("component not found")
 	}
-	comp.StatusHistory = cr.NextStatus(model.StatusPending, comp.StatusHistory)
-
-	err = cr.Update(comp.ID, comp)
+	history := cr.NextStatus(status, comp.StatusHistory)
+	comp.MU.Lock()
+	comp.StatusHistory = history
+	comp.MU.Unlock()
+	err := cr.save(comp)
+	cr.mu.Unlock()
 	if err != nil {
 		return err
 	}
-	return nil
 
+	publishStatus(cr.bus, model.EntityComponent, comp.ID, "", history)
+	return nil
 }
 
 func (cr *ComponentRegistry) UpVersion(componentID string, version string) {
@@ -0,0 +1,153 @@
+package inforo_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- fleet controller: fails components with "broken" metadata, records rollbacks ---
+type fleetController struct {
+	mockController
+	mu         sync.Mutex
+	rolledBack []string
+	active     int
+	maxActive  int
+}
+
+func (f *fleetController) RunTask(r map[string]string, p map[string]string) error {
+	f.mu.Lock()
+	f.active++
+	f.maxActive = max(f.maxActive, f.active)
+	f.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+
+	if r["rollback"] == "true" {
+		f.mu.Lock()
+		f.rolledBack = append(f.rolledBack, p["name"])
+		f.mu.Unlock()
+		return nil
+	}
+	if p["broken"] == "true" {
+		return errors.New("update failed")
+	}
+	return nil
+}
+
+func setupFleet(t *testing.T, task *model.Task, broken ...string) (*inforo.Core, *fleetController) {
+	c := inforo.NewDefaultCore()
+	controller := &fleetController{}
+	require.NoError(t, c.Controllers.Register("fleet", controller))
+	require.NoError(t, c.MonitorControllers.Register("scripted", &scriptedMonitoringController{calls: make(map[string]int)}))
+	_, err := c.Monitorings.Register("scripted", &model.Monitoring{ID: "prometheus", Type: "scripted"})
+	require.NoError(t, err)
+
+	isBroken := map[string]bool{}
+	for _, id := range broken {
+		isBroken[id] = true
+	}
+	for _, id := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		meta := map[string]string{"name": id}
+		if isBroken[id] {
+			meta["broken"] = "true"
+		}
+		_, err := c.Components.Register(&model.Component{ID: id, Type: "fleet", Version: "1.0.0", Metadata: meta})
+		require.NoError(t, err)
+	}
+
+	task.ID = "fleet-update"
+	task.Type = model.UpdateTask
+	task.Components = []string{"node-1", "node-2", "node-3", "node-4"}
+	task.Metadata = map[string]string{}
+	task.RollBack = &model.Rollback{Type: model.TriggerRollBack, Metadata: map[string]string{"rollback": "true"}}
+	_, err = c.Tasks.Register(task)
+	require.NoError(t, err)
+	return c, controller
+}
+
+func componentStatuses(t *testing.T, c *inforo.Core) map[string]model.Status {
+	task, err := c.Tasks.Get("fleet-update")
+	require.NoError(t, err)
+
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	statuses := map[string]model.Status{}
+	for id, result := range task.ComponentResults {
+		statuses[id] = result.Status
+	}
+	return statuses
+}
+
+func TestComponents_FailFastRollsBackChangedOnly(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{}, "node-2")
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	assert.EqualError(t, err, "update failed")
+
+	assert.Equal(t, []string{"node-1", "node-2"}, controller.rolledBack)
+	assert.Equal(t, map[string]model.Status{
+		"node-1": model.StatusRollBack,
+		"node-2": model.StatusRollBack,
+		"node-3": model.StatusSkipped,
+		"node-4": model.StatusSkipped,
+	}, componentStatuses(t, c))
+}
+
+func TestComponents_ContinueOnError(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{FailurePolicy: model.ContinueOnError}, "node-2", "node-4")
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "component node-2: update failed")
+	assert.Contains(t, err.Error(), "component node-4: update failed")
+	assert.Equal(t, []string{"node-1", "node-2", "node-3", "node-4"}, controller.rolledBack)
+}
+
+func TestComponents_MinSuccessRatio(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{FailurePolicy: model.MinSuccessRatio, MinSuccessRatio: 0.75}, "node-3")
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.NoError(t, err)
+	assert.Empty(t, controller.rolledBack)
+
+	status, _, err := c.Tasks.Status("fleet-update")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, status)
+	assert.Equal(t, map[string]model.Status{
+		"node-1": model.StatusSuccess,
+		"node-2": model.StatusSuccess,
+		"node-3": model.StatusFailed,
+		"node-4": model.StatusSuccess,
+	}, componentStatuses(t, c))
+}
+
+func TestComponents_MinSuccessRatioNotMet(t *testing.T) {
+	c, _ := setupFleet(t, &model.Task{FailurePolicy: model.MinSuccessRatio, MinSuccessRatio: 0.75}, "node-1", "node-3")
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "success ratio 0.50 below 0.75")
+}
+
+func TestComponents_InvalidPolicy(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		FailurePolicy: "best-effort"})
+	assert.EqualError(t, err, "invalid failure policy 'best-effort'")
+
+	_, err = c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		FailurePolicy: model.MinSuccessRatio, MinSuccessRatio: 1.5})
+	assert.EqualError(t, err, "invalid min success ratio 1.5, expected (0, 1]")
+}
@@ -0,0 +1,94 @@
+package inforo
+
+import (
+	"context"
+
+	"github.com/laplasd/inforo/api"
+)
+
+// ControllerWithContext returns a context-aware view of a controller.
+// Controllers that already implement api.ContextController are returned as is;
+// legacy controllers are wrapped so that the caller stops waiting as soon as
+// the context is done. The wrapped RunTask keeps running in the background
+// until it returns, since a legacy controller has no way to be interrupted.
+//
+// Parameters:
+//   - c: controller to adapt
+//
+// Returns:
+// api.ContextController - controller accepting a context.Context
+func ControllerWithContext(c api.Controller) api.ContextController {
+	if cc, ok := c.(api.ContextController); ok {
+		return cc
+	}
+	return &contextController{Controller: c}
+}
+
+type contextController struct {
+	api.Controller
+}
+
+func (c *contextController) RunTaskContext(ctx context.Context, taskMeta map[string]string, componentMeta map[string]string) error {
+	return runWithContext(ctx, func() error {
+		return c.RunTask(taskMeta, componentMeta)
+	})
+}
+
+// MonitoringControllerWithContext returns a context-aware view of a monitoring
+// controller, following the same rules as ControllerWithContext.
+//
+// Parameters:
+//   - c: monitoring controller to adapt
+//
+// Returns:
+// api.ContextMonitoringController - monitoring controller accepting a context.Context
+func MonitoringControllerWithContext(c api.MonitoringController) api.ContextMonitoringController {
+	if cc, ok := c.(api.ContextMonitoringController); ok {
+		return cc
+	}
+	return &contextMonitoringController{MonitoringController: c}
+}
+
+type contextMonitoringController struct {
+	api.MonitoringController
+}
+
+func (c *contextMonitoringController) RunCheckContext(ctx context.Context, monitorMeta map[string]string) error {
+	return runWithContext(ctx, func() error {
+		return c.RunCheck(monitorMeta)
+	})
+}
+
+// runWithContext runs fn in its own goroutine and returns either its result
+// or the context error, whichever comes first.
+func runWithContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type dependsResolvedKey struct{}
+
+// withDependsResolved marks ctx of a task whose dependencies were already
+// handled by the caller, e.g. the plan scheduler, so ForkContext does not
+// resolve them again.
+func withDependsResolved(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dependsResolvedKey{}, true)
+}
+
+func dependsResolved(ctx context.Context) bool {
+	resolved, _ := ctx.Value(dependsResolvedKey{}).(bool)
+	return resolved
+}
@@ -0,0 +1,103 @@
+package inforo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- blocking controller: RunTask never returns until released ---
+type blockingController struct {
+	mockController
+	release chan struct{}
+}
+
+func (b *blockingController) RunTask(r map[string]string, p map[string]string) error {
+	<-b.release
+	return nil
+}
+
+func setupCoreWithBlockingComponent(t *testing.T) (*inforo.Core, *blockingController) {
+	c := inforo.NewDefaultCore()
+	ctl := &blockingController{release: make(chan struct{})}
+	t.Cleanup(func() { close(ctl.release) })
+
+	require.NoError(t, c.Controllers.Register("blocking", ctl))
+	_, err := c.Components.Register(&model.Component{
+		ID:      "component-1",
+		Name:    "Blocking Component",
+		Type:    "blocking",
+		Version: "1.0.0",
+	})
+	require.NoError(t, err)
+	return c, ctl
+}
+
+func TestControllerWithContext_KeepsContextController(t *testing.T) {
+	ctl := &blockingController{}
+	adapted := inforo.ControllerWithContext(ctl)
+	assert.NotSame(t, ctl, adapted)
+
+	again := inforo.ControllerWithContext(adapted)
+	assert.Same(t, adapted, again)
+}
+
+func TestControllerWithContext_Cancel(t *testing.T) {
+	ctl := &blockingController{release: make(chan struct{})}
+	defer close(ctl.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := inforo.ControllerWithContext(ctl).RunTaskContext(ctx, nil, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestForkContext_Timeout(t *testing.T) {
+	c, _ := setupCoreWithBlockingComponent(t)
+
+	_, err := c.Tasks.Register(&model.Task{
+		ID:         "task-1",
+		Name:       "Hung task",
+		Type:       model.UpdateTask,
+		Components: []string{"component-1"},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = c.Tasks.ForkContext(ctx, "task-1", "")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	task, _ := c.Tasks.Get("task-1")
+	assert.Equal(t, model.StatusStopped, task.StatusHistory.LastStatus)
+}
+
+func TestRunContext_CancelPropagatesToTasks(t *testing.T) {
+	c, _ := setupCoreWithBlockingComponent(t)
+
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Name: "Hung task", Type: model.UpdateTask, Components: []string{"component-1"}},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = c.Plans.RunContext(ctx, plan.ID, "")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	status, _ := c.Plans.Status(plan.ID)
+	assert.Equal(t, model.StatusStopped, status)
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -20,13 +22,22 @@ type KuberController struct {
 }
 
 func (i *KuberController) RunTask(taskMeta map[string]string, componentMeta map[string]string) error {
+	return i.RunTaskContext(context.Background(), taskMeta, componentMeta)
+}
+
+func (i *KuberController) RunTaskContext(ctx context.Context, taskMeta map[string]string, componentMeta map[string]string) error {
 	taskID := taskMeta["id"] // предполагаем, что ID есть в метаданных
 	taskType := taskMeta["Type"]
 
 	i.Logger.Infof("KuberController running task %s of type %s with component metadata: %+v", taskID, taskType, componentMeta)
 
 	// Здесь может быть логика запуска kubectl, apply, check и т.д.
-	time.Sleep(1 * time.Second)
+	select {
+	case <-time.After(1 * time.Second):
+	case <-ctx.Done():
+		i.Logger.Warnf("Task %s cancelled: %v", taskID, ctx.Err())
+		return ctx.Err()
+	}
 
 	i.Logger.Infof("Task %s completed", taskID)
 	return nil
@@ -55,6 +66,12 @@ type SSHController struct {
 }
 
 func (s *SSHController) RunTask(taskMeta map[string]string, componentMeta map[string]string) error {
+	return s.RunTaskContext(context.Background(), taskMeta, componentMeta)
+}
+
+// RunTaskContext runs the command like RunTask. Cancelling ctx aborts the
+// dial and closes the session of a command that is still running.
+func (s *SSHController) RunTaskContext(ctx context.Context, taskMeta map[string]string, componentMeta map[string]string) error {
 	cmd := taskMeta["command"]
 	taskID := taskMeta["id"]
 	taskType := taskMeta["type"]
@@ -81,7 +98,7 @@ func (s *SSHController) RunTask(taskMeta map[string]string, componentMeta map[st
 	}
 
 	address := fmt.Sprintf("%s:%s", host, port)
-	client, err := ssh.Dial("tcp", address, config)
+	client, err := dialSSHContext(ctx, address, config)
 	if err != nil {
 		return fmt.Errorf("failed to dial SSH: %w", err)
 	}
@@ -97,9 +114,24 @@ func (s *SSHController) RunTask(taskMeta map[string]string, componentMeta map[st
 	session.Stdout = &stdout
 	session.Stderr = &stderr
 
-	if err := session.Run(cmd); err != nil {
-		s.Logger.Errorf("SSH command failed: %s", stderr.String())
-		return fmt.Errorf("ssh command error: %w", err)
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(cmd)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			s.Logger.Errorf("SSH command failed: %s", stderr.String())
+			return fmt.Errorf("ssh command error: %w", err)
+		}
+	case <-ctx.Done():
+		// Закрываем сессию и соединение, чтобы прервать зависшую команду
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		client.Close()
+		s.Logger.Warnf("SSH task %s cancelled: %v", taskID, ctx.Err())
+		return fmt.Errorf("ssh command cancelled: %w", ctx.Err())
 	}
 
 	s.Logger.Infof("SSH task %s output:\n%s", taskID, stdout.String())
@@ -167,3 +199,28 @@ func (s *SSHController) CheckComponent(componentMeta map[string]string) error {
 
 	return nil
 }
+
+// dialSSHContext устанавливает SSH-соединение, учитывая отмену контекста
+func dialSSHContext(ctx context.Context, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Рукопожатие не принимает контекст, поэтому прерываем его закрытием соединения
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
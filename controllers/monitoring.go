@@ -1,12 +1,19 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/laplasd/inforo/api"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,13 +29,21 @@ func NewPromQLMonitorController(logger *logrus.Logger, apiURL string) *PromQLMon
 	}
 }
 
-// ValidateCheck проверяет корректность параметров запроса PromQL
+// ValidateCheck проверяет корректность параметров запроса PromQL.
+//
+// Поддерживаемые ключи метаданных проверки:
+//   - query     — PromQL-запрос (обязательный)
+//   - operator  — оператор сравнения значений с порогом: <, <=, >, >=, ==, !=
+//     (или lt, le, gt, ge, eq, ne); без оператора проверка проходит, если запрос вернул данные
+//   - threshold — порог для operator
+//   - match     — сколько серий должно удовлетворять условию: all (по умолчанию), any, none
+//   - range     — окно для range-запроса, например "10m"; серия удовлетворяет условию,
+//     если ему удовлетворяют все её значения в окне
+//   - step      — шаг range-запроса, по умолчанию "1m"
+//   - timeout   — таймаут HTTP-запроса, по умолчанию "10s"
 func (p *PromQLMonitorController) ValidateCheck(monitorMeta map[string]string) error {
-	query := monitorMeta["query"]
-	if query == "" {
-		return fmt.Errorf("promql query is required")
-	}
-	return nil
+	_, err := parsePromQLCheck(monitorMeta)
+	return err
 }
 
 // ValidateMonitoring — здесь можно проверить конфиг мониторинга, например, таймауты, повторные попытки и т.д.
@@ -39,21 +54,38 @@ func (p *PromQLMonitorController) ValidateMonitoring(config map[string]string) e
 
 // RunCheck выполняет запрос к Prometheus API и анализирует результат
 func (p *PromQLMonitorController) RunCheck(monitorMeta map[string]string) error {
-	query := monitorMeta["query"]
-	timeoutStr := monitorMeta["timeout"] // например, "5s"
-	timeout := 10 * time.Second
-	if timeoutStr != "" {
-		if d, err := time.ParseDuration(timeoutStr); err == nil {
-			timeout = d
-		}
+	return p.RunCheckContext(context.Background(), monitorMeta)
+}
+
+// RunCheckContext выполняет RunCheck, прерывая HTTP-запрос при отмене ctx
+func (p *PromQLMonitorController) RunCheckContext(ctx context.Context, monitorMeta map[string]string) error {
+	check, err := parsePromQLCheck(monitorMeta)
+	if err != nil {
+		// Повтор с теми же метаданными не поможет
+		return api.Permanent(err)
 	}
 
-	client := &http.Client{Timeout: timeout}
+	client := &http.Client{Timeout: check.timeout}
 
-	url := fmt.Sprintf("%s/query?query=%s", p.promAPIURL, query)
-	p.logger.Debugf("Running PromQL query: %s", url)
+	params := url.Values{}
+	params.Set("query", check.query)
+	endpoint := "query"
+	if check.window > 0 {
+		end := time.Now()
+		endpoint = "query_range"
+		params.Set("start", strconv.FormatInt(end.Add(-check.window).Unix(), 10))
+		params.Set("end", strconv.FormatInt(end.Unix(), 10))
+		params.Set("step", strconv.FormatFloat(check.step.Seconds(), 'f', -1, 64))
+	}
+	queryURL := fmt.Sprintf("%s/%s?%s", p.promAPIURL, endpoint, params.Encode())
+	p.logger.Debugf("Running PromQL query: %s", queryURL)
 
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build prometheus request: %w", err)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to query prometheus: %w", err)
 	}
@@ -73,12 +105,18 @@ func (p *PromQLMonitorController) RunCheck(monitorMeta map[string]string) error
 		return fmt.Errorf("prometheus query failed with status: %s", result.Status)
 	}
 
-	// Пример простой проверки: считаем, что успешный мониторинг — это когда query вернула не пустой набор данных
 	if len(result.Data.Result) == 0 {
 		return fmt.Errorf("promql query returned no data")
 	}
 
-	p.logger.Infof("PromQL monitoring check passed for query: %s", query)
+	// Без оператора достаточно того, что запрос вернул данные
+	if check.operator != "" {
+		if err := check.evaluate(result.Data.Result); err != nil {
+			return err
+		}
+	}
+
+	p.logger.Infof("PromQL monitoring check passed for query: %s", check.query)
 	return nil
 }
 
@@ -103,5 +141,212 @@ type PrometheusQueryData struct {
 
 type PrometheusQueryResult struct {
 	Metric map[string]string `json:"metric"`
-	Value  [2]interface{}    `json:"value"` // [ timestamp, value ]
+	Value  [2]interface{}    `json:"value"`            // [ timestamp, value ]
+	Values [][2]interface{}  `json:"values,omitempty"` // для matrix: [ [ timestamp, value ], ... ]
+}
+
+// UnmarshalJSON разбирает все типы результатов; scalar приводится к одной
+// серии без меток.
+func (d *PrometheusQueryData) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	d.ResultType = raw.ResultType
+	d.Result = nil
+	if len(raw.Result) == 0 {
+		return nil
+	}
+
+	switch raw.ResultType {
+	case "scalar", "string":
+		var value [2]interface{}
+		if err := json.Unmarshal(raw.Result, &value); err != nil {
+			return err
+		}
+		d.Result = []PrometheusQueryResult{{Metric: map[string]string{}, Value: value}}
+		return nil
+	default:
+		return json.Unmarshal(raw.Result, &d.Result)
+	}
+}
+
+// samples возвращает значения серии: одно для vector, все из окна для matrix
+func (r PrometheusQueryResult) samples() ([]float64, error) {
+	points := r.Values
+	if len(points) == 0 {
+		points = [][2]interface{}{r.Value}
+	}
+	values := make([]float64, 0, len(points))
+	for _, point := range points {
+		raw, ok := point[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected sample value %v", point[1])
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample value %q: %w", raw, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// seriesName форматирует метки серии в виде {a="1", b="2"}
+func seriesName(metric map[string]string) string {
+	keys := make([]string, 0, len(metric))
+	for k := range metric {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := make([]string, 0, len(keys))
+	for _, k := range keys {
+		labels = append(labels, fmt.Sprintf("%s=%q", k, metric[k]))
+	}
+	return "{" + strings.Join(labels, ", ") + "}"
+}
+
+//
+// Пороговые проверки
+//
+
+type promQLCheck struct {
+	query     string
+	operator  string
+	threshold float64
+	match     string
+	window    time.Duration
+	step      time.Duration
+	timeout   time.Duration
+}
+
+var promQLOperators = map[string]string{
+	"<": "<", "lt": "<",
+	"<=": "<=", "le": "<=",
+	">": ">", "gt": ">",
+	">=": ">=", "ge": ">=",
+	"==": "==", "eq": "==",
+	"!=": "!=", "ne": "!=",
+}
+
+func parsePromQLCheck(meta map[string]string) (*promQLCheck, error) {
+	check := &promQLCheck{
+		query:   meta["query"],
+		match:   meta["match"],
+		step:    time.Minute,
+		timeout: 10 * time.Second,
+	}
+	if check.query == "" {
+		return nil, fmt.Errorf("promql query is required")
+	}
+
+	if raw := meta["operator"]; raw != "" {
+		operator, ok := promQLOperators[raw]
+		if !ok {
+			return nil, fmt.Errorf("invalid operator %q", raw)
+		}
+		check.operator = operator
+
+		threshold, err := strconv.ParseFloat(meta["threshold"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %w", meta["threshold"], err)
+		}
+		check.threshold = threshold
+	} else if meta["threshold"] != "" {
+		return nil, fmt.Errorf("threshold requires an operator")
+	}
+
+	switch check.match {
+	case "":
+		check.match = "all"
+	case "all", "any", "none":
+	default:
+		return nil, fmt.Errorf("invalid match %q, expected all, any or none", check.match)
+	}
+
+	var err error
+	if raw := meta["range"]; raw != "" {
+		if check.window, err = time.ParseDuration(raw); err != nil || check.window <= 0 {
+			return nil, fmt.Errorf("invalid range %q", raw)
+		}
+	}
+	if raw := meta["step"]; raw != "" {
+		if check.step, err = time.ParseDuration(raw); err != nil || check.step <= 0 {
+			return nil, fmt.Errorf("invalid step %q", raw)
+		}
+	}
+	if raw := meta["timeout"]; raw != "" {
+		if check.timeout, err = time.ParseDuration(raw); err != nil || check.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", raw)
+		}
+	}
+	return check, nil
+}
+
+func (c *promQLCheck) compare(value float64) bool {
+	switch c.operator {
+	case "<":
+		return value < c.threshold
+	case "<=":
+		return value <= c.threshold
+	case ">":
+		return value > c.threshold
+	case ">=":
+		return value >= c.threshold
+	case "==":
+		return value == c.threshold
+	default:
+		return value != c.threshold
+	}
+}
+
+// evaluate сравнивает серии с порогом по правилу match. Ошибка содержит
+// наблюдаемые значения серий, из-за которых проверка не прошла.
+func (c *promQLCheck) evaluate(series []PrometheusQueryResult) error {
+	matched := 0
+	var matching, failing []string
+	for _, s := range series {
+		values, err := s.samples()
+		if err != nil {
+			return fmt.Errorf("series %s: %w", seriesName(s.Metric), err)
+		}
+		// Серия удовлетворяет условию, только если ему удовлетворяют все значения
+		violation := -1
+		for i, value := range values {
+			if !c.compare(value) {
+				violation = i
+				break
+			}
+		}
+		if violation < 0 {
+			matched++
+			matching = append(matching, fmt.Sprintf("%s=%s", seriesName(s.Metric), formatSample(values[len(values)-1])))
+		} else {
+			failing = append(failing, fmt.Sprintf("%s=%s", seriesName(s.Metric), formatSample(values[violation])))
+		}
+	}
+
+	condition := fmt.Sprintf("%s %s", c.operator, formatSample(c.threshold))
+	switch c.match {
+	case "any":
+		if matched == 0 {
+			return fmt.Errorf("no series match %q, observed: %s", condition, strings.Join(failing, ", "))
+		}
+	case "none":
+		if matched > 0 {
+			return fmt.Errorf("%d of %d series match %q, observed: %s", matched, len(series), condition, strings.Join(matching, ", "))
+		}
+	default:
+		if len(failing) > 0 {
+			return fmt.Errorf("%d of %d series do not match %q, observed: %s", len(failing), len(series), condition, strings.Join(failing, ", "))
+		}
+	}
+	return nil
+}
+
+func formatSample(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
 }
@@ -0,0 +1,121 @@
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/controllers"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vectorResponse = `{"status":"success","data":{"resultType":"vector","result":[
+	{"metric":{"instance":"web-1"},"value":[1700000000,"0.002"]},
+	{"metric":{"instance":"web-2"},"value":[1700000000,"0.05"]}]}}`
+
+const matrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[
+	{"metric":{"instance":"web-1"},"values":[[1700000000,"0.1"],[1700000060,"0.2"],[1700000120,"0.35"]]}]}}`
+
+const scalarResponse = `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"42"]}}`
+
+func newPromQL(t *testing.T, body string) (*controllers.PromQLMonitorController, *[]*http.Request) {
+	requests := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+	return controllers.NewPromQLMonitorController(logger, server.URL+"/api/v1"), &requests
+}
+
+func TestPromQL_Thresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		meta    map[string]string
+		wantErr string
+	}{
+		{name: "no operator needs data", body: vectorResponse,
+			meta: map[string]string{"query": "up"}},
+		{name: "all series below", body: vectorResponse,
+			meta: map[string]string{"query": "error_rate", "operator": "<", "threshold": "0.1"}},
+		{name: "one series above", body: vectorResponse,
+			meta:    map[string]string{"query": "error_rate", "operator": "lt", "threshold": "0.01"},
+			wantErr: `1 of 2 series do not match "< 0.01", observed: {instance="web-2"}=0.05`},
+		{name: "any series below", body: vectorResponse,
+			meta: map[string]string{"query": "error_rate", "operator": "<", "threshold": "0.01", "match": "any"}},
+		{name: "none above", body: vectorResponse,
+			meta:    map[string]string{"query": "error_rate", "operator": ">=", "threshold": "0.01", "match": "none"},
+			wantErr: `1 of 2 series match ">= 0.01", observed: {instance="web-2"}=0.05`},
+		{name: "range breached once", body: matrixResponse,
+			meta:    map[string]string{"query": "p99", "operator": "<", "threshold": "0.3", "range": "10m"},
+			wantErr: `1 of 1 series do not match "< 0.3", observed: {instance="web-1"}=0.35`},
+		{name: "scalar", body: scalarResponse,
+			meta: map[string]string{"query": "scalar(up)", "operator": "==", "threshold": "42"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller, _ := newPromQL(t, tt.body)
+			require.NoError(t, controller.ValidateCheck(tt.meta))
+
+			err := controller.RunCheck(tt.meta)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPromQL_RangeQuery(t *testing.T) {
+	controller, requests := newPromQL(t, matrixResponse)
+
+	require.NoError(t, controller.RunCheck(map[string]string{
+		"query": `rate(http_requests_total{code=~"5.."}[5m])`, "operator": "<", "threshold": "1",
+		"range": "10m", "step": "30s",
+	}))
+
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, "/api/v1/query_range", req.URL.Path)
+	assert.Equal(t, `rate(http_requests_total{code=~"5.."}[5m])`, req.URL.Query().Get("query"))
+	assert.Equal(t, "30", req.URL.Query().Get("step"))
+	assert.NotEmpty(t, req.URL.Query().Get("start"))
+}
+
+func TestPromQL_InvalidCheck(t *testing.T) {
+	controller, requests := newPromQL(t, vectorResponse)
+
+	tests := []struct {
+		meta    map[string]string
+		wantErr string
+	}{
+		{meta: map[string]string{"query": "up", "operator": "~"}, wantErr: `invalid operator "~"`},
+		{meta: map[string]string{"query": "up", "operator": "<", "threshold": "x"},
+			wantErr: `invalid threshold "x": strconv.ParseFloat: parsing "x": invalid syntax`},
+		{meta: map[string]string{"query": "up", "threshold": "1"}, wantErr: "threshold requires an operator"},
+		{meta: map[string]string{"query": "up", "match": "most"}, wantErr: `invalid match "most", expected all, any or none`},
+		{meta: map[string]string{"query": "up", "operator": "<", "threshold": "1", "range": "soon"}, wantErr: `invalid range "soon"`},
+	}
+	for _, tt := range tests {
+		assert.EqualError(t, controller.ValidateCheck(tt.meta), tt.wantErr)
+
+		// Ошибка метаданных не исправится повтором
+		err := controller.RunCheck(tt.meta)
+		var permanent *api.PermanentError
+		assert.True(t, errors.As(err, &permanent), fmt.Sprintf("%v is retryable", err))
+	}
+	assert.Empty(t, *requests)
+}
@@ -10,12 +10,15 @@
 package inforo
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/laplasd/inforo/api"
 	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/storage"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Core represents the central orchestrator that manages all system operations.
@@ -28,6 +31,9 @@ type Core struct {
 	MonitorControllers api.MonitoringControllerRegistry // Registry for monitoring controllers
 	Tasks              api.TaskRegistry                 // Registry for task management
 	Plans              api.PlanRegistry                 // Registry for execution plans
+	Executions         api.ExecutionRegistry            // Registry for task and plan executions
+	Storage            api.Storage                      // Storage backing the default registries
+	Bus                api.EventBus                     // Status and event changes of the default registries
 }
 
 // CoreOptions provides configuration options for initializing a Core instance.
@@ -40,6 +46,10 @@ type CoreOptions struct {
 	MonitorControllers api.MonitoringControllerRegistry `json:"MonitorControllers"` // Custom monitoring controller registry
 	Tasks              api.TaskRegistry                 `json:"Tasks"`              // Custom task registry
 	Plans              api.PlanRegistry                 `json:"Plans"`              // Custom plan registry
+	Executions         api.ExecutionRegistry            `json:"Executions"`         // Custom execution registry
+	Storage            api.Storage                      `json:"Storage"`            // Storage for registry state, in-memory by default
+	Bus                api.EventBus                     `json:"Bus"`                // Custom event bus
+	TracerProvider     trace.TracerProvider             `json:"TracerProvider"`     // Spans of task and plan executions, the global otel provider by default
 }
 
 // NewNullLogger creates a logger that discards all log output.
@@ -69,12 +79,20 @@ func NewDefaultCore() *Core {
 		MonitorControllers: opts.MonitorControllers,
 		Tasks:              opts.Tasks,
 		Plans:              opts.Plans,
+		Executions:         opts.Executions,
+		Storage:            opts.Storage,
+		Bus:                opts.Bus,
 	}
 	return c
 }
 
 // NewCore creates a new Core instance with custom configurations.
 // Any nil options will be replaced with default implementations.
+// Default registries restore their state from opt.Storage, so passing the
+// storage of a previous run reloads its components, tasks and plans.
+// Tasks and plans the previous run left in progress are marked interrupted;
+// Core.Recover applies a recovery policy to them once the controllers are
+// registered.
 //
 // Parameters:
 //   - opt: CoreOptions containing custom configurations
@@ -82,6 +100,7 @@ func NewDefaultCore() *Core {
 // Returns:
 // *Core - initialized core instance with merged custom and default configurations
 func NewCore(opt CoreOptions) *Core {
+	restored := opt.Storage != nil
 	opts := DefaultOpts(opt)
 
 	c := &Core{
@@ -92,6 +111,16 @@ func NewCore(opt CoreOptions) *Core {
 		MonitorControllers: opts.MonitorControllers,
 		Tasks:              opts.Tasks,
 		Plans:              opts.Plans,
+		Executions:         opts.Executions,
+		Storage:            opts.Storage,
+		Bus:                opts.Bus,
+	}
+	// Прерванные выполнения только помечаются: без контроллеров их нельзя
+	// ни продолжить, ни откатить
+	if restored {
+		if err := c.Recover(model.RecoverNone); err != nil {
+			c.Logger.Errorf("NewCore: failed to detect interrupted runs: %v", err)
+		}
 	}
 	return c
 }
@@ -105,9 +134,16 @@ func NewCore(opt CoreOptions) *Core {
 // Returns:
 // CoreOptions - complete options with defaults filled in
 func DefaultOpts(opt CoreOptions) CoreOptions {
+	var err error
 	if opt.Logger == nil {
 		opt.Logger = NewNullLogger()
 	}
+	if opt.Storage == nil {
+		opt.Storage = storage.NewMemoryStorage()
+	}
+	if opt.Bus == nil {
+		opt.Bus = NewEventBus(EventBusOptions{Logger: opt.Logger})
+	}
 	if opt.Controllers == nil {
 		controllerOpts := ControllerRegistryOptions{
 			Logger: opt.Logger,
@@ -125,15 +161,31 @@ func DefaultOpts(opt CoreOptions) CoreOptions {
 		componentOpts := ComponentRegistryOptions{
 			Logger:      opt.Logger,
 			Controllers: opt.Controllers,
+			Storage:     opt.Storage,
+			Bus:         opt.Bus,
+		}
+		opt.Components, err = NewComponentRegistry(componentOpts)
+		if err != nil {
+			opt.Logger.Errorf("DefaultOpts: failed to restore components: %v", err)
 		}
-		opt.Components, _ = NewComponentRegistry(componentOpts)
 	}
 	if opt.Monitorings == nil {
 		monitoringOpts := MonitoringRegistryOptions{
 			Logger:      opt.Logger,
 			Controllers: opt.MonitorControllers,
+			Storage:     opt.Storage,
+			Bus:         opt.Bus,
+		}
+		opt.Monitorings, err = NewMonitoringRegistry(monitoringOpts)
+		if err != nil {
+			opt.Logger.Errorf("DefaultOpts: failed to restore monitorings: %v", err)
+		}
+	}
+	if opt.Executions == nil {
+		executionOpts := ExecutionRegistryOptions{
+			Logger: opt.Logger,
 		}
-		opt.Monitorings, _ = NewMonitoringRegistry(monitoringOpts)
+		opt.Executions, _ = NewExecutionRegistry(executionOpts)
 	}
 	if opt.Tasks == nil {
 		taskOpts := TaskRegistryOptions{
@@ -142,16 +194,31 @@ func DefaultOpts(opt CoreOptions) CoreOptions {
 			Controllers:        opt.Controllers,
 			Monitoring:         opt.Monitorings,
 			MonitorControllers: opt.MonitorControllers,
+			Executions:         opt.Executions,
+			Storage:            opt.Storage,
+			Bus:                opt.Bus,
+			TracerProvider:     opt.TracerProvider,
+		}
+		opt.Tasks, err = NewTaskRegistry(taskOpts)
+		if err != nil {
+			opt.Logger.Errorf("DefaultOpts: failed to restore tasks: %v", err)
 		}
-		opt.Tasks, _ = NewTaskRegistry(taskOpts)
 	}
 	if opt.Plans == nil {
 		planOpts := PlanRegistryOptions{
-			Logger:     opt.Logger,
-			Components: opt.Components,
-			Tasks:      opt.Tasks,
+			Logger:         opt.Logger,
+			Components:     opt.Components,
+			Controllers:    opt.Controllers,
+			Tasks:          opt.Tasks,
+			Executions:     opt.Executions,
+			Storage:        opt.Storage,
+			Bus:            opt.Bus,
+			TracerProvider: opt.TracerProvider,
+		}
+		opt.Plans, err = NewPlanRegistry(planOpts)
+		if err != nil {
+			opt.Logger.Errorf("DefaultOpts: failed to restore plans: %v", err)
 		}
-		opt.Plans, _ = NewPlanRegistry(planOpts)
 	}
 	return opt
 }
@@ -171,3 +238,45 @@ func isValidTaskType(t model.TaskType) bool {
 		return false
 	}
 }
+
+// isValidDepensType checks if a dependency type is supported; the empty type
+// stands for model.Strict.
+func isValidDepensType(t model.DepensType) bool {
+	switch t {
+	case model.Strict, model.Ordered, model.Advisory, model.Blocking, "":
+		return true
+	default:
+		return false
+	}
+}
+
+// normalizeDepends returns a copy of the dependencies with the empty type
+// replaced by model.Strict.
+func normalizeDepends(depends []model.Depends) []model.Depends {
+	if depends == nil {
+		return nil
+	}
+	result := make([]model.Depends, len(depends))
+	for i, d := range depends {
+		if d.Type == "" {
+			d.Type = model.Strict
+		}
+		result[i] = d
+	}
+	return result
+}
+
+// validateFailurePolicy checks the failure policy of a multi-component task.
+func validateFailurePolicy(policy model.FailurePolicy, minSuccessRatio float64) error {
+	switch policy {
+	case model.FailFast, model.ContinueOnError, "":
+		return nil
+	case model.MinSuccessRatio:
+		if minSuccessRatio <= 0 || minSuccessRatio > 1 {
+			return fmt.Errorf("invalid min success ratio %v, expected (0, 1]", minSuccessRatio)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid failure policy '%s'", policy)
+	}
+}
@@ -0,0 +1,213 @@
+package inforo_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- recording controller: counts calls per component, fails on "bad" ---
+type recordingController struct {
+	mockController
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *recordingController) RunTask(t map[string]string, p map[string]string) error {
+	r.mu.Lock()
+	r.calls[p["name"]]++
+	r.mu.Unlock()
+
+	if p["name"] == "bad" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+
+func (r *recordingController) Calls(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[name]
+}
+
+func setupRecordingCore(t *testing.T) (*inforo.Core, *recordingController) {
+	c := inforo.NewDefaultCore()
+	ctl := &recordingController{calls: make(map[string]int)}
+	require.NoError(t, c.Controllers.Register("recording", ctl))
+	for _, id := range []string{"bad", "good", "other"} {
+		_, err := c.Components.Register(&model.Component{
+			ID:       id,
+			Type:     "recording",
+			Version:  "1.0.0",
+			Metadata: map[string]string{"name": id},
+		})
+		require.NoError(t, err)
+	}
+	return c, ctl
+}
+
+func TestPlanDepends_StrictCascades(t *testing.T) {
+	c, ctl := setupRecordingCore(t)
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"bad"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"good"},
+			DependsOn: []model.Depends{{Type: model.Strict, ID: "task-1"}}},
+		{ID: "task-3", Type: model.UpdateTask, Components: []string{"other"},
+			DependsOn: []model.Depends{{Type: model.Advisory, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+
+	_, err = c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "task task-2 skipped: strict dependency task-1 did not succeed")
+
+	// Strict-зависимая задача не запускалась, advisory — выполнилась
+	assert.Equal(t, 0, ctl.Calls("good"))
+	assert.Equal(t, 1, ctl.Calls("other"))
+
+	skipped, err := c.Tasks.Get("task-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSkipped, skipped.StatusHistory.LastStatus)
+	assert.Equal(t, 1, eventsContaining(skipped, "Skipped: strict dependency task-1 did not succeed"))
+
+	status, _ := c.Plans.Status(plan.ID)
+	assert.Equal(t, model.StatusFailed, status)
+}
+
+func TestPlanDepends_DefaultIsStrict(t *testing.T) {
+	c, _ := setupRecordingCore(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"good"}})
+	require.NoError(t, err)
+	task, err := c.Tasks.Register(&model.Task{ID: "task-2", Type: model.UpdateTask, Components: []string{"good"},
+		DependsOn: []model.Depends{{ID: "task-1"}}})
+	require.NoError(t, err)
+	assert.Equal(t, model.Strict, task.DependsOn[0].Type)
+
+	_, err = c.Tasks.Register(&model.Task{ID: "task-3", Type: model.UpdateTask, Components: []string{"good"},
+		DependsOn: []model.Depends{{Type: "optional", ID: "task-1"}}})
+	assert.EqualError(t, err, "invalid dependency type 'optional' for 'task-1'")
+}
+
+func TestForkDepends(t *testing.T) {
+	tests := []struct {
+		name       string
+		depends    model.DepensType
+		dependency string
+		wantErr    string
+		wantCalls  int
+	}{
+		{name: "strict runs dependency", depends: model.Strict, dependency: "good", wantCalls: 1},
+		{name: "strict fails with dependency", depends: model.Strict, dependency: "bad", wantCalls: 1,
+			wantErr: "strict dependency task-1 failed: deploy failed"},
+		{name: "ordered ignores outcome", depends: model.Ordered, dependency: "bad", wantCalls: 1},
+		{name: "advisory ignores failure", depends: model.Advisory, dependency: "bad", wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ctl := setupRecordingCore(t)
+			_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{tt.dependency}})
+			require.NoError(t, err)
+			_, err = c.Tasks.Register(&model.Task{ID: "task-2", Type: model.UpdateTask, Components: []string{"other"},
+				DependsOn: []model.Depends{{Type: tt.depends, ID: "task-1"}}})
+			require.NoError(t, err)
+
+			_, err = c.Tasks.Fork("task-2", "")
+			assert.Equal(t, tt.wantCalls, ctl.Calls(tt.dependency))
+
+			task, _ := c.Tasks.Get("task-2")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Equal(t, model.StatusFailed, task.StatusHistory.LastStatus)
+				assert.Equal(t, 0, ctl.Calls("other"))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+		})
+	}
+}
+
+func TestForkDepends_StrictSkipsSucceeded(t *testing.T) {
+	c, ctl := setupRecordingCore(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"good"}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "task-2", Type: model.UpdateTask, Components: []string{"other"},
+		DependsOn: []model.Depends{{Type: model.Strict, ID: "task-1"}}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+	_, err = c.Tasks.Fork("task-2", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctl.Calls("good"))
+}
+
+func TestForkDepends_BlockingNotRunning(t *testing.T) {
+	c, ctl := setupRecordingCore(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"bad"}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "task-2", Type: model.UpdateTask, Components: []string{"other"},
+		DependsOn: []model.Depends{{Type: model.Blocking, ID: "task-1"}}})
+	require.NoError(t, err)
+
+	// Незапущенную зависимость не ждём: её никто не запустит
+	_, err = c.Tasks.Fork("task-2", "")
+	assert.EqualError(t, err, "blocking dependency task-1 is not running")
+
+	// Статус прошлого выполнения зависимости тоже не в счёт
+	_, err = c.Tasks.Fork("task-1", "")
+	require.Error(t, err)
+	_, err = c.Tasks.Fork("task-2", "")
+	assert.EqualError(t, err, "blocking dependency task-1 is not running")
+	assert.Equal(t, 1, ctl.Calls("bad"))
+	assert.Equal(t, 0, ctl.Calls("other"))
+}
+
+func TestPlanDepends_BlockingRunsAfterDependency(t *testing.T) {
+	c, ctl := setupRecordingCore(t)
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"bad"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"other"},
+			DependsOn: []model.Depends{{Type: model.Blocking, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+
+	// В плане зависимость уже отработала в этом выполнении, её результат не важен
+	_, err = c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+	assert.Equal(t, 1, ctl.Calls("bad"))
+	assert.Equal(t, 1, ctl.Calls("other"))
+}
+
+func TestForkDepends_BlockingWaitsForRunning(t *testing.T) {
+	c, ctl, _ := setupSteppingPlan(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "task-3", Type: model.UpdateTask, Components: []string{"second"},
+		DependsOn: []model.Depends{{Type: model.Blocking, ID: "task-1"}}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.ForkAsync("task-1", "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	_, err = c.Tasks.ForkAsync("task-3", "")
+	require.NoError(t, err)
+	select {
+	case name := <-ctl.started:
+		t.Fatalf("task on component %s started before blocking dependency finished", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctl.release <- struct{}{}
+	waitStarted(t, ctl, "second")
+	ctl.release <- struct{}{}
+	assert.Equal(t, 1, ctl.Calls("first"))
+}
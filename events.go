@@ -17,6 +17,8 @@ func (e *Events) AddEvent(events *model.EventHistory, message string) {
 		events.Event = make([]model.Event, 0)
 	}
 
+	events.MU.Lock()
+	defer events.MU.Unlock()
 	events.Event = append(events.Event, model.Event{
 		Timestamp: time.Now(),
 		Message:   message,
@@ -14,7 +14,7 @@ func main() {
 	core := inforo.NewDefaultCore()
 
 	// 2. Создание и регистрация компонента
-	component := model.Component{
+	component := &model.Component{
 		ID:      "web-server",
 		Name:    "NGINX",
 		Type:    "webserver",
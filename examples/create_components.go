@@ -9,7 +9,7 @@ import (
 
 func main() {
 	core := inforo.NewDefaultCore()
-	component := model.Component{
+	component := &model.Component{
 		ID:       "",
 		Name:     "",
 		Type:     "",
@@ -0,0 +1,283 @@
+package inforo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxFinishedExecutions is how many finished executions the registry
+// keeps unless configured otherwise.
+const DefaultMaxFinishedExecutions = 1000
+
+// ExecutionRegistry keeps track of task, rollback and plan executions by
+// executionID, so that IDs returned by the async methods can be looked up
+// and waited on afterwards. Finished executions are kept within the
+// retention limits; executions in progress are always kept.
+type ExecutionRegistry struct {
+	executions  map[string]*executionEntry
+	mu          *sync.RWMutex
+	logger      *logrus.Logger
+	maxFinished int
+	retention   time.Duration
+}
+
+type ExecutionRegistryOptions struct {
+	Logger *logrus.Logger
+	// MaxFinished — сколько завершённых выполнений хранить: самые старые
+	// удаляются. DefaultMaxFinishedExecutions, если 0; без ограничения, если < 0
+	MaxFinished int
+	// Retention — сколько хранить завершённое выполнение; 0 — без ограничения
+	Retention time.Duration
+}
+
+// executionEntry pairs a record with the channel closed when it finishes.
+type executionEntry struct {
+	execution *model.Execution
+	done      chan struct{}
+	// reserved — запись создана async-методом и ещё не занята исполнителем
+	reserved bool
+}
+
+func NewExecutionRegistry(opts ExecutionRegistryOptions) (api.ExecutionRegistry, error) {
+	if opts.Logger == nil {
+		opts.Logger = NewNullLogger()
+	}
+	if opts.MaxFinished == 0 {
+		opts.MaxFinished = DefaultMaxFinishedExecutions
+	}
+	return &ExecutionRegistry{
+		executions:  make(map[string]*executionEntry),
+		mu:          &sync.RWMutex{},
+		logger:      opts.Logger,
+		maxFinished: opts.MaxFinished,
+		retention:   opts.Retention,
+	}, nil
+}
+
+// Reserve creates a pending record for an execution that is about to be
+// started in the background, so that it can be queried right away.
+func (er *ExecutionRegistry) Reserve(id string, kind model.ExecutionKind, planID string, taskID string) error {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	if entry, exists := er.executions[id]; exists && !isFinished(entry) {
+		return errors.New("execution already exists")
+	}
+
+	entry := newExecutionEntry(id, kind, planID, taskID, model.StatusPending)
+	entry.reserved = true
+	er.executions[id] = entry
+	er.logger.Debugf("[%s] ExecutionRegistry.Reserve() - kind: %s", id, kind)
+	return nil
+}
+
+// Start marks the execution as running. It returns true when the caller owns
+// the execution and is responsible for calling Finish; nested calls that
+// join an execution already in progress (tasks run by a plan, dependencies
+// triggered by Fork) only add their task to it and get false.
+func (er *ExecutionRegistry) Start(id string, kind model.ExecutionKind, planID string, taskID string) bool {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	entry, exists := er.executions[id]
+	switch {
+	case !exists || isFinished(entry):
+		er.executions[id] = newExecutionEntry(id, kind, planID, taskID, model.StatusRunning)
+		er.logger.Debugf("[%s] ExecutionRegistry.Start() - kind: %s", id, kind)
+		return true
+	case entry.reserved:
+		entry.reserved = false
+		entry.execution.Status = model.StatusRunning
+		entry.execution.StartedAt = time.Now()
+		er.logger.Debugf("[%s] ExecutionRegistry.Start() - claimed reserved execution", id)
+		return true
+	default:
+		if taskID != "" && !contains(entry.execution.TaskIDs, taskID) {
+			entry.execution.TaskIDs = append(entry.execution.TaskIDs, taskID)
+		}
+		return false
+	}
+}
+
+// Finish records the final status of the execution and releases waiters.
+func (er *ExecutionRegistry) Finish(id string, status model.Status, err error) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	entry, exists := er.executions[id]
+	if !exists || isFinished(entry) {
+		return
+	}
+
+	entry.reserved = false
+	entry.execution.Status = status
+	entry.execution.FinishedAt = time.Now()
+	if err != nil {
+		entry.execution.Error = err.Error()
+	}
+	close(entry.done)
+	er.logger.Debugf("[%s] ExecutionRegistry.Finish() - status: %s", id, status)
+	er.prune()
+}
+
+// prune removes the finished executions beyond the retention limits. The
+// caller holds the write lock.
+func (er *ExecutionRegistry) prune() {
+	finished := make([]*executionEntry, 0)
+	for id, entry := range er.executions {
+		if !isFinished(entry) {
+			continue
+		}
+		if er.retention > 0 && time.Since(entry.execution.FinishedAt) > er.retention {
+			delete(er.executions, id)
+			continue
+		}
+		finished = append(finished, entry)
+	}
+	if er.maxFinished < 0 || len(finished) <= er.maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].execution.FinishedAt.Before(finished[j].execution.FinishedAt)
+	})
+	for _, entry := range finished[:len(finished)-er.maxFinished] {
+		delete(er.executions, entry.execution.ID)
+	}
+}
+
+func (er *ExecutionRegistry) Get(id string) (*model.Execution, error) {
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	entry, exists := er.executions[id]
+	if !exists {
+		return nil, errors.New("execution not found")
+	}
+	return snapshotExecution(entry.execution), nil
+}
+
+func (er *ExecutionRegistry) List() ([]*model.Execution, error) {
+	return er.filter(func(e *model.Execution) bool { return true }), nil
+}
+
+// ListByTask returns every execution the task took part in, oldest first.
+func (er *ExecutionRegistry) ListByTask(taskID string) ([]*model.Execution, error) {
+	return er.filter(func(e *model.Execution) bool {
+		return e.TaskID == taskID || contains(e.TaskIDs, taskID)
+	}), nil
+}
+
+// ListByPlan returns every execution of the plan, oldest first.
+func (er *ExecutionRegistry) ListByPlan(planID string) ([]*model.Execution, error) {
+	return er.filter(func(e *model.Execution) bool {
+		return e.PlanID == planID
+	}), nil
+}
+
+// Wait blocks until the execution finishes or ctx is done.
+func (er *ExecutionRegistry) Wait(ctx context.Context, id string) (*model.Execution, error) {
+	er.mu.RLock()
+	entry, exists := er.executions[id]
+	er.mu.RUnlock()
+	if !exists {
+		return nil, errors.New("execution not found")
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+	return snapshotExecution(entry.execution), nil
+}
+
+func (er *ExecutionRegistry) filter(match func(e *model.Execution) bool) []*model.Execution {
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	result := make([]*model.Execution, 0)
+	for _, entry := range er.executions {
+		if match(entry.execution) {
+			result = append(result, snapshotExecution(entry.execution))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+func newExecutionEntry(id string, kind model.ExecutionKind, planID string, taskID string, status model.Status) *executionEntry {
+	execution := &model.Execution{
+		ID:        id,
+		Kind:      kind,
+		PlanID:    planID,
+		TaskID:    taskID,
+		Status:    status,
+		StartedAt: time.Now(),
+	}
+	if taskID != "" {
+		execution.TaskIDs = []string{taskID}
+	}
+	return &executionEntry{
+		execution: execution,
+		done:      make(chan struct{}),
+	}
+}
+
+func isFinished(entry *executionEntry) bool {
+	select {
+	case <-entry.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// snapshotExecution copies a record so callers never share it with the registry.
+func snapshotExecution(e *model.Execution) *model.Execution {
+	return &model.Execution{
+		ID:         e.ID,
+		Kind:       e.Kind,
+		PlanID:     e.PlanID,
+		TaskID:     e.TaskID,
+		TaskIDs:    append([]string(nil), e.TaskIDs...),
+		Status:     e.Status,
+		Error:      e.Error,
+		StartedAt:  e.StartedAt,
+		FinishedAt: e.FinishedAt,
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// executionStatus maps the result of an execution to its final status.
+func executionStatus(ctx context.Context, err error) model.Status {
+	switch {
+	case err == nil:
+		return model.StatusSuccess
+	case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		return model.StatusStopped
+	default:
+		return model.StatusFailed
+	}
+}
@@ -0,0 +1,145 @@
+package inforo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- failing controller ---
+type failingController struct {
+	mockController
+}
+
+func (f *failingController) RunTask(r map[string]string, p map[string]string) error {
+	return errors.New("deploy failed")
+}
+
+func TestExecutions_ForkAsync(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}})
+	require.NoError(t, err)
+
+	executionID, err := c.Tasks.ForkAsync("task-1", "")
+	require.NoError(t, err)
+
+	// Запись доступна сразу после возврата ForkAsync
+	_, err = c.Executions.Get(executionID)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	execution, err := c.Executions.Wait(ctx, executionID)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.TaskExecution, execution.Kind)
+	assert.Equal(t, "task-1", execution.TaskID)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+	assert.False(t, execution.FinishedAt.IsZero())
+
+	byTask, _ := c.Executions.ListByTask("task-1")
+	assert.Len(t, byTask, 1)
+}
+
+func TestExecutions_RecordsFailure(t *testing.T) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("failing", &failingController{}))
+	_, err := c.Components.Register(&model.Component{ID: "component-1", Type: "failing", Version: "1.0.0"})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "exec-1")
+	require.Error(t, err)
+
+	execution, err := c.Executions.Get("exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, execution.Status)
+	assert.Equal(t, "deploy failed", execution.Error)
+}
+
+func TestExecutions_PlanRun(t *testing.T) {
+	c := setupCoreWithComponent()
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"component-1"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+
+	executionID, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	execution, err := c.Executions.Wait(ctx, executionID)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.PlanExecution, execution.Kind)
+	assert.Equal(t, plan.ID, execution.PlanID)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+	assert.ElementsMatch(t, []string{"task-1", "task-2"}, execution.TaskIDs)
+
+	byPlan, _ := c.Executions.ListByPlan(plan.ID)
+	require.Len(t, byPlan, 1)
+	assert.Equal(t, executionID, byPlan[0].ID)
+
+	byTask, _ := c.Executions.ListByTask("task-2")
+	require.Len(t, byTask, 1)
+	assert.Equal(t, executionID, byTask[0].ID)
+}
+
+func TestExecutions_WaitTimeout(t *testing.T) {
+	registry, _ := inforo.NewExecutionRegistry(inforo.ExecutionRegistryOptions{Logger: inforo.NewNullLogger()})
+	require.True(t, registry.Start("exec-1", model.TaskExecution, "", "task-1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := registry.Wait(ctx, "exec-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = registry.Wait(context.Background(), "missing")
+	assert.EqualError(t, err, "execution not found")
+}
+
+func TestExecutions_RetentionLimits(t *testing.T) {
+	executions, err := inforo.NewExecutionRegistry(inforo.ExecutionRegistryOptions{MaxFinished: 2})
+	require.NoError(t, err)
+
+	require.True(t, executions.Start("running", model.TaskExecution, "", "task-1"))
+	for _, id := range []string{"exec-1", "exec-2", "exec-3"} {
+		require.True(t, executions.Start(id, model.TaskExecution, "", "task-1"))
+		executions.Finish(id, model.StatusSuccess, nil)
+	}
+
+	// Самое старое завершённое выполнение удалено, незавершённое осталось
+	_, err = executions.Get("exec-1")
+	assert.EqualError(t, err, "execution not found")
+	list, err := executions.List()
+	require.NoError(t, err)
+	ids := make([]string, 0, len(list))
+	for _, execution := range list {
+		ids = append(ids, execution.ID)
+	}
+	assert.ElementsMatch(t, []string{"running", "exec-2", "exec-3"}, ids)
+
+	byAge, err := inforo.NewExecutionRegistry(inforo.ExecutionRegistryOptions{Retention: 20 * time.Millisecond})
+	require.NoError(t, err)
+	require.True(t, byAge.Start("old", model.PlanExecution, "plan-1", ""))
+	byAge.Finish("old", model.StatusSuccess, nil)
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, byAge.Start("new", model.PlanExecution, "plan-1", ""))
+	byAge.Finish("new", model.StatusSuccess, nil)
+
+	_, err = byAge.Get("old")
+	assert.EqualError(t, err, "execution not found")
+	_, err = byAge.Get("new")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,329 @@
+package manifest
+
+import (
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+)
+
+// Target receives the objects of a manifest. CoreTarget registers them in a
+// Core; other targets, such as a client of the HTTP API, can be plugged in.
+type Target interface {
+	RegisterComponent(comp *model.Component) error
+	RegisterMonitoring(m *model.Monitoring) error
+	RegisterTask(task *model.Task) error
+	// RegisterPlan registers a plan of the tasks and returns its ID
+	RegisterPlan(tasks []*model.Task, maxParallelism int) (string, error)
+}
+
+// Result lists what was registered, in registration order.
+type Result struct {
+	Components  []string
+	Monitorings []string
+	Tasks       []string          // Задачи вне планов
+	Plans       map[string]string // ID зарегистрированных планов по имени
+}
+
+type coreTarget struct {
+	core *inforo.Core
+}
+
+// CoreTarget returns a Target that registers into the core.
+func CoreTarget(core *inforo.Core) Target {
+	return &coreTarget{core: core}
+}
+
+func (t *coreTarget) RegisterComponent(comp *model.Component) error {
+	_, err := t.core.Components.Register(&model.Component{
+		ID:       comp.ID,
+		Name:     comp.Name,
+		Type:     comp.Type,
+		Version:  comp.Version,
+		Metadata: comp.Metadata,
+	})
+	return err
+}
+
+func (t *coreTarget) RegisterMonitoring(m *model.Monitoring) error {
+	_, err := t.core.Monitorings.Register(m.Type, m)
+	return err
+}
+
+func (t *coreTarget) RegisterTask(task *model.Task) error {
+	_, err := t.core.Tasks.Register(task)
+	return err
+}
+
+func (t *coreTarget) RegisterPlan(tasks []*model.Task, maxParallelism int) (string, error) {
+	plan, err := t.core.Plans.Register(tasks)
+	if err != nil {
+		return "", err
+	}
+	if maxParallelism != 0 {
+		if err := t.core.Plans.SetMaxParallelism(plan.ID, "", maxParallelism); err != nil {
+			return plan.ID, err
+		}
+	}
+	return plan.ID, nil
+}
+
+// Apply validates the manifest against the core and registers it. Nothing is
+// registered if validation fails.
+func Apply(core *inforo.Core, m *Manifest) (*Result, error) {
+	if err := m.Validate(core); err != nil {
+		return nil, err
+	}
+	return Register(CoreTarget(core), m)
+}
+
+// Register registers the manifest into the target without validating it:
+// components, monitorings, plans with their tasks, then the other tasks in
+// dependency order. It stops at the first error, which points at the item
+// that failed, and returns what was registered before.
+func Register(target Target, m *Manifest) (*Result, error) {
+	result := &Result{Plans: make(map[string]string)}
+
+	for _, comp := range m.Components {
+		if err := target.RegisterComponent(comp.model()); err != nil {
+			return result, &ValidationError{File: comp.file, Path: comp.path, Message: err.Error()}
+		}
+		result.Components = append(result.Components, comp.ID)
+	}
+	for _, mon := range m.Monitorings {
+		if err := target.RegisterMonitoring(mon.model()); err != nil {
+			return result, &ValidationError{File: mon.file, Path: mon.path, Message: err.Error()}
+		}
+		result.Monitorings = append(result.Monitorings, mon.ID)
+	}
+
+	tasks := make(map[string]*Task, len(m.Tasks))
+	for _, task := range m.Tasks {
+		tasks[task.ID] = task
+	}
+	inPlan := make(map[string]bool)
+	for _, plan := range m.Plans {
+		planTasks := make([]*model.Task, 0, len(plan.Tasks))
+		for _, taskID := range plan.Tasks {
+			inPlan[taskID] = true
+			if task, exists := tasks[taskID]; exists {
+				planTasks = append(planTasks, task.model())
+			}
+		}
+		planID, err := target.RegisterPlan(planTasks, plan.MaxParallelism)
+		if err != nil {
+			return result, &ValidationError{File: plan.file, Path: plan.path, Message: err.Error()}
+		}
+		result.Plans[plan.Name] = planID
+	}
+
+	// Зависимость регистрируется раньше зависящей от неё задачи
+	registered := make(map[string]bool)
+	var register func(task *Task) error
+	register = func(task *Task) error {
+		if registered[task.ID] || inPlan[task.ID] {
+			return nil
+		}
+		registered[task.ID] = true
+		for _, depends := range task.DependsOn {
+			if dependency, exists := tasks[depends.ID]; exists {
+				if err := register(dependency); err != nil {
+					return err
+				}
+			}
+		}
+		if err := target.RegisterTask(task.model()); err != nil {
+			return &ValidationError{File: task.file, Path: task.path, Message: err.Error()}
+		}
+		result.Tasks = append(result.Tasks, task.ID)
+		return nil
+	}
+	for _, task := range m.Tasks {
+		if err := register(task); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// dryRun registers the manifest into a scratch Core that has the controllers
+// of the real one and copies of the objects the manifest refers to.
+func (v *validator) dryRun(m *Manifest) {
+	scratch := inforo.NewDefaultCore()
+
+	copyController := func(componentType string) {
+		if _, err := scratch.Controllers.Get(componentType); err == nil {
+			return
+		}
+		if controller, err := v.core.Controllers.Get(componentType); err == nil {
+			scratch.Controllers.Register(componentType, controller)
+		}
+	}
+	copyComponent := func(id string) {
+		if _, exists := v.components[id]; exists {
+			return
+		}
+		if _, err := scratch.Components.Get(id); err == nil {
+			return
+		}
+		if comp, err := v.core.Components.Get(id); err == nil {
+			copyController(comp.Type)
+			comp.MU.RLock()
+			defer comp.MU.RUnlock()
+			scratch.Components.Register(&model.Component{ID: comp.ID, Name: comp.Name, Type: comp.Type, Version: comp.Version, Metadata: comp.Metadata})
+		}
+	}
+
+	for _, comp := range m.Components {
+		copyController(comp.Type)
+	}
+	for _, mon := range m.Monitorings {
+		if controller, err := v.core.MonitorControllers.Get(mon.Type); err == nil {
+			scratch.MonitorControllers.Register(mon.Type, controller)
+		}
+	}
+	for _, task := range m.Tasks {
+		for _, componentID := range task.Components {
+			copyComponent(componentID)
+		}
+		for _, check := range append(append([]*Check{}, task.PreChecks...), task.PostChecks...) {
+			if _, exists := v.monitorings[check.Monitoring]; exists {
+				continue
+			}
+			if mon, err := v.core.Monitorings.Get(check.Monitoring); err == nil {
+				if controller, err := v.core.MonitorControllers.Get(mon.Type); err == nil {
+					scratch.MonitorControllers.Register(mon.Type, controller)
+				}
+				scratch.Monitorings.Register(mon.Type, &model.Monitoring{ID: mon.ID, Name: mon.Name, Type: mon.Type, Config: mon.Config})
+			}
+		}
+		// Существующие задачи, от которых зависят задачи манифеста
+		for _, depends := range task.DependsOn {
+			if _, exists := v.tasks[depends.ID]; exists {
+				continue
+			}
+			if existing, err := v.core.Tasks.Get(depends.ID); err == nil {
+				existing.MU.RLock()
+				copied := &model.Task{ID: existing.ID, Type: existing.Type, Components: existing.Components, Metadata: map[string]string{}}
+				existing.MU.RUnlock()
+				for _, componentID := range copied.Components {
+					copyComponent(componentID)
+				}
+				scratch.Tasks.Register(copied)
+			}
+		}
+	}
+
+	if _, err := Register(CoreTarget(scratch), m); err != nil {
+		if verr, ok := err.(*ValidationError); ok {
+			v.errs = append(v.errs, verr)
+			return
+		}
+		v.errs = append(v.errs, &ValidationError{Message: err.Error()})
+	}
+}
+
+func (c *Component) model() *model.Component {
+	return &model.Component{
+		ID:       c.ID,
+		Name:     c.Name,
+		Type:     c.Type,
+		Version:  c.Version,
+		Metadata: c.Metadata,
+	}
+}
+
+func (m *Monitoring) model() *model.Monitoring {
+	return &model.Monitoring{
+		ID:     m.ID,
+		Name:   m.Name,
+		Type:   m.Type,
+		Config: m.Config,
+	}
+}
+
+func (t *Task) model() *model.Task {
+	task := &model.Task{
+		ID:              t.ID,
+		Name:            t.Name,
+		Type:            model.TaskType(t.Type),
+		Components:      t.Components,
+		PreChecks:       checksModel(t.PreChecks),
+		PostChecks:      checksModel(t.PostChecks),
+		Retry:           t.Retry.model(),
+		FailurePolicy:   model.FailurePolicy(t.FailurePolicy),
+		MinSuccessRatio: t.MinSuccessRatio,
+		Metadata:        t.Metadata,
+	}
+	if task.Metadata == nil {
+		task.Metadata = map[string]string{}
+	}
+	for _, depends := range t.DependsOn {
+		task.DependsOn = append(task.DependsOn, model.Depends{ID: depends.ID, Type: model.DepensType(depends.Type)})
+	}
+	if t.RollBack != nil {
+		task.RollBack = &model.Rollback{Type: model.RollBackType(t.RollBack.Type), Metadata: t.RollBack.Metadata}
+	}
+	if t.Strategy != nil {
+		task.Strategy = &model.RolloutStrategy{
+			Type:         model.RolloutType(t.Strategy.Type),
+			BatchSize:    t.Strategy.BatchSize,
+			BatchPercent: t.Strategy.BatchPercent,
+			CanarySize:   t.Strategy.CanarySize,
+			Pause:        parseDuration(t.Strategy.Pause),
+		}
+	}
+	return task
+}
+
+func checksModel(checks []*Check) []*model.Check {
+	if checks == nil {
+		return nil
+	}
+	result := make([]*model.Check, 0, len(checks))
+	for _, c := range checks {
+		check := &model.Check{
+			ID:               c.ID,
+			Name:             c.Name,
+			MonitoringID:     c.Monitoring,
+			Severity:         model.CheckSeverity(c.Severity),
+			Retry:            c.Retry.model(),
+			Mode:             model.CheckMode(c.Mode),
+			Interval:         parseDuration(c.Interval),
+			Duration:         parseDuration(c.Duration),
+			SuccessThreshold: c.SuccessThreshold,
+			FailureThreshold: c.FailureThreshold,
+			Metadata:         c.Metadata,
+		}
+		if check.Metadata == nil {
+			check.Metadata = map[string]string{}
+		}
+		result = append(result, check)
+	}
+	return result
+}
+
+func (r *Retry) model() *model.RetryPolicy {
+	if r == nil {
+		return nil
+	}
+	return &model.RetryPolicy{
+		MaxAttempts: r.MaxAttempts,
+		Backoff:     model.BackoffType(r.Backoff),
+		Delay:       parseDuration(r.Delay),
+		MaxDelay:    parseDuration(r.MaxDelay),
+		Multiplier:  r.Multiplier,
+		Jitter:      r.Jitter,
+		RetryOn:     r.RetryOn,
+	}
+}
+
+// parseDuration converts a duration validated by Validate; invalid values
+// are zero.
+func parseDuration(value string) model.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return model.Duration(d)
+}
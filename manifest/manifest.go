@@ -0,0 +1,177 @@
+// Package manifest loads a declarative description of a rollout — components,
+// monitorings, tasks and plans — from YAML or JSON files into a Core.
+//
+// A manifest is validated as a whole before anything is registered: field
+// values, references between its items and, when a Core is given, the
+// controllers and existing objects of that Core. Every problem is reported
+// with the file, the path of the item and the field.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the manifest format version this package reads.
+const Version = "v1"
+
+// Manifest is the content of one or more manifest files.
+type Manifest struct {
+	Version     string        `yaml:"version" json:"version"`
+	Components  []*Component  `yaml:"components,omitempty" json:"components,omitempty"`
+	Monitorings []*Monitoring `yaml:"monitorings,omitempty" json:"monitorings,omitempty"`
+	Tasks       []*Task       `yaml:"tasks,omitempty" json:"tasks,omitempty"`
+	Plans       []*Plan       `yaml:"plans,omitempty" json:"plans,omitempty"`
+}
+
+type Component struct {
+	ID       string            `yaml:"id" json:"id"`
+	Name     string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Type     string            `yaml:"type" json:"type"`
+	Version  string            `yaml:"version" json:"version"`
+	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	file     string
+	path     string
+}
+
+type Monitoring struct {
+	ID     string            `yaml:"id" json:"id"`
+	Name   string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Type   string            `yaml:"type" json:"type"`
+	Config map[string]string `yaml:"config,omitempty" json:"config,omitempty"`
+	file   string
+	path   string
+}
+
+// Task describes a task. Tasks listed in a plan are registered with the
+// plan, the others on their own.
+type Task struct {
+	ID              string            `yaml:"id" json:"id"`
+	Name            string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Type            string            `yaml:"type" json:"type"`
+	Components      []string          `yaml:"components" json:"components"`
+	DependsOn       []*Depends        `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	PreChecks       []*Check          `yaml:"preChecks,omitempty" json:"preChecks,omitempty"`
+	PostChecks      []*Check          `yaml:"postChecks,omitempty" json:"postChecks,omitempty"`
+	RollBack        *RollBack         `yaml:"rollBack,omitempty" json:"rollBack,omitempty"`
+	Retry           *Retry            `yaml:"retry,omitempty" json:"retry,omitempty"`
+	FailurePolicy   string            `yaml:"failurePolicy,omitempty" json:"failurePolicy,omitempty"`
+	MinSuccessRatio float64           `yaml:"minSuccessRatio,omitempty" json:"minSuccessRatio,omitempty"`
+	Strategy        *Strategy         `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Metadata        map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	file            string
+	path            string
+}
+
+type Depends struct {
+	ID   string `yaml:"id" json:"id"`
+	Type string `yaml:"type,omitempty" json:"type,omitempty"` // strict по умолчанию
+}
+
+type Check struct {
+	ID               string            `yaml:"id" json:"id"`
+	Name             string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Monitoring       string            `yaml:"monitoring" json:"monitoring"`
+	Severity         string            `yaml:"severity,omitempty" json:"severity,omitempty"`
+	Mode             string            `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Interval         string            `yaml:"interval,omitempty" json:"interval,omitempty"` // Длительность вида "30s"
+	Duration         string            `yaml:"duration,omitempty" json:"duration,omitempty"`
+	SuccessThreshold int               `yaml:"successThreshold,omitempty" json:"successThreshold,omitempty"`
+	FailureThreshold int               `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
+	Retry            *Retry            `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Metadata         map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+type RollBack struct {
+	Type     string            `yaml:"type" json:"type"`
+	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+type Retry struct {
+	MaxAttempts int      `yaml:"maxAttempts" json:"maxAttempts"`
+	Backoff     string   `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+	Delay       string   `yaml:"delay,omitempty" json:"delay,omitempty"`
+	MaxDelay    string   `yaml:"maxDelay,omitempty" json:"maxDelay,omitempty"`
+	Multiplier  float64  `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`
+	Jitter      float64  `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+	RetryOn     []string `yaml:"retryOn,omitempty" json:"retryOn,omitempty"`
+}
+
+type Strategy struct {
+	Type         string `yaml:"type" json:"type"`
+	BatchSize    int    `yaml:"batchSize,omitempty" json:"batchSize,omitempty"`
+	BatchPercent int    `yaml:"batchPercent,omitempty" json:"batchPercent,omitempty"`
+	CanarySize   int    `yaml:"canarySize,omitempty" json:"canarySize,omitempty"`
+	Pause        string `yaml:"pause,omitempty" json:"pause,omitempty"`
+}
+
+// Plan groups tasks of the manifest by ID.
+type Plan struct {
+	Name           string   `yaml:"name" json:"name"`
+	MaxParallelism int      `yaml:"maxParallelism,omitempty" json:"maxParallelism,omitempty"`
+	Tasks          []string `yaml:"tasks" json:"tasks"`
+	file           string
+	path           string
+}
+
+// Parse reads a manifest from data. The name is used in error messages.
+// JSON is read as YAML, of which it is a subset.
+func Parse(name string, data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	// Запоминаем, где описан каждый элемент, — для сообщений об ошибках
+	for i, comp := range m.Components {
+		comp.file, comp.path = name, fmt.Sprintf("components[%d]", i)
+	}
+	for i, mon := range m.Monitorings {
+		mon.file, mon.path = name, fmt.Sprintf("monitorings[%d]", i)
+	}
+	for i, task := range m.Tasks {
+		task.file, task.path = name, fmt.Sprintf("tasks[%d]", i)
+	}
+	for i, plan := range m.Plans {
+		plan.file, plan.path = name, fmt.Sprintf("plans[%d]", i)
+	}
+	return m, nil
+}
+
+// Load reads and merges the manifest files. Each file must declare the same
+// version.
+func Load(files ...string) (*Manifest, error) {
+	merged := &Manifest{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		m, err := Parse(file, data)
+		if err != nil {
+			return nil, err
+		}
+		if m.Version != Version {
+			return nil, &ValidationError{File: file, Field: "version",
+				Message: fmt.Sprintf("unsupported version %q, expected %q", m.Version, Version)}
+		}
+		merged.Merge(m)
+	}
+	merged.Version = Version
+	return merged, nil
+}
+
+// Merge appends the items of other to the manifest.
+func (m *Manifest) Merge(other *Manifest) {
+	m.Components = append(m.Components, other.Components...)
+	m.Monitorings = append(m.Monitorings, other.Monitorings...)
+	m.Tasks = append(m.Tasks, other.Tasks...)
+	m.Plans = append(m.Plans, other.Plans...)
+}
@@ -0,0 +1,234 @@
+package manifest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/manifest"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- kuber controller: a component needs a namespace ---
+type kuberController struct{}
+
+func (k *kuberController) RunTask(r map[string]string, p map[string]string) error { return nil }
+func (k *kuberController) ValideTask(r map[string]string) error                   { return nil }
+func (k *kuberController) ValideComponent(m map[string]string) error {
+	if m["namespace"] == "" {
+		return errors.New("namespace is required")
+	}
+	return nil
+}
+func (k *kuberController) CheckComponent(m map[string]string) error { return nil }
+
+type promController struct{}
+
+func (p *promController) RunCheck(m map[string]string) error           { return nil }
+func (p *promController) CheckMonitoring(c map[string]string) error    { return nil }
+func (p *promController) ValidateCheck(m map[string]string) error      { return nil }
+func (p *promController) ValidateMonitoring(c map[string]string) error { return nil }
+
+func newCore(t *testing.T) *inforo.Core {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("kuber", &kuberController{}))
+	require.NoError(t, c.MonitorControllers.Register("prometheus", &promController{}))
+	return c
+}
+
+// validationErrors returns the messages of a Validate error.
+func validationErrors(t *testing.T, err error) []string {
+	var errs manifest.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return messages
+}
+
+func TestLoad_YAML(t *testing.T) {
+	m, err := manifest.Load("testdata/release.yaml")
+	require.NoError(t, err)
+	require.Len(t, m.Components, 2)
+	require.Len(t, m.Tasks, 3)
+	require.Len(t, m.Plans, 1)
+
+	deploy := m.Tasks[1]
+	assert.Equal(t, "deploy-api", deploy.ID)
+	assert.Equal(t, "migrate", deploy.DependsOn[0].ID)
+	assert.Equal(t, "1m", deploy.PostChecks[0].Duration)
+	assert.Equal(t, "trigger", deploy.RollBack.Type)
+	assert.Equal(t, 2, m.Plans[0].MaxParallelism)
+
+	require.NoError(t, m.Validate(newCore(t)))
+}
+
+func TestParse_JSON(t *testing.T) {
+	m, err := manifest.Parse("release.json", []byte(`{
+  "version": "v1",
+  "components": [{"id": "api", "type": "kuber", "version": "1.0.0", "metadata": {"namespace": "prod"}}],
+  "tasks": [{"id": "deploy", "type": "update", "components": ["api"]}]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, "api", m.Components[0].ID)
+	require.NoError(t, m.Validate(newCore(t)))
+}
+
+func TestParse_UnknownField(t *testing.T) {
+	_, err := manifest.Parse("x.yaml", []byte("version: v1\ncomponents:\n  - id: api\n    image: api:1.0\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x.yaml:")
+	assert.Contains(t, err.Error(), "field image not found")
+}
+
+func TestLoad_UnsupportedVersion(t *testing.T) {
+	_, err := manifest.Load("testdata/v2.yaml")
+	require.EqualError(t, err, `testdata/v2.yaml: version: unsupported version "v2", expected "v1"`)
+}
+
+func TestValidate_CrossReferences(t *testing.T) {
+	m, err := manifest.Parse("x.yaml", []byte(`
+version: v1
+components:
+  - {id: api, type: kuber, version: 1.0.0, metadata: {namespace: prod}}
+  - {id: api, type: helm, version: 1.0.0}
+tasks:
+  - id: migrate
+    type: update
+    components: [api]
+  - id: deploy
+    type: upgrade
+    components: [api, db]
+    dependsOn: [{id: foo}]
+    postChecks:
+      - {id: errors, monitoring: grafana, mode: soak, interval: 1m, duration: 10s}
+  - id: notify
+    type: check
+    components: [api]
+    dependsOn: [{id: migrate}]
+plans:
+  - name: release
+    tasks: [deploy, notify, cleanup]
+`))
+	require.NoError(t, err)
+
+	err = m.Validate(newCore(t))
+	assert.Equal(t, []string{
+		`x.yaml: components[1].id: duplicate component "api", first defined in x.yaml components[0]`,
+		`x.yaml: components[1].type: no controller for component type "helm"`,
+		`x.yaml: plans[0].tasks[2]: task "cleanup" is not defined`,
+		`x.yaml: tasks[1].type: invalid task type "upgrade"`,
+		`x.yaml: tasks[1].components[1]: component "db" is not defined`,
+		`x.yaml: tasks[1].dependsOn[0].id: task "foo" is not defined`,
+		`x.yaml: tasks[1].postChecks[0].monitoring: monitoring "grafana" is not defined`,
+		`x.yaml: tasks[1].postChecks[0].duration: soak duration must not be shorter than its interval`,
+		`x.yaml: tasks[2].dependsOn[0].id: task "migrate" is not in plan "release"`,
+	}, validationErrors(t, err))
+}
+
+func TestValidate_Cycle(t *testing.T) {
+	m, err := manifest.Parse("x.yaml", []byte(`
+version: v1
+tasks:
+  - {id: a, type: update, components: [api], dependsOn: [{id: c}]}
+  - {id: b, type: update, components: [api], dependsOn: [{id: a}]}
+  - {id: c, type: update, components: [api], dependsOn: [{id: b}]}
+`))
+	require.NoError(t, err)
+
+	err = m.Validate(nil)
+	assert.Equal(t, []string{"x.yaml: tasks[1].dependsOn: dependency cycle a -> c -> b -> a"}, validationErrors(t, err))
+}
+
+func TestValidate_DryRunCatchesRegistryError(t *testing.T) {
+	c := newCore(t)
+	m, err := manifest.Parse("x.yaml", []byte(`
+version: v1
+components:
+  - {id: api, type: kuber, version: 1.0.0}
+`))
+	require.NoError(t, err)
+
+	// Без core проверяются только поля и ссылки
+	require.NoError(t, m.Validate(nil))
+
+	err = m.Validate(c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x.yaml: components[0]: ")
+	assert.Contains(t, err.Error(), "namespace is required")
+
+	// Ничего не зарегистрировано
+	_, err = c.Components.Get("api")
+	assert.Error(t, err)
+}
+
+func TestValidate_ExistingObjects(t *testing.T) {
+	c := newCore(t)
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "kuber", Version: "1.0.0", Metadata: map[string]string{"namespace": "prod"}})
+	require.NoError(t, err)
+
+	m, err := manifest.Parse("x.yaml", []byte(`
+version: v1
+tasks:
+  - {id: deploy, type: update, components: [api]}
+`))
+	require.NoError(t, err)
+	require.NoError(t, m.Validate(c))
+
+	m, err = manifest.Parse("x.yaml", []byte(`
+version: v1
+components:
+  - {id: api, type: kuber, version: 1.0.0, metadata: {namespace: prod}}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`x.yaml: components[0].id: component "api" is already registered`},
+		validationErrors(t, m.Validate(c)))
+}
+
+func TestApply_RegistersManifest(t *testing.T) {
+	c := newCore(t)
+	m, err := manifest.Load("testdata/release.yaml")
+	require.NoError(t, err)
+
+	result, err := manifest.Apply(c, m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "worker"}, result.Components)
+	assert.Equal(t, []string{"prometheus"}, result.Monitorings)
+	assert.Empty(t, result.Tasks)
+	require.Contains(t, result.Plans, "release-1.5")
+
+	plan, err := c.Plans.Get(result.Plans["release-1.5"])
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.MaxParallelism)
+
+	deploy, err := c.Tasks.Get("deploy-api")
+	require.NoError(t, err)
+	assert.Equal(t, model.Duration(time.Minute), deploy.PostChecks[0].Duration)
+	assert.Equal(t, model.DepensType("strict"), deploy.DependsOn[0].Type)
+
+	worker, err := c.Tasks.Get("deploy-worker")
+	require.NoError(t, err)
+	assert.Equal(t, model.RollingRollout, worker.Strategy.Type)
+}
+
+func TestApply_StandaloneTasksInDependencyOrder(t *testing.T) {
+	c := newCore(t)
+	m, err := manifest.Parse("x.yaml", []byte(`
+version: v1
+components:
+  - {id: api, type: kuber, version: 1.0.0, metadata: {namespace: prod}}
+tasks:
+  - {id: deploy, type: update, components: [api], dependsOn: [{id: migrate}]}
+  - {id: migrate, type: update, components: [api]}
+`))
+	require.NoError(t, err)
+
+	result, err := manifest.Apply(c, m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "deploy"}, result.Tasks)
+}
@@ -0,0 +1,407 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+)
+
+// ValidationError points at the field of a manifest item that is invalid.
+type ValidationError struct {
+	File    string
+	Path    string // Путь к элементу, например "tasks[2].dependsOn[0]"
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	location := e.Path
+	if e.Field != "" {
+		if location != "" {
+			location += "."
+		}
+		location += e.Field
+	}
+
+	parts := make([]string, 0, 3)
+	if e.File != "" {
+		parts = append(parts, e.File)
+	}
+	if location != "" {
+		parts = append(parts, location)
+	}
+	return strings.Join(append(parts, e.Message), ": ")
+}
+
+// ValidationErrors is every problem found in a manifest.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type validator struct {
+	core        *inforo.Core
+	errs        ValidationErrors
+	components  map[string]*Component
+	monitorings map[string]*Monitoring
+	tasks       map[string]*Task
+	taskPlans   map[string]*Plan
+}
+
+func (v *validator) fail(file, path, field, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{File: file, Path: path, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the manifest as a whole: required fields and values,
+// references between items, plan membership and dependency cycles. With a
+// core it also checks controllers and existing objects, and registers the
+// manifest into an empty scratch Core to catch what the registries reject.
+// Without a core, references to objects outside the manifest are not checked.
+func (m *Manifest) Validate(core *inforo.Core) error {
+	v := &validator{
+		core:        core,
+		components:  make(map[string]*Component),
+		monitorings: make(map[string]*Monitoring),
+		tasks:       make(map[string]*Task),
+		taskPlans:   make(map[string]*Plan),
+	}
+
+	for _, comp := range m.Components {
+		v.component(comp)
+	}
+	for _, mon := range m.Monitorings {
+		v.monitoring(mon)
+	}
+	for _, task := range m.Tasks {
+		if task.ID == "" {
+			continue
+		}
+		if first, exists := v.tasks[task.ID]; exists {
+			v.fail(task.file, task.path, "id", "duplicate task %q, first defined in %s %s", task.ID, first.file, first.path)
+			continue
+		}
+		v.tasks[task.ID] = task
+	}
+	for _, plan := range m.Plans {
+		v.plan(plan)
+	}
+	for _, task := range m.Tasks {
+		v.task(task)
+	}
+	v.cycles(m.Tasks)
+
+	if len(v.errs) == 0 && core != nil {
+		v.dryRun(m)
+	}
+	if len(v.errs) != 0 {
+		return v.errs
+	}
+	return nil
+}
+
+func (v *validator) component(comp *Component) {
+	if comp.ID == "" {
+		v.fail(comp.file, comp.path, "id", "is required")
+	} else if first, exists := v.components[comp.ID]; exists {
+		v.fail(comp.file, comp.path, "id", "duplicate component %q, first defined in %s %s", comp.ID, first.file, first.path)
+	} else {
+		v.components[comp.ID] = comp
+		if v.core != nil {
+			if _, err := v.core.Components.Get(comp.ID); err == nil {
+				v.fail(comp.file, comp.path, "id", "component %q is already registered", comp.ID)
+			}
+		}
+	}
+
+	if comp.Type == "" {
+		v.fail(comp.file, comp.path, "type", "is required")
+	} else if v.core != nil {
+		if _, err := v.core.Controllers.Get(comp.Type); err != nil {
+			v.fail(comp.file, comp.path, "type", "no controller for component type %q", comp.Type)
+		}
+	}
+	if comp.Version == "" {
+		v.fail(comp.file, comp.path, "version", "is required")
+	}
+}
+
+func (v *validator) monitoring(mon *Monitoring) {
+	if mon.ID == "" {
+		v.fail(mon.file, mon.path, "id", "is required")
+	} else if first, exists := v.monitorings[mon.ID]; exists {
+		v.fail(mon.file, mon.path, "id", "duplicate monitoring %q, first defined in %s %s", mon.ID, first.file, first.path)
+	} else {
+		v.monitorings[mon.ID] = mon
+		if v.core != nil {
+			if _, err := v.core.Monitorings.Get(mon.ID); err == nil {
+				v.fail(mon.file, mon.path, "id", "monitoring %q is already registered", mon.ID)
+			}
+		}
+	}
+
+	if mon.Type == "" {
+		v.fail(mon.file, mon.path, "type", "is required")
+	} else if v.core != nil {
+		if _, err := v.core.MonitorControllers.Get(mon.Type); err != nil {
+			v.fail(mon.file, mon.path, "type", "no monitoring controller for type %q", mon.Type)
+		}
+	}
+}
+
+func (v *validator) plan(plan *Plan) {
+	if plan.Name == "" {
+		v.fail(plan.file, plan.path, "name", "is required")
+	}
+	if plan.MaxParallelism < 0 {
+		v.fail(plan.file, plan.path, "maxParallelism", "must not be negative")
+	}
+	if len(plan.Tasks) == 0 {
+		v.fail(plan.file, plan.path, "tasks", "at least one task is required")
+	}
+	for i, taskID := range plan.Tasks {
+		field := fmt.Sprintf("tasks[%d]", i)
+		if _, exists := v.tasks[taskID]; !exists {
+			v.fail(plan.file, plan.path, field, "task %q is not defined", taskID)
+			continue
+		}
+		if other, exists := v.taskPlans[taskID]; exists {
+			v.fail(plan.file, plan.path, field, "task %q already belongs to plan %q", taskID, other.Name)
+			continue
+		}
+		v.taskPlans[taskID] = plan
+	}
+}
+
+func (v *validator) task(task *Task) {
+	if task.ID == "" {
+		v.fail(task.file, task.path, "id", "is required")
+	}
+	switch model.TaskType(task.Type) {
+	case model.UpdateTask, model.RollbackTask, model.CheckTask:
+	case "":
+		v.fail(task.file, task.path, "type", "is required")
+	default:
+		v.fail(task.file, task.path, "type", "invalid task type %q", task.Type)
+	}
+
+	if len(task.Components) == 0 {
+		v.fail(task.file, task.path, "components", "at least one component is required")
+	}
+	for i, componentID := range task.Components {
+		if !v.componentExists(componentID) {
+			v.fail(task.file, task.path, fmt.Sprintf("components[%d]", i), "component %q is not defined", componentID)
+		}
+	}
+
+	plan := v.taskPlans[task.ID]
+	for i, depends := range task.DependsOn {
+		path := fmt.Sprintf("%s.dependsOn[%d]", task.path, i)
+		switch model.DepensType(depends.Type) {
+		case model.Strict, model.Ordered, model.Blocking, model.Advisory, "":
+		default:
+			v.fail(task.file, path, "type", "invalid dependency type %q", depends.Type)
+		}
+
+		switch {
+		case depends.ID == "":
+			v.fail(task.file, path, "id", "is required")
+		case depends.ID == task.ID:
+			v.fail(task.file, path, "id", "task cannot depend on itself")
+		case v.tasks[depends.ID] == nil:
+			if !v.existingTask(depends.ID) || plan != nil {
+				v.fail(task.file, path, "id", "task %q is not defined", depends.ID)
+			}
+		case plan != nil && v.taskPlans[depends.ID] != plan:
+			// Plans register their tasks together, a dependency must be in the same plan
+			v.fail(task.file, path, "id", "task %q is not in plan %q", depends.ID, plan.Name)
+		}
+	}
+
+	v.checks(task, "preChecks", task.PreChecks)
+	v.checks(task, "postChecks", task.PostChecks)
+
+	if task.RollBack != nil {
+		switch model.RollBackType(task.RollBack.Type) {
+		case model.ManualRollBack, model.TriggerRollBack:
+		case "":
+			v.fail(task.file, task.path+".rollBack", "type", "is required")
+		default:
+			v.fail(task.file, task.path+".rollBack", "type", "invalid rollback type %q", task.RollBack.Type)
+		}
+	}
+	v.retry(task.file, task.path+".retry", task.Retry)
+
+	switch model.FailurePolicy(task.FailurePolicy) {
+	case model.FailFast, model.ContinueOnError, "":
+	case model.MinSuccessRatio:
+		if task.MinSuccessRatio <= 0 || task.MinSuccessRatio > 1 {
+			v.fail(task.file, task.path, "minSuccessRatio", "must be in (0, 1] for %s", model.MinSuccessRatio)
+		}
+	default:
+		v.fail(task.file, task.path, "failurePolicy", "invalid failure policy %q", task.FailurePolicy)
+	}
+
+	if task.Strategy != nil {
+		path := task.path + ".strategy"
+		switch model.RolloutType(task.Strategy.Type) {
+		case model.SequentialRollout, model.RollingRollout, model.CanaryRollout:
+		default:
+			v.fail(task.file, path, "type", "invalid rollout strategy %q", task.Strategy.Type)
+		}
+		v.duration(task.file, path, "pause", task.Strategy.Pause)
+	}
+}
+
+func (v *validator) checks(task *Task, field string, checks []*Check) {
+	for i, check := range checks {
+		path := fmt.Sprintf("%s.%s[%d]", task.path, field, i)
+		if check.ID == "" {
+			v.fail(task.file, path, "id", "is required")
+		}
+		if check.Monitoring == "" {
+			v.fail(task.file, path, "monitoring", "is required")
+		} else if !v.monitoringExists(check.Monitoring) {
+			v.fail(task.file, path, "monitoring", "monitoring %q is not defined", check.Monitoring)
+		}
+
+		switch model.CheckSeverity(check.Severity) {
+		case model.SeverityBlocking, model.SeverityWarning, model.SeverityInfo, "":
+		default:
+			v.fail(task.file, path, "severity", "invalid severity %q", check.Severity)
+		}
+
+		interval := v.duration(task.file, path, "interval", check.Interval)
+		duration := v.duration(task.file, path, "duration", check.Duration)
+		switch model.CheckMode(check.Mode) {
+		case model.CheckOnce, "":
+		case model.CheckSoak:
+			if interval <= 0 {
+				v.fail(task.file, path, "interval", "soak needs a positive interval")
+			} else if duration < interval {
+				v.fail(task.file, path, "duration", "soak duration must not be shorter than its interval")
+			}
+		default:
+			v.fail(task.file, path, "mode", "invalid mode %q", check.Mode)
+		}
+		v.retry(task.file, path+".retry", check.Retry)
+	}
+}
+
+func (v *validator) retry(file, path string, retry *Retry) {
+	if retry == nil {
+		return
+	}
+	switch model.BackoffType(retry.Backoff) {
+	case model.FixedBackoff, model.ExponentialBackoff, "":
+	default:
+		v.fail(file, path, "backoff", "invalid backoff %q", retry.Backoff)
+	}
+	v.duration(file, path, "delay", retry.Delay)
+	v.duration(file, path, "maxDelay", retry.MaxDelay)
+}
+
+// duration parses a duration field; an empty value is zero.
+func (v *validator) duration(file, path, field, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.fail(file, path, field, "invalid duration %q", value)
+		return 0
+	}
+	if d < 0 {
+		v.fail(file, path, field, "must not be negative")
+	}
+	return d
+}
+
+// cycles reports dependency cycles between the tasks of the manifest.
+func (v *validator) cycles(tasks []*Task) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(task *Task) bool
+	visit = func(task *Task) bool {
+		state[task.ID] = visiting
+		stack = append(stack, task.ID)
+		for _, depends := range task.DependsOn {
+			next := v.tasks[depends.ID]
+			if next == nil || next == task {
+				continue
+			}
+			switch state[next.ID] {
+			case visiting:
+				cycle := append([]string{}, stack[indexOf(stack, next.ID):]...)
+				cycle = append(cycle, next.ID)
+				v.fail(task.file, task.path, "dependsOn", "dependency cycle %s", strings.Join(cycle, " -> "))
+				return true
+			case 0:
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[task.ID] = done
+		return false
+	}
+
+	for _, task := range tasks {
+		if task.ID != "" && v.tasks[task.ID] == task && state[task.ID] == 0 {
+			stack = stack[:0]
+			visit(task)
+		}
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func (v *validator) componentExists(id string) bool {
+	if _, exists := v.components[id]; exists {
+		return true
+	}
+	if v.core == nil {
+		return true
+	}
+	_, err := v.core.Components.Get(id)
+	return err == nil
+}
+
+func (v *validator) monitoringExists(id string) bool {
+	if _, exists := v.monitorings[id]; exists {
+		return true
+	}
+	if v.core == nil {
+		return true
+	}
+	_, err := v.core.Monitorings.Get(id)
+	return err == nil
+}
+
+func (v *validator) existingTask(id string) bool {
+	if v.core == nil {
+		return true
+	}
+	_, err := v.core.Tasks.Get(id)
+	return err == nil
+}
@@ -0,0 +1,218 @@
+// Package metrics exports Prometheus metrics of a Core: task executions,
+// controller latency, check results, plan durations, rollbacks and
+// executions in flight.
+//
+// Metrics follows the event bus of the Core, so it can be added to a running
+// Core without changing its registries:
+//
+//	m, err := metrics.NewMetrics(metrics.MetricsOptions{Core: core})
+//	go m.Run(ctx)
+//	http.Handle("/metrics", m.Handler())
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsOptions provides configuration options for initializing Metrics.
+type MetricsOptions struct {
+	Core *inforo.Core
+	// Registry the metrics are registered in; a new one by default. Pass
+	// prometheus.DefaultRegisterer's registry to add Go runtime metrics.
+	Registry  *prometheus.Registry
+	Namespace string // "inforo" by default
+}
+
+// Metrics collects the metrics of a Core.
+type Metrics struct {
+	core     *inforo.Core
+	registry *prometheus.Registry
+	sub      api.Subscription
+
+	taskExecutions  *prometheus.CounterVec
+	runTaskDuration *prometheus.HistogramVec
+	checkResults    *prometheus.CounterVec
+	planDuration    *prometheus.HistogramVec
+	rollbacks       *prometheus.CounterVec
+
+	// planStarted — время запуска выполняющихся планов
+	planStarted map[string]time.Time
+	mu          sync.Mutex
+}
+
+func NewMetrics(opts MetricsOptions) (*Metrics, error) {
+	if opts.Core == nil || opts.Core.Bus == nil {
+		return nil, errors.New("metrics requires a core with an event bus")
+	}
+	if opts.Registry == nil {
+		opts.Registry = prometheus.NewRegistry()
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "inforo"
+	}
+
+	m := &Metrics{
+		core:     opts.Core,
+		registry: opts.Registry,
+		taskExecutions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "task_executions_total",
+			Help:      "Finished task executions by task type and status.",
+		}, []string{"type", "status"}),
+		runTaskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "controller_run_task_duration_seconds",
+			Help:      "Duration of controller RunTask calls by controller type and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"controller_type", "status"}),
+		checkResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "check_results_total",
+			Help:      "Check results by monitoring and status.",
+		}, []string{"monitoring", "status"}),
+		planDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "plan_duration_seconds",
+			Help:      "Duration of plan executions by final status.",
+			Buckets:   prometheus.ExponentialBuckets(1, 3, 10),
+		}, []string{"status"}),
+		rollbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "rollbacks_total",
+			Help:      "Rolled back tasks and plans.",
+		}, []string{"entity"}),
+		planStarted: make(map[string]time.Time),
+	}
+	inFlight := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: opts.Namespace,
+		Name:      "executions_in_flight",
+		Help:      "Task, rollback and plan executions that have not finished.",
+	}, m.inFlight)
+
+	for _, collector := range []prometheus.Collector{
+		m.taskExecutions, m.runTaskDuration, m.checkResults, m.planDuration, m.rollbacks, inFlight,
+	} {
+		if err := opts.Registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	m.sub = opts.Core.Bus.Subscribe(model.EventFilter{
+		Types: []model.BusEventType{model.StatusChanged, model.ControllerCalled},
+	})
+	return m, nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Run follows the event bus until ctx is cancelled.
+func (m *Metrics) Run(ctx context.Context) error {
+	defer m.sub.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-m.sub.Events():
+			if !ok {
+				return nil
+			}
+			m.observe(event)
+		}
+	}
+}
+
+func (m *Metrics) observe(event model.BusEvent) {
+	if event.Type == model.ControllerCalled {
+		m.runTaskDuration.WithLabelValues(event.ControllerType, string(event.Status)).
+			Observe(time.Duration(event.Duration).Seconds())
+		return
+	}
+
+	switch event.Entity {
+	case model.EntityTask:
+		switch event.Status {
+		case model.StatusSuccess, model.StatusFailed, model.StatusStopped:
+			if task, err := m.core.Tasks.Get(event.EntityID); err == nil {
+				task.MU.RLock()
+				taskType := task.Type
+				task.MU.RUnlock()
+				m.taskExecutions.WithLabelValues(string(taskType), string(event.Status)).Inc()
+			}
+		case model.StatusRollBack:
+			m.rollbacks.WithLabelValues(string(model.EntityTask)).Inc()
+		}
+	case model.EntityCheck:
+		if monitoringID := m.checkMonitoring(event.TaskID, event.EntityID); monitoringID != "" {
+			m.checkResults.WithLabelValues(monitoringID, string(event.Status)).Inc()
+		}
+	case model.EntityPlan:
+		m.observePlan(event)
+	}
+}
+
+func (m *Metrics) observePlan(event model.BusEvent) {
+	if event.Status == model.StatusRollBack {
+		m.rollbacks.WithLabelValues(string(model.EntityPlan)).Inc()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch event.Status {
+	case model.StatusRunning:
+		// Возобновление после паузы продолжает то же выполнение
+		if _, running := m.planStarted[event.EntityID]; !running {
+			m.planStarted[event.EntityID] = event.Timestamp
+		}
+	case model.StatusSuccess, model.StatusFailed, model.StatusStopped, model.StatusRollBack, model.StatusInterrupted:
+		if started, running := m.planStarted[event.EntityID]; running {
+			m.planDuration.WithLabelValues(string(event.Status)).Observe(event.Timestamp.Sub(started).Seconds())
+			delete(m.planStarted, event.EntityID)
+		}
+	}
+}
+
+// checkMonitoring returns the monitoring of the check of the task.
+func (m *Metrics) checkMonitoring(taskID, checkID string) string {
+	task, err := m.core.Tasks.Get(taskID)
+	if err != nil {
+		return ""
+	}
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	for _, checks := range [][]*model.Check{task.PreChecks, task.PostChecks} {
+		for _, check := range checks {
+			if check.ID == checkID {
+				return check.MonitoringID
+			}
+		}
+	}
+	return ""
+}
+
+func (m *Metrics) inFlight() float64 {
+	executions, err := m.core.Executions.List()
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, execution := range executions {
+		if execution.FinishedAt.IsZero() {
+			count++
+		}
+	}
+	return float64(count)
+}
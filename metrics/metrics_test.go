@@ -0,0 +1,152 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/metrics"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- deploy controller: fails to deploy the "broken" image ---
+type deployController struct{}
+
+func (d *deployController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "broken" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+func (d *deployController) ValideTask(r map[string]string) error      { return nil }
+func (d *deployController) ValideComponent(m map[string]string) error { return nil }
+func (d *deployController) CheckComponent(m map[string]string) error  { return nil }
+
+// --- monitoring controller: fails checks with "fail" metadata ---
+type monitoringController struct{}
+
+func (m *monitoringController) RunCheck(meta map[string]string) error {
+	if meta["fail"] != "" {
+		return errors.New("error rate 5% above 1%")
+	}
+	return nil
+}
+func (m *monitoringController) CheckMonitoring(config map[string]string) error    { return nil }
+func (m *monitoringController) ValidateCheck(meta map[string]string) error        { return nil }
+func (m *monitoringController) ValidateMonitoring(config map[string]string) error { return nil }
+
+func setupCore(t *testing.T) *inforo.Core {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	require.NoError(t, c.MonitorControllers.Register("scripted", &monitoringController{}))
+	_, err = c.Monitorings.Register("scripted", &model.Monitoring{ID: "prometheus", Type: "scripted"})
+	require.NoError(t, err)
+	return c
+}
+
+// startMetrics runs the metrics until the test ends.
+func startMetrics(t *testing.T, c *inforo.Core) *metrics.Metrics {
+	m, err := metrics.NewMetrics(metrics.MetricsOptions{Core: c})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return m
+}
+
+// scrape returns the exposition served by the server on GET /metrics.
+func scrape(t *testing.T, m *metrics.Metrics, c *inforo.Core) string {
+	srv, err := server.NewServer(server.ServerOptions{Core: c, Metrics: m.Handler()})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// eventuallyContains waits until every line is in the exposition.
+func eventuallyContains(t *testing.T, m *metrics.Metrics, c *inforo.Core, lines ...string) {
+	var body string
+	ok := assert.Eventually(t, func() bool {
+		body = scrape(t, m, c)
+		exposed := strings.Split(body, "\n")
+		for _, line := range lines {
+			if !slices.Contains(exposed, line) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	if !ok {
+		t.Logf("metrics:\n%s", body)
+	}
+}
+
+func TestMetrics_TaskExecutionsAndControllerLatency(t *testing.T) {
+	c := setupCore(t)
+	m := startMetrics(t, c)
+
+	for id, image := range map[string]string{"ok-1": "v2", "ok-2": "v2", "broken": "broken"} {
+		_, err := c.Tasks.Register(&model.Task{ID: id, Type: model.UpdateTask, Components: []string{"api"},
+			Metadata: map[string]string{"image": image}})
+		require.NoError(t, err)
+		c.Tasks.Fork(id, "")
+	}
+
+	eventuallyContains(t, m, c,
+		`inforo_task_executions_total{status="success",type="update"} 2`,
+		`inforo_task_executions_total{status="failed",type="update"} 1`,
+		`inforo_controller_run_task_duration_seconds_count{controller_type="deploy",status="success"} 2`,
+		`inforo_controller_run_task_duration_seconds_count{controller_type="deploy",status="failed"} 1`,
+		`inforo_executions_in_flight 0`,
+	)
+}
+
+func TestMetrics_ChecksPlansAndRollbacks(t *testing.T) {
+	c := setupCore(t)
+	m := startMetrics(t, c)
+
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "deploy", Type: model.UpdateTask, Components: []string{"api"}, Metadata: map[string]string{"image": "broken"},
+			PreChecks: []*model.Check{
+				{ID: "ready", MonitoringID: "prometheus", Metadata: map[string]string{}},
+				{ID: "errors", MonitoringID: "prometheus", Severity: model.SeverityWarning, Metadata: map[string]string{"fail": "yes"}},
+			},
+			RollBack: &model.Rollback{Type: model.TriggerRollBack, Metadata: map[string]string{"image": "v1"}}},
+	})
+	require.NoError(t, err)
+	c.Plans.Run(plan.ID, "")
+
+	eventuallyContains(t, m, c,
+		`inforo_check_results_total{monitoring="prometheus",status="success"} 1`,
+		`inforo_check_results_total{monitoring="prometheus",status="failed"} 1`,
+		`inforo_plan_duration_seconds_count{status="failed"} 1`,
+		`inforo_rollbacks_total{entity="task"} 1`,
+	)
+}
+
+func TestNewMetrics_RequiresBus(t *testing.T) {
+	_, err := metrics.NewMetrics(metrics.MetricsOptions{})
+	require.EqualError(t, err, "metrics requires a core with an event bus")
+}
@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+type AuditOperation string
+
+const (
+	AuditRegister        AuditOperation = "register"
+	AuditUpdate          AuditOperation = "update"
+	AuditDelete          AuditOperation = "delete"
+	AuditDisable         AuditOperation = "disable"
+	AuditEnable          AuditOperation = "enable"
+	AuditFork            AuditOperation = "fork"
+	AuditRun             AuditOperation = "run"
+	AuditRollBack        AuditOperation = "rollback"
+	AuditApproveRollBack AuditOperation = "approve_rollback"
+	AuditStop            AuditOperation = "stop"
+	AuditPause           AuditOperation = "pause"
+	AuditResume          AuditOperation = "resume"
+	AuditSetParallelism  AuditOperation = "set_parallelism"
+	AuditRecover         AuditOperation = "recover"
+)
+
+type AuditResult string
+
+const (
+	AuditSuccess AuditResult = "success"
+	AuditFailure AuditResult = "failure"
+)
+
+// AuditRecord — запись о вызове изменяющего метода реестра
+type AuditRecord struct {
+	ID          string         `json:"ID"`
+	Timestamp   time.Time      `json:"Timestamp"`
+	Actor       string         `json:"Actor"`
+	Operation   AuditOperation `json:"Operation"`
+	Entity      EntityType     `json:"Entity"`
+	EntityID    string         `json:"EntityID"`
+	ExecutionID string         `json:"ExecutionID,omitempty"`
+	// Changes — поля сущности, изменившиеся в результате операции
+	Changes []FieldChange `json:"Changes,omitempty"`
+	Result  AuditResult   `json:"Result"`
+	Error   string        `json:"Error,omitempty"`
+}
+
+// FieldChange holds the JSON values of a field before and after an
+// operation; nil stands for a field that did not exist.
+type FieldChange struct {
+	Field  string      `json:"Field"`
+	Before interface{} `json:"Before,omitempty"`
+	After  interface{} `json:"After,omitempty"`
+}
+
+// AuditQuery selects audit records; empty fields match everything. Since is
+// inclusive, Until is exclusive.
+type AuditQuery struct {
+	Entity   EntityType `json:"Entity,omitempty"`
+	EntityID string     `json:"EntityID,omitempty"`
+	Actor    string     `json:"Actor,omitempty"`
+	Since    time.Time  `json:"Since,omitempty"`
+	Until    time.Time  `json:"Until,omitempty"`
+}
+
+func (q AuditQuery) Match(record *AuditRecord) bool {
+	switch {
+	case q.Entity != "" && record.Entity != q.Entity:
+		return false
+	case q.EntityID != "" && record.EntityID != q.EntityID:
+		return false
+	case q.Actor != "" && record.Actor != q.Actor:
+		return false
+	case !q.Since.IsZero() && record.Timestamp.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !record.Timestamp.Before(q.Until):
+		return false
+	}
+	return true
+}
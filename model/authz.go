@@ -0,0 +1,51 @@
+package model
+
+// Role — роль вызывающего; каждая следующая включает права предыдущей
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // Чтение
+	RoleOperator Role = "operator" // Запуск и управление выполнениями
+	RoleAdmin    Role = "admin"    // Изменение компонентов, мониторингов, задач и планов
+)
+
+// AccessRead — чтение в правилах доступа, наряду с операциями журнала аудита
+const AccessRead AuditOperation = "read"
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Identity — кто вызывает операции Core
+type Identity struct {
+	Name  string `json:"Name"`
+	Roles []Role `json:"Roles,omitempty"`
+}
+
+// HasRole reports whether the identity has the role or a role above it.
+func (i *Identity) HasRole(role Role) bool {
+	if i == nil {
+		return false
+	}
+	for _, r := range i.Roles {
+		if roleRank[r] >= roleRank[role] && roleRank[r] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidRole(role Role) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// AccessRule requires Role for the operations it matches; empty fields match
+// everything. ComponentTypes matches operations touching a component of
+// one of the types: the component itself, the components of a task or of
+// any task of a plan.
+type AccessRule struct {
+	Operations     []AuditOperation `json:"Operations,omitempty"`
+	Entities       []EntityType     `json:"Entities,omitempty"`
+	EntityIDs      []string         `json:"EntityIDs,omitempty"`
+	ComponentTypes []string         `json:"ComponentTypes,omitempty"`
+	Role           Role             `json:"Role"`
+}
@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+type EntityType string
+
+const (
+	EntityComponent  EntityType = "component"
+	EntityTask       EntityType = "task"
+	EntityPlan       EntityType = "plan"
+	EntityMonitoring EntityType = "monitoring"
+	EntityCheck      EntityType = "check"
+	EntityExecution  EntityType = "execution"
+)
+
+type BusEventType string
+
+const (
+	StatusChanged BusEventType = "status"
+	EventAdded    BusEventType = "event"
+	// ControllerCalled — вызов RunTask контроллера для компонента задачи
+	ControllerCalled BusEventType = "controller"
+)
+
+// BusEvent — изменение статуса, новая запись в истории событий сущности или
+// вызов контроллера
+type BusEvent struct {
+	Type     BusEventType `json:"Type"`
+	Entity   EntityType   `json:"Entity"`
+	EntityID string       `json:"EntityID"`
+	// TaskID — задача, в рамках которой выполнялась проверка или обновлялся компонент
+	TaskID         string    `json:"TaskID,omitempty"`
+	Status         Status    `json:"Status,omitempty"`
+	PreviousStatus Status    `json:"PreviousStatus,omitempty"` // пусто для только что созданной сущности
+	Message        string    `json:"Message,omitempty"`
+	Timestamp      time.Time `json:"Timestamp"`
+	// ControllerType и Duration заполняются для ControllerCalled
+	ControllerType string   `json:"ControllerType,omitempty"`
+	Duration       Duration `json:"Duration,omitempty"`
+}
+
+// EventFilter selects bus events; empty fields match everything. EntityIDs
+// also match TaskID, so subscribing to a task includes its checks and
+// components.
+type EventFilter struct {
+	Types     []BusEventType `json:"Types,omitempty"`
+	Entities  []EntityType   `json:"Entities,omitempty"`
+	EntityIDs []string       `json:"EntityIDs,omitempty"`
+}
+
+func (f EventFilter) Match(event BusEvent) bool {
+	if len(f.Types) != 0 && !contains(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Entities) != 0 && !contains(f.Entities, event.Entity) {
+		return false
+	}
+	if len(f.EntityIDs) != 0 && !contains(f.EntityIDs, event.EntityID) &&
+		(event.TaskID == "" || !contains(f.EntityIDs, event.TaskID)) {
+		return false
+	}
+	return true
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
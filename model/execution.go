@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type ExecutionKind string
+
+const (
+	TaskExecution     ExecutionKind = "task"
+	RollBackExecution ExecutionKind = "rollback"
+	PlanExecution     ExecutionKind = "plan"
+)
+
+// Execution описывает один запуск задачи, отката или плана. Реестр
+// выполнений отдаёт копии записей, поэтому своей блокировки у неё нет
+type Execution struct {
+	ID         string        `json:"ID"`
+	Kind       ExecutionKind `json:"Kind"`
+	PlanID     string        `json:"PlanID,omitempty"`
+	TaskID     string        `json:"TaskID,omitempty"`  // задача, с которой начато выполнение
+	TaskIDs    []string      `json:"TaskIDs,omitempty"` // все задачи, выполненные в рамках запуска
+	Status     Status        `json:"Status"`
+	Error      string        `json:"Error,omitempty"`
+	StartedAt  time.Time     `json:"StartedAt"`
+	FinishedAt time.Time     `json:"FinishedAt,omitempty"`
+}
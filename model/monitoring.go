@@ -1,6 +1,9 @@
 package model
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type Status string
 
@@ -14,12 +17,53 @@ type Monitoring struct {
 	MU            sync.RWMutex      `json:"-"`
 }
 
+type CheckSeverity string
+
+const (
+	// SeverityBlocking - проваленная проверка проваливает задачу (по умолчанию)
+	SeverityBlocking CheckSeverity = "blocking"
+	// SeverityWarning - провал записывается как предупреждение, задача продолжается
+	SeverityWarning CheckSeverity = "warning"
+	// SeverityInfo - результат только записывается в историю
+	SeverityInfo CheckSeverity = "info"
+)
+
+type CheckMode string
+
+const (
+	// CheckOnce - проверка выполняется один раз (по умолчанию)
+	CheckOnce CheckMode = "once"
+	// CheckSoak - проверка повторяется каждые Interval в течение Duration
+	CheckSoak CheckMode = "soak"
+)
+
 type Check struct {
-	ID            string            `json:"ID"`
-	Name          string            `json:"Name"`
-	MonitoringID  string            `json:"MonitoringID"`
-	StatusHistory *StatusHistory    `json:"StatusHistory,omitempty"`
-	EventHistory  *EventHistory     `json:"EventHistory,omitempty"`
-	Metadata      map[string]string `json:"MetaData"`
-	MU            sync.RWMutex      `json:"-"`
+	ID           string        `json:"ID"`
+	Name         string        `json:"Name"`
+	MonitoringID string        `json:"MonitoringID"`
+	Severity     CheckSeverity `json:"Severity,omitempty"`
+	Retry        *RetryPolicy  `json:"Retry,omitempty"`
+	// Soak: повтор каждые Interval в течение Duration. Проверка проваливается после
+	// FailureThreshold провалов подряд (по умолчанию 1) и досрочно проходит после
+	// SuccessThreshold успехов подряд (0 — наблюдать всё окно)
+	Mode             CheckMode         `json:"Mode,omitempty"`
+	Interval         Duration          `json:"Interval,omitempty"`
+	Duration         Duration          `json:"Duration,omitempty"`
+	SuccessThreshold int               `json:"SuccessThreshold,omitempty"`
+	FailureThreshold int               `json:"FailureThreshold,omitempty"`
+	Result           *CheckResult      `json:"Result,omitempty"` // Результат последнего выполнения
+	StatusHistory    *StatusHistory    `json:"StatusHistory,omitempty"`
+	EventHistory     *EventHistory     `json:"EventHistory,omitempty"`
+	Metadata         map[string]string `json:"MetaData"`
+	MU               sync.RWMutex      `json:"-"`
+}
+
+// CheckResult — результат выполнения проверки
+type CheckResult struct {
+	Status    Status        `json:"Status"` // success или failed
+	Severity  CheckSeverity `json:"Severity"`
+	Output    string        `json:"Output,omitempty"` // Ошибка контроллера мониторинга
+	Runs      int           `json:"Runs,omitempty"`   // Число выполнений soak-проверки
+	Duration  time.Duration `json:"Duration"`
+	Timestamp time.Time     `json:"Timestamp"`
 }
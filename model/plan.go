@@ -9,9 +9,11 @@ type Plan struct {
 	ID            string                `json:"id"` // Уникальный идентификатор плана
 	TaskGraphs    []*TaskGraph          // Набор независимых графов задач
 	RollbackStack []*RollbackCheckpoint // Стек точек отката
-	StatusHistory *StatusHistory        `json:"StatusHistory,omitempty"` // История статусов плана
-	EventHistory  *EventHistory         `json:"EventHistory,omitempty"`
-	MU            sync.RWMutex          `json:"-"`
+	// MaxParallelism ограничивает число одновременно выполняемых задач плана, 0 — без ограничения
+	MaxParallelism int            `json:"MaxParallelism,omitempty"`
+	StatusHistory  *StatusHistory `json:"StatusHistory,omitempty"` // История статусов плана
+	EventHistory   *EventHistory  `json:"EventHistory,omitempty"`
+	MU             sync.RWMutex   `json:"-"`
 }
 
 // TaskGraph представляет направленный ациклический граф задач
@@ -20,12 +22,21 @@ type TaskGraph struct {
 	Tasks        map[string]*Task    // Все задачи графа
 	Dependencies map[string][]string // Прямые зависимости (task → dependsOn)
 	Dependents   map[string][]string // Обратные зависимости (task ← requiredBy)
+	// MaxParallelism ограничивает число одновременно выполняемых задач графа, 0 — без ограничения
+	MaxParallelism int `json:"MaxParallelism,omitempty"`
 }
 
 // RollbackCheckpoint содержит состояние для отката
 type RollbackCheckpoint struct {
-	GraphID   string                 // ID графа
-	TaskID    string                 // ID задачи
-	State     map[string]interface{} // Состояние компонентов
-	Timestamp time.Time              // Время создания точки отката
+	GraphID   string                     // ID графа
+	TaskID    string                     // ID задачи
+	State     map[string]*ComponentState // Состояние компонентов до выполнения задачи
+	Timestamp time.Time                  // Время создания точки отката
+}
+
+// ComponentState — снимок компонента, по которому он восстанавливается при откате
+type ComponentState struct {
+	Version    string            `json:"Version"`
+	Metadata   map[string]string `json:"MetaData,omitempty"`
+	Controller map[string]string `json:"Controller,omitempty"` // Состояние от api.SnapshotController
 }
@@ -0,0 +1,16 @@
+package model
+
+// RecoveryPolicy определяет, что делать с планами, выполнение которых
+// было прервано остановкой процесса
+type RecoveryPolicy string
+
+const (
+	// RecoverNone — только пометить план как interrupted
+	RecoverNone RecoveryPolicy = ""
+	// RecoverResume — продолжить план с последней сохранённой точки отката
+	RecoverResume RecoveryPolicy = "resume"
+	// RecoverRollBack — откатить задачи, завершённые до сбоя
+	RecoverRollBack RecoveryPolicy = "rollback"
+	// RecoverFail — пометить план как failed
+	RecoverFail RecoveryPolicy = "fail"
+)
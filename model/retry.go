@@ -0,0 +1,54 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type BackoffType string
+
+const (
+	// FixedBackoff - одинаковая пауза между попытками
+	FixedBackoff BackoffType = "fixed"
+	// ExponentialBackoff - пауза растёт в Multiplier раз после каждой попытки
+	ExponentialBackoff BackoffType = "exponential"
+)
+
+// RetryPolicy описывает повторные попытки выполнения задачи или проверки
+type RetryPolicy struct {
+	MaxAttempts int         `json:"MaxAttempts"`          // Всего попыток, включая первую; 0 и 1 — без повторов
+	Backoff     BackoffType `json:"Backoff,omitempty"`    // fixed по умолчанию
+	Delay       Duration    `json:"Delay,omitempty"`      // Пауза перед первой повторной попыткой
+	MaxDelay    Duration    `json:"MaxDelay,omitempty"`   // Верхняя граница паузы, 0 — без ограничения
+	Multiplier  float64     `json:"Multiplier,omitempty"` // Множитель для exponential, по умолчанию 2
+	Jitter      float64     `json:"Jitter,omitempty"`     // Случайное отклонение паузы, доля от 0 до 1
+	RetryOn     []string    `json:"RetryOn,omitempty"`    // Повторять только ошибки, содержащие одну из подстрок
+}
+
+// Duration — time.Duration, который в JSON записывается строкой вида "1m30s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(time.Duration(v))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
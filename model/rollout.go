@@ -0,0 +1,22 @@
+package model
+
+type RolloutType string
+
+const (
+	// SequentialRollout - компоненты обновляются по одному (по умолчанию)
+	SequentialRollout RolloutType = "sequential"
+	// RollingRollout - компоненты обновляются параллельно пачками по BatchSize или BatchPercent
+	RollingRollout RolloutType = "rolling"
+	// CanaryRollout - сначала обновляются CanarySize компонентов и проверяются PostChecks,
+	// затем обновление продвигается на остальные компоненты
+	CanaryRollout RolloutType = "canary"
+)
+
+// RolloutStrategy описывает порядок обновления компонентов задачи
+type RolloutStrategy struct {
+	Type         RolloutType `json:"Type"`
+	BatchSize    int         `json:"BatchSize,omitempty"`    // Компонентов в пачке
+	BatchPercent int         `json:"BatchPercent,omitempty"` // Размер пачки в процентах от числа компонентов
+	CanarySize   int         `json:"CanarySize,omitempty"`   // Компонентов в канарейке, по умолчанию 1
+	Pause        Duration    `json:"Pause,omitempty"`        // Пауза между пачками
+}
@@ -19,6 +19,10 @@ const (
 	StatusRetry    Status = "retry"
 	StatusDisable  Status = "disable"
 	StatusRollBack Status = "rollback"
+	// StatusInterrupted — выполнение прервано остановкой процесса
+	StatusInterrupted Status = "interrupted"
+	// StatusAwaitingApproval — задача упала и ждёт подтверждения ручного отката
+	StatusAwaitingApproval Status = "awaiting_approval"
 )
 
 type StatusHistory struct {
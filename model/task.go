@@ -1,24 +1,34 @@
 package model
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type DepensType string
 
 const (
 	// Strict - зависимость должна быть успешно выполнена (жесткая зависимость)
-	// Если зависимость не выполнена - ошибка
+	// Зависимость запускается, если ещё не выполнена успешно; её ошибка — ошибка задачи.
+	// В плане задача не запускается, если зависимость упала или была пропущена.
+	// Используется, если тип не указан: раньше зависимость без типа
+	// игнорировалась, теперь её ошибка проваливает задачу.
 	Strict DepensType = "strict"
 
 	// Ordered - зависимость должна быть выполнена первой (порядковая зависимость)
-	// Планировщик должен сначала выполнить эту задачу
+	// Зависимость запускается, если ещё не выполнена успешно, но её результат не важен
 	Ordered DepensType = "ordered"
 
 	// Advisory - мягкая зависимость (рекомендательная)
-	// Желательно выполнить зависимость, но не обязательно
+	// Зависимость запускается, если не выполнена и не выполняется сейчас;
+	// задача выполняется, даже если зависимость упала или была пропущена
 	Advisory DepensType = "advisory"
 
 	// Blocking - блокирующая зависимость
-	// Текущая задача не может выполняться, пока зависимость не завершится
+	// Зависимость не запускается: задача ждёт, пока выполняющаяся зависимость
+	// не завершится с любым результатом. Если зависимость не выполняется и не
+	// выполнялась в том же выполнении (в плане её уже выполнил граф), задача
+	// сразу завершается ошибкой.
 	Blocking DepensType = "blocking"
 )
 
@@ -35,17 +45,43 @@ const (
 	CheckTask    TaskType = "check"
 )
 
+type FailurePolicy string
+
+const (
+	// FailFast - задача падает на первой ошибке компонента, остальные пропускаются (по умолчанию)
+	FailFast FailurePolicy = "fail-fast"
+	// ContinueOnError - обновляются все компоненты, задача падает, если упал хотя бы один
+	ContinueOnError FailurePolicy = "continue-on-error"
+	// MinSuccessRatio - обновляются все компоненты, задача успешна, если доля
+	// успешных компонентов не меньше Task.MinSuccessRatio
+	MinSuccessRatio FailurePolicy = "min-success-ratio"
+)
+
+// ComponentResult — результат выполнения задачи на одном компоненте
+type ComponentResult struct {
+	Status     Status    `json:"Status"`
+	Error      string    `json:"Error,omitempty"`
+	Attempts   int       `json:"Attempts,omitempty"` // Сколько раз задача запускалась на компоненте
+	StartedAt  time.Time `json:"StartedAt,omitempty"`
+	FinishedAt time.Time `json:"FinishedAt,omitempty"`
+}
+
 type Task struct {
-	ID            string            `json:"ID"`
-	Name          string            `json:"Name"`
-	Type          TaskType          `json:"Type"`
-	Components    []string          `json:"Components"`
-	RollBack      *Rollback         `json:"RollBack,omitempty"`
-	DependsOn     []Depends         `json:"DependsOn,omitempty"`
-	PreChecks     []*Check          `json:"PreChecks,omitempty"`
-	PostChecks    []*Check          `json:"PostChecks,omitempty"`
-	StatusHistory *StatusHistory    `json:"StatusHistory,omitempty"`
-	EventHistory  *EventHistory     `json:"EventHistory,omitempty"`
-	Metadata      map[string]string `json:"MetaData"`
-	MU            sync.RWMutex      `json:"-"`
+	ID               string                      `json:"ID"`
+	Name             string                      `json:"Name"`
+	Type             TaskType                    `json:"Type"`
+	Components       []string                    `json:"Components"`
+	RollBack         *Rollback                   `json:"RollBack,omitempty"`
+	DependsOn        []Depends                   `json:"DependsOn,omitempty"`
+	PreChecks        []*Check                    `json:"PreChecks,omitempty"`
+	PostChecks       []*Check                    `json:"PostChecks,omitempty"`
+	Retry            *RetryPolicy                `json:"Retry,omitempty"`
+	FailurePolicy    FailurePolicy               `json:"FailurePolicy,omitempty"`    // Реакция на ошибку одного из компонентов
+	MinSuccessRatio  float64                     `json:"MinSuccessRatio,omitempty"`  // Для min-success-ratio, от 0 до 1
+	Strategy         *RolloutStrategy            `json:"Strategy,omitempty"`         // Порядок обновления компонентов
+	ComponentResults map[string]*ComponentResult `json:"ComponentResults,omitempty"` // Результаты последнего выполнения по компонентам
+	StatusHistory    *StatusHistory              `json:"StatusHistory,omitempty"`
+	EventHistory     *EventHistory               `json:"EventHistory,omitempty"`
+	Metadata         map[string]string           `json:"MetaData"`
+	MU               sync.RWMutex                `json:"-"`
 }
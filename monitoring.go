@@ -1,6 +1,7 @@
 package inforo
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -16,8 +17,10 @@ import (
 type MonitoringRegistry struct {
 	monitorControllers api.MonitoringControllerRegistry
 	monitorings        map[string]*model.Monitoring
+	storage            api.Storage
 	*StatusManager
 	*Events
+	bus    api.EventBus
 	mu     *sync.RWMutex
 	logger *logrus.Logger
 }
@@ -25,8 +28,10 @@ type MonitoringRegistry struct {
 type MonitoringRegistryOptions struct {
 	Logger        *logrus.Logger
 	Controllers   api.MonitoringControllerRegistry
+	Storage       api.Storage
 	StatusManager *StatusManager
 	EventManager  *Events
+	Bus           api.EventBus // Получает изменения статусов и событий, если задан
 	// Другие зависимости
 }
 
@@ -36,13 +41,43 @@ func NewMonitoringRegistry(opts MonitoringRegistryOptions) (api.MonitoringRegist
 		mu:                 &sync.RWMutex{},
 		logger:             opts.Logger,
 		monitorControllers: opts.Controllers,
+		storage:            opts.Storage,
 		StatusManager:      opts.StatusManager,
+		bus:                opts.Bus,
 		monitorings:        make(map[string]*model.Monitoring),
 	}
 	// mr.Register("promql-monitor", controllers.NewPromQLMonitorController(opts.Logger, "http://prometheus:9090/api/v1"))
+	if err := mr.load(); err != nil {
+		return mr, err
+	}
 	return mr, nil
 }
 
+// load restores the monitorings saved in the storage.
+func (mr *MonitoringRegistry) load() error {
+	return loadDocuments(mr.storage, monitoringsCollection, func(id string, data []byte) error {
+		m := &model.Monitoring{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return err
+		}
+		if m.StatusHistory == nil {
+			m.StatusHistory = mr.NewStatus(model.StatusPending)
+		}
+		if m.EventHistory == nil {
+			m.EventHistory = &model.EventHistory{}
+		}
+		mr.monitorings[m.ID] = m
+		return nil
+	})
+}
+
+// save persists the monitoring; callers hold mr.mu.
+func (mr *MonitoringRegistry) save(m *model.Monitoring) error {
+	m.EventHistory.MU.RLock()
+	defer m.EventHistory.MU.RUnlock()
+	return saveDocument(mr.storage, monitoringsCollection, m.ID, m)
+}
+
 func (mr *MonitoringRegistry) Register(tp string, m *model.Monitoring) (*model.Monitoring, error) {
 	mr.logger.Debugf("MonitoringRegistry.Register: call(), args: m[%v]", m)
 
@@ -71,7 +106,12 @@ func (mr *MonitoringRegistry) Register(tp string, m *model.Monitoring) (*model.M
 	mr.AddEvent(m.EventHistory, "Created monitoring!")
 
 	m.StatusHistory = mr.NewStatus(model.StatusPending)
+	if err := mr.save(m); err != nil {
+		return nil, err
+	}
 	mr.monitorings[m.ID] = m
+	publishStatus(mr.bus, model.EntityMonitoring, m.ID, "", m.StatusHistory)
+	publishEvent(mr.bus, model.EntityMonitoring, m.ID, "", "Created monitoring!")
 	return m, nil
 }
 
@@ -109,6 +149,9 @@ func (mr *MonitoringRegistry) Update(id string, updated *model.Monitoring) error
 		}
 	}
 
+	if err := mr.save(updated); err != nil {
+		return err
+	}
 	mr.monitorings[id] = updated
 	mr.logger.Infof("Monitoring %s updated", id)
 	return nil
@@ -122,6 +165,9 @@ func (mr *MonitoringRegistry) Delete(id string) error {
 		return errors.New("monitoring system not found")
 	}
 
+	if err := deleteDocument(mr.storage, monitoringsCollection, id); err != nil {
+		return err
+	}
 	delete(mr.monitorings, id)
 	return nil
 }
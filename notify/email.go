@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailOptions provides configuration options for initializing an EmailSink.
+type EmailOptions struct {
+	Name string // "email" by default
+	Addr string // SMTP server, "host:port"
+	From string
+	To   []string
+	Auth smtp.Auth // Without authentication if nil
+	// SendMail sends the message, smtp.SendMail by default
+	SendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// EmailSink sends notifications as plain text email.
+type EmailSink struct {
+	name     string
+	addr     string
+	from     string
+	to       []string
+	auth     smtp.Auth
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmailSink(opts EmailOptions) (*EmailSink, error) {
+	if opts.Addr == "" {
+		return nil, errors.New("email requires an SMTP server address")
+	}
+	if opts.From == "" || len(opts.To) == 0 {
+		return nil, errors.New("email requires a sender and at least one recipient")
+	}
+	if opts.Name == "" {
+		opts.Name = "email"
+	}
+	if opts.SendMail == nil {
+		opts.SendMail = smtp.SendMail
+	}
+	return &EmailSink{
+		name:     opts.Name,
+		addr:     opts.Addr,
+		from:     opts.From,
+		to:       opts.To,
+		auth:     opts.Auth,
+		sendMail: opts.SendMail,
+	}, nil
+}
+
+func (e *EmailSink) Name() string {
+	return e.name
+}
+
+func (e *EmailSink) Send(ctx context.Context, n *Notification) error {
+	// net/smtp не принимает контекст — проверяем его хотя бы перед отправкой
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.sendMail(e.addr, e.auth, e.from, e.to, e.message(n))
+}
+
+// message renders the notification as an RFC 5322 message.
+func (e *EmailSink) message(n *Notification) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: [inforo] %s\r\n", n.Title())
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "%s\r\n\r\n", n.Title())
+	fmt.Fprintf(&msg, "%s:    %s\r\n", n.entityName(), n.ID)
+	fmt.Fprintf(&msg, "Status:  %s -> %s\r\n", n.PreviousStatus, n.Status)
+	fmt.Fprintf(&msg, "Time:    %s\r\n", n.Timestamp.Format(time.RFC3339))
+	if n.Message != "" {
+		fmt.Fprintf(&msg, "Event:   %s\r\n", n.Message)
+	}
+	return msg.Bytes()
+}
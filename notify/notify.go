@@ -0,0 +1,306 @@
+// Package notify sends notifications about plan and task status transitions
+// to webhooks, Slack and email.
+//
+// A Notifier subscribes to the event bus of a Core, matches status changes
+// against its rules and delivers them to the sinks of the matching rules with
+// retries. The same transition of the same plan or task is sent to a sink at
+// most once per dedup window.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDedupWindow — повторный переход в тот же статус в течение окна не отправляется
+const defaultDedupWindow = 5 * time.Minute
+
+// Notification describes a status transition of a plan or task.
+type Notification struct {
+	Entity         model.EntityType `json:"Entity"`
+	ID             string           `json:"ID"`
+	Name           string           `json:"Name,omitempty"`
+	Status         model.Status     `json:"Status"`
+	PreviousStatus model.Status     `json:"PreviousStatus,omitempty"`
+	Message        string           `json:"Message,omitempty"` // Последнее событие плана или задачи
+	Timestamp      time.Time        `json:"Timestamp"`
+}
+
+// Title is a one-line summary, e.g. "Task deploy-api failed".
+func (n *Notification) Title() string {
+	name := n.ID
+	if n.Name != "" {
+		name = n.Name
+	}
+	return fmt.Sprintf("%s %s %s", n.entityName(), name, n.Status)
+}
+
+func (n *Notification) entityName() string {
+	if n.Entity == model.EntityTask {
+		return "Task"
+	}
+	return "Plan"
+}
+
+// Sink delivers notifications. An error wrapped with api.Permanent is not
+// retried.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, n *Notification) error
+}
+
+// Rule selects the transitions to notify about; empty fields match
+// everything.
+type Rule struct {
+	Entities []model.EntityType `json:"Entities,omitempty"` // plan и/или task
+	IDs      []string           `json:"IDs,omitempty"`
+	Statuses []model.Status     `json:"Statuses,omitempty"` // Статус, в который перешла сущность
+	Sinks    []string           `json:"Sinks,omitempty"`    // Имена получателей, по умолчанию все
+}
+
+// DefaultRules notify every sink when a plan or task fails, rolls back,
+// waits for rollback approval or is interrupted.
+var DefaultRules = []Rule{{
+	Statuses: []model.Status{model.StatusFailed, model.StatusRollBack, model.StatusAwaitingApproval, model.StatusInterrupted},
+}}
+
+func (r *Rule) match(n *Notification) bool {
+	return (len(r.Entities) == 0 || contains(r.Entities, n.Entity)) &&
+		(len(r.IDs) == 0 || contains(r.IDs, n.ID)) &&
+		(len(r.Statuses) == 0 || contains(r.Statuses, n.Status))
+}
+
+// NotifierOptions provides configuration options for initializing a Notifier.
+type NotifierOptions struct {
+	Core   *inforo.Core
+	Sinks  []Sink
+	Rules  []Rule             // DefaultRules if empty
+	Retry  *model.RetryPolicy // 3 attempts with exponential backoff from 1s by default
+	Logger *logrus.Logger
+	// DedupWindow suppresses repeated transitions of a plan or task to the
+	// same status; 5 minutes by default, negative disables deduplication
+	DedupWindow time.Duration
+}
+
+// Notifier delivers the status transitions of a Core to sinks.
+type Notifier struct {
+	core        *inforo.Core
+	sinks       map[string]Sink
+	rules       []Rule
+	retry       *model.RetryPolicy
+	dedupWindow time.Duration
+	sub         api.Subscription
+	sent        map[string]time.Time // Время отправки по ключу получатель/сущность/статус
+	mu          sync.Mutex
+	wg          sync.WaitGroup
+	logger      *logrus.Logger
+}
+
+// NewNotifier creates a Notifier and subscribes it to the event bus of the
+// core; transitions are delivered once Run is called.
+func NewNotifier(opts NotifierOptions) (*Notifier, error) {
+	if opts.Core == nil || opts.Core.Bus == nil {
+		return nil, errors.New("notifier requires a core with an event bus")
+	}
+	if len(opts.Sinks) == 0 {
+		return nil, errors.New("notifier requires at least one sink")
+	}
+	if opts.Logger == nil {
+		opts.Logger = opts.Core.Logger
+	}
+	if len(opts.Rules) == 0 {
+		opts.Rules = DefaultRules
+	}
+	if opts.Retry == nil {
+		opts.Retry = &model.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     model.ExponentialBackoff,
+			Delay:       model.Duration(time.Second),
+		}
+	}
+	if opts.DedupWindow == 0 {
+		opts.DedupWindow = defaultDedupWindow
+	}
+
+	sinks := make(map[string]Sink, len(opts.Sinks))
+	for _, sink := range opts.Sinks {
+		if _, exists := sinks[sink.Name()]; exists {
+			return nil, fmt.Errorf("duplicate sink '%s'", sink.Name())
+		}
+		sinks[sink.Name()] = sink
+	}
+	for i, rule := range opts.Rules {
+		for _, entity := range rule.Entities {
+			if entity != model.EntityPlan && entity != model.EntityTask {
+				return nil, fmt.Errorf("rule %d: unsupported entity '%s'", i, entity)
+			}
+		}
+		for _, name := range rule.Sinks {
+			if _, exists := sinks[name]; !exists {
+				return nil, fmt.Errorf("rule %d: unknown sink '%s'", i, name)
+			}
+		}
+	}
+
+	return &Notifier{
+		core:        opts.Core,
+		sinks:       sinks,
+		rules:       opts.Rules,
+		retry:       opts.Retry,
+		dedupWindow: opts.DedupWindow,
+		sub: opts.Core.Bus.Subscribe(model.EventFilter{
+			Types:    []model.BusEventType{model.StatusChanged},
+			Entities: []model.EntityType{model.EntityPlan, model.EntityTask},
+		}),
+		sent:   make(map[string]time.Time),
+		logger: opts.Logger,
+	}, nil
+}
+
+// Run delivers notifications until ctx is cancelled, then unsubscribes and
+// waits for the deliveries in progress; their retries are cut short.
+func (n *Notifier) Run(ctx context.Context) error {
+	defer n.wg.Wait()
+	defer n.sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-n.sub.Events():
+			if !ok {
+				return nil
+			}
+			n.dispatch(ctx, event)
+		}
+	}
+}
+
+// dispatch sends the transition to the sinks of the matching rules.
+func (n *Notifier) dispatch(ctx context.Context, event model.BusEvent) {
+	notification := n.notification(event)
+
+	targets := make(map[string]bool)
+	for i := range n.rules {
+		rule := &n.rules[i]
+		if !rule.match(notification) {
+			continue
+		}
+		if len(rule.Sinks) == 0 {
+			for name := range n.sinks {
+				targets[name] = true
+			}
+		}
+		for _, name := range rule.Sinks {
+			targets[name] = true
+		}
+	}
+
+	for name := range targets {
+		key := fmt.Sprintf("%s/%s/%s/%s", name, notification.Entity, notification.ID, notification.Status)
+		if !n.claim(key) {
+			n.logger.Debugf("Notifier.dispatch() - %s: duplicate '%s' suppressed", name, notification.Title())
+			continue
+		}
+		sink := n.sinks[name]
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.deliver(ctx, sink, key, notification)
+		}()
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, sink Sink, key string, notification *Notification) {
+	err := inforo.Retry(ctx, n.retry, func(attempt int) error {
+		err := sink.Send(ctx, notification)
+		if err != nil {
+			n.logger.Warnf("Notifier.deliver() - %s: attempt %d of '%s' failed: %v", sink.Name(), attempt, notification.Title(), err)
+		}
+		return err
+	})
+	if err != nil {
+		n.logger.Errorf("Notifier.deliver() - %s: '%s' not delivered: %v", sink.Name(), notification.Title(), err)
+		// Неотправленное уведомление не должно глушить следующее такое же
+		n.mu.Lock()
+		delete(n.sent, key)
+		n.mu.Unlock()
+	}
+}
+
+// claim reports whether the notification with the key may be sent now and
+// records it as sent.
+func (n *Notifier) claim(key string) bool {
+	if n.dedupWindow < 0 {
+		return true
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	for k, sentAt := range n.sent {
+		if now.Sub(sentAt) >= n.dedupWindow {
+			delete(n.sent, k)
+		}
+	}
+	if _, exists := n.sent[key]; exists {
+		return false
+	}
+	n.sent[key] = now
+	return true
+}
+
+// notification builds the notification of the event with the name and the
+// last event of the plan or task.
+func (n *Notifier) notification(event model.BusEvent) *Notification {
+	notification := &Notification{
+		Entity:         event.Entity,
+		ID:             event.EntityID,
+		Status:         event.Status,
+		PreviousStatus: event.PreviousStatus,
+		Timestamp:      event.Timestamp,
+	}
+
+	var history *model.EventHistory
+	switch event.Entity {
+	case model.EntityTask:
+		if task, err := n.core.Tasks.Get(event.EntityID); err == nil {
+			task.MU.RLock()
+			notification.Name = task.Name
+			history = task.EventHistory
+			task.MU.RUnlock()
+		}
+	case model.EntityPlan:
+		if plan, err := n.core.Plans.Get(event.EntityID); err == nil {
+			plan.MU.RLock()
+			history = plan.EventHistory
+			plan.MU.RUnlock()
+		}
+	}
+	if history != nil {
+		history.MU.RLock()
+		if len(history.Event) != 0 {
+			notification.Message = history.Event[len(history.Event)-1].Message
+		}
+		history.MU.RUnlock()
+	}
+	return notification
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- deploy controller: fails to deploy the "broken" image ---
+type deployController struct{}
+
+func (d *deployController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "broken" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+func (d *deployController) ValideTask(r map[string]string) error      { return nil }
+func (d *deployController) ValideComponent(m map[string]string) error { return nil }
+func (d *deployController) CheckComponent(m map[string]string) error  { return nil }
+
+// recordingSink keeps the notifications it was sent.
+type recordingSink struct {
+	name string
+	mu   sync.Mutex
+	sent []*notify.Notification
+}
+
+func (r *recordingSink) Name() string { return r.name }
+
+func (r *recordingSink) Send(ctx context.Context, n *notify.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func (r *recordingSink) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	titles := make([]string, 0, len(r.sent))
+	for _, n := range r.sent {
+		titles = append(titles, n.Title())
+	}
+	return titles
+}
+
+// webhookServer answers with the statuses in turn, then with 200, and
+// passes the bodies it received to the channel.
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, chan []byte) {
+	bodies := make(chan []byte, 16)
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		mu.Lock()
+		defer mu.Unlock()
+		if len(statuses) != 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func setupCore(t *testing.T) *inforo.Core {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	_, err := c.Components.Register(&model.Component{ID: "api", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	return c
+}
+
+func registerTask(t *testing.T, c *inforo.Core, id, image string) {
+	_, err := c.Tasks.Register(&model.Task{ID: id, Name: id, Type: model.UpdateTask, Components: []string{"api"},
+		Metadata: map[string]string{"image": image}})
+	require.NoError(t, err)
+}
+
+// startNotifier runs the notifier until the returned stop is called.
+func startNotifier(t *testing.T, opts notify.NotifierOptions) (stop func()) {
+	n, err := notify.NewNotifier(opts)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.Run(ctx)
+	}()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func receive(t *testing.T, bodies chan []byte) []byte {
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+		return nil
+	}
+}
+
+func TestWebhook_TemplatedBodyOnTaskFailure(t *testing.T) {
+	c := setupCore(t)
+	srv, bodies := webhookServer(t)
+	webhook, err := notify.NewWebhookSink(notify.WebhookOptions{
+		URL:      srv.URL,
+		Template: `{"summary": {{json .Title}}, "status": {{json .Status}}, "from": {{json .PreviousStatus}}}`,
+	})
+	require.NoError(t, err)
+	startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{webhook}})
+
+	registerTask(t, c, "deploy-api", "broken")
+	_, err = c.Tasks.Fork("deploy-api", "")
+	require.Error(t, err)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(receive(t, bodies), &body))
+	assert.Equal(t, map[string]string{"summary": "Task deploy-api failed", "status": "failed", "from": "running"}, body)
+}
+
+func TestWebhook_InvalidTemplate(t *testing.T) {
+	_, err := notify.NewWebhookSink(notify.WebhookOptions{URL: "http://localhost", Template: `{"a": {{.Title}`})
+	require.ErrorContains(t, err, "invalid webhook template")
+
+	webhook, err := notify.NewWebhookSink(notify.WebhookOptions{URL: "http://localhost", Template: `{"a": {{.Title}}}`})
+	require.NoError(t, err)
+	err = webhook.Send(context.Background(), &notify.Notification{Entity: model.EntityTask, ID: "x", Status: model.StatusFailed})
+	require.EqualError(t, err, "webhook template produced invalid JSON")
+}
+
+func TestSlack_PlanFailurePayload(t *testing.T) {
+	c := setupCore(t)
+	srv, bodies := webhookServer(t)
+	slack, err := notify.NewSlackSink(notify.SlackOptions{WebhookURL: srv.URL, Channel: "#oncall"})
+	require.NoError(t, err)
+	startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{slack},
+		Rules: []notify.Rule{{Entities: []model.EntityType{model.EntityPlan}, Statuses: []model.Status{model.StatusFailed}}}})
+
+	plan, err := c.Plans.Register([]*model.Task{{ID: "deploy-api", Type: model.UpdateTask, Components: []string{"api"},
+		Metadata: map[string]string{"image": "broken"}}})
+	require.NoError(t, err)
+	_, err = c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+
+	var msg notify.SlackMessage
+	require.NoError(t, json.Unmarshal(receive(t, bodies), &msg))
+	assert.Equal(t, "Plan "+plan.ID+" failed", msg.Text)
+	assert.Equal(t, "#oncall", msg.Channel)
+	require.Len(t, msg.Attachments, 1)
+	assert.Equal(t, "danger", msg.Attachments[0].Color)
+	assert.Equal(t, "running → failed", msg.Attachments[0].Fields[0].Value)
+}
+
+func TestNotifier_RetriesFailedDelivery(t *testing.T) {
+	c := setupCore(t)
+	srv, bodies := webhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	webhook, err := notify.NewWebhookSink(notify.WebhookOptions{URL: srv.URL})
+	require.NoError(t, err)
+	startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{webhook},
+		Retry: &model.RetryPolicy{MaxAttempts: 3, Delay: model.Duration(10 * time.Millisecond)}})
+
+	registerTask(t, c, "deploy-api", "broken")
+	c.Tasks.Fork("deploy-api", "")
+
+	for i := 0; i < 3; i++ {
+		var n notify.Notification
+		require.NoError(t, json.Unmarshal(receive(t, bodies), &n))
+		assert.Equal(t, "deploy-api", n.ID)
+		assert.Equal(t, model.StatusFailed, n.Status)
+	}
+}
+
+func TestNotifier_ClientErrorIsNotRetried(t *testing.T) {
+	c := setupCore(t)
+	srv, bodies := webhookServer(t, http.StatusBadRequest)
+	webhook, err := notify.NewWebhookSink(notify.WebhookOptions{URL: srv.URL})
+	require.NoError(t, err)
+	stop := startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{webhook},
+		Retry: &model.RetryPolicy{MaxAttempts: 3, Delay: model.Duration(10 * time.Millisecond)}})
+
+	registerTask(t, c, "deploy-api", "broken")
+	c.Tasks.Fork("deploy-api", "")
+	receive(t, bodies)
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	assert.Empty(t, bodies)
+}
+
+func TestNotifier_DeduplicatesRepeatedTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		window time.Duration
+		sent   []string
+	}{
+		{"within window", 0, []string{"Task deploy-api failed", "Task other failed"}},
+		{"disabled", -1, []string{"Task deploy-api failed", "Task deploy-api failed", "Task other failed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := setupCore(t)
+			sink := &recordingSink{name: "oncall"}
+			stop := startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{sink}, DedupWindow: tc.window})
+
+			registerTask(t, c, "deploy-api", "broken")
+			registerTask(t, c, "other", "broken")
+			c.Tasks.Fork("deploy-api", "")
+			c.Tasks.Fork("deploy-api", "")
+			c.Tasks.Fork("other", "")
+
+			// События разбираются по порядку: к отправке "other" дубликаты уже
+			// отброшены или отправляются, stop дожидается отправки
+			require.Eventually(t, func() bool {
+				return contains(sink.titles(), "Task other failed")
+			}, 5*time.Second, 10*time.Millisecond)
+			stop()
+			assert.ElementsMatch(t, tc.sent, sink.titles())
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNotifier_RulesSelectSinks(t *testing.T) {
+	c := setupCore(t)
+	oncall := &recordingSink{name: "oncall"}
+	audit := &recordingSink{name: "audit"}
+	stop := startNotifier(t, notify.NotifierOptions{Core: c, Sinks: []notify.Sink{oncall, audit}, Rules: []notify.Rule{
+		{Entities: []model.EntityType{model.EntityPlan}, Statuses: []model.Status{model.StatusFailed}, Sinks: []string{"oncall"}},
+		{IDs: []string{"smoke"}, Statuses: []model.Status{model.StatusSuccess}, Sinks: []string{"audit"}},
+	}})
+
+	plan, err := c.Plans.Register([]*model.Task{{ID: "deploy-api", Type: model.UpdateTask, Components: []string{"api"},
+		Metadata: map[string]string{"image": "broken"}}})
+	require.NoError(t, err)
+	c.Plans.Run(plan.ID, "")
+	registerTask(t, c, "smoke", "api:1.0")
+	_, err = c.Tasks.Fork("smoke", "")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(audit.titles()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	stop()
+	assert.Equal(t, []string{"Plan " + plan.ID + " failed"}, oncall.titles())
+	assert.Equal(t, []string{"Task smoke success"}, audit.titles())
+}
+
+func TestNewNotifier_InvalidOptions(t *testing.T) {
+	c := setupCore(t)
+	sink := &recordingSink{name: "oncall"}
+
+	_, err := notify.NewNotifier(notify.NotifierOptions{Core: c})
+	require.EqualError(t, err, "notifier requires at least one sink")
+	_, err = notify.NewNotifier(notify.NotifierOptions{Core: c, Sinks: []notify.Sink{sink}, Rules: []notify.Rule{{Sinks: []string{"pager"}}}})
+	require.EqualError(t, err, "rule 0: unknown sink 'pager'")
+	_, err = notify.NewNotifier(notify.NotifierOptions{Core: c, Sinks: []notify.Sink{sink},
+		Rules: []notify.Rule{{Entities: []model.EntityType{model.EntityCheck}}}})
+	require.EqualError(t, err, "rule 0: unsupported entity 'check'")
+}
+
+func TestEmail_Message(t *testing.T) {
+	var sentTo []string
+	var message string
+	email, err := notify.NewEmailSink(notify.EmailOptions{
+		Addr: "smtp.example.com:25",
+		From: "inforo@example.com",
+		To:   []string{"oncall@example.com"},
+		SendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			sentTo, message = to, string(msg)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	err = email.Send(context.Background(), &notify.Notification{
+		Entity: model.EntityTask, ID: "deploy-api", Status: model.StatusRollBack, PreviousStatus: model.StatusFailed,
+		Message: "RollBack task!", Timestamp: time.Now(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"oncall@example.com"}, sentTo)
+	assert.Contains(t, message, "Subject: [inforo] Task deploy-api rollback\r\n")
+	assert.Contains(t, message, "Status:  failed -> rollback\r\n")
+	assert.Contains(t, message, "Event:   RollBack task!\r\n")
+}
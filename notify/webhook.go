@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// WebhookOptions provides configuration options for initializing a WebhookSink.
+type WebhookOptions struct {
+	Name string // "webhook" by default
+	URL  string
+	// Template renders the JSON body from the Notification; the "json"
+	// function encodes a value, e.g. {"text": {{json .Title}}}. Without a
+	// template the Notification itself is sent.
+	Template   string
+	Headers    map[string]string
+	HTTPClient *http.Client // http.DefaultClient by default
+}
+
+// WebhookSink posts notifications as JSON to a URL.
+type WebhookSink struct {
+	name     string
+	url      string
+	template *template.Template
+	headers  map[string]string
+	http     *http.Client
+}
+
+func NewWebhookSink(opts WebhookOptions) (*WebhookSink, error) {
+	if opts.URL == "" {
+		return nil, errors.New("webhook requires a URL")
+	}
+	if opts.Name == "" {
+		opts.Name = "webhook"
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	sink := &WebhookSink{
+		name:    opts.Name,
+		url:     opts.URL,
+		headers: opts.Headers,
+		http:    opts.HTTPClient,
+	}
+	if opts.Template != "" {
+		tmpl, err := template.New(opts.Name).Funcs(template.FuncMap{"json": toJSON}).Parse(opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		sink.template = tmpl
+	}
+	return sink, nil
+}
+
+func (w *WebhookSink) Name() string {
+	return w.name
+}
+
+func (w *WebhookSink) Send(ctx context.Context, n *Notification) error {
+	if w.template == nil {
+		return postJSON(ctx, w.http, w.url, w.headers, n)
+	}
+
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, n); err != nil {
+		return api.Permanent(fmt.Errorf("failed to render webhook template: %w", err))
+	}
+	if !json.Valid(body.Bytes()) {
+		return api.Permanent(errors.New("webhook template produced invalid JSON"))
+	}
+	return post(ctx, w.http, w.url, w.headers, body.Bytes())
+}
+
+// SlackOptions provides configuration options for initializing a SlackSink.
+type SlackOptions struct {
+	Name       string // "slack" by default
+	WebhookURL string // Incoming webhook URL
+	Channel    string // Channel of the webhook if empty
+	Username   string
+	HTTPClient *http.Client // http.DefaultClient by default
+}
+
+// SlackSink posts notifications to a Slack incoming webhook.
+type SlackSink struct {
+	name     string
+	url      string
+	channel  string
+	username string
+	http     *http.Client
+}
+
+// SlackMessage is the payload of a Slack incoming webhook.
+type SlackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+type SlackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Fields []SlackField `json:"fields,omitempty"`
+	Ts     int64        `json:"ts,omitempty"`
+}
+
+type SlackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+func NewSlackSink(opts SlackOptions) (*SlackSink, error) {
+	if opts.WebhookURL == "" {
+		return nil, errors.New("slack requires a webhook URL")
+	}
+	if opts.Name == "" {
+		opts.Name = "slack"
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &SlackSink{
+		name:     opts.Name,
+		url:      opts.WebhookURL,
+		channel:  opts.Channel,
+		username: opts.Username,
+		http:     opts.HTTPClient,
+	}, nil
+}
+
+func (s *SlackSink) Name() string {
+	return s.name
+}
+
+func (s *SlackSink) Send(ctx context.Context, n *Notification) error {
+	fields := []SlackField{
+		{Title: "Status", Value: fmt.Sprintf("%s → %s", n.PreviousStatus, n.Status), Short: true},
+		{Title: "ID", Value: n.ID, Short: true},
+	}
+	if n.Message != "" {
+		fields = append(fields, SlackField{Title: "Last event", Value: n.Message})
+	}
+	return postJSON(ctx, s.http, s.url, nil, &SlackMessage{
+		Text:     n.Title(),
+		Channel:  s.channel,
+		Username: s.username,
+		Attachments: []SlackAttachment{{
+			Color:  statusColor(n.Status),
+			Fields: fields,
+			Ts:     n.Timestamp.Unix(),
+		}},
+	})
+}
+
+func statusColor(status model.Status) string {
+	switch status {
+	case model.StatusSuccess:
+		return "good"
+	case model.StatusFailed, model.StatusRollBack, model.StatusInterrupted:
+		return "danger"
+	default:
+		return "warning"
+	}
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return api.Permanent(err)
+	}
+	return post(ctx, client, url, headers, data)
+}
+
+// post sends the JSON body. Client errors other than 408 and 429 are
+// permanent, another attempt will not fix them.
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return api.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(message))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return api.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package inforo
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseGate holds goroutines at a boundary while execution is paused.
+// The zero value is not usable; create gates with newPauseGate.
+type pauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+func newPauseGate() *pauseGate {
+	return &pauseGate{resume: make(chan struct{})}
+}
+
+// Pause closes the gate. It returns false if the gate was already closed.
+func (g *pauseGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.resume = make(chan struct{})
+	return true
+}
+
+// Resume opens the gate and releases every waiter. It returns false if the
+// gate was not closed.
+func (g *pauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resume)
+	return true
+}
+
+// Paused reports whether the gate is closed.
+func (g *pauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks while the gate is closed. It returns the context error if ctx
+// is done before the gate opens.
+func (g *pauseGate) Wait(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		paused, resume := g.paused, g.resume
+		g.mu.Unlock()
+
+		if !paused {
+			return ctx.Err()
+		}
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
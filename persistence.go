@@ -0,0 +1,62 @@
+package inforo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/laplasd/inforo/api"
+)
+
+// Collections used by the default registries in api.Storage.
+const (
+	componentsCollection  = "components"
+	monitoringsCollection = "monitorings"
+	tasksCollection       = "tasks"
+	plansCollection       = "plans"
+)
+
+// saveDocument stores v as JSON under collection/id. A nil storage disables
+// persistence, which keeps registries built without one purely in memory.
+func saveDocument(store api.Storage, collection string, id string, v interface{}) error {
+	if store == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", collection, id, err)
+	}
+	return store.Put(collection, id, data)
+}
+
+// deleteDocument removes collection/id from the storage.
+func deleteDocument(store api.Storage, collection string, id string) error {
+	if store == nil {
+		return nil
+	}
+	return store.Delete(collection, id)
+}
+
+// loadDocuments calls fn for every document of the collection, ordered by ID.
+func loadDocuments(store api.Storage, collection string, fn func(id string, data []byte) error) error {
+	if store == nil {
+		return nil
+	}
+	docs, err := store.List(collection)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := fn(id, docs[id]); err != nil {
+			return fmt.Errorf("failed to load %s/%s: %w", collection, id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package inforo_test
+
+import (
+	"testing"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileCore(t *testing.T, dir string) *inforo.Core {
+	store, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+
+	c := inforo.NewCore(inforo.CoreOptions{Storage: store})
+	require.NoError(t, c.Controllers.Register("mock", &mockController{}))
+	return c
+}
+
+func TestNewCore_ReloadsStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	c := newFileCore(t, dir)
+	_, err := c.Components.Register(&model.Component{
+		ID:       "component-1",
+		Type:     "mock",
+		Version:  "1.0.0",
+		Metadata: map[string]string{"host": "web-1"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Components.Disable("component-1"))
+
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"component-1"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+	_, err = c.Plans.Run(plan.ID, "")
+	require.NoError(t, err)
+
+	// Новый Core поверх того же каталога
+	restored := newFileCore(t, dir)
+
+	comp, err := restored.Components.Get("component-1")
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", comp.Metadata["host"])
+	assert.Equal(t, model.StatusDisable, comp.StatusHistory.LastStatus)
+	assert.NotEmpty(t, comp.EventHistory.Event)
+
+	task, err := restored.Tasks.Get("task-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+	assert.NotEmpty(t, task.StatusHistory.Previous)
+
+	restoredPlan, err := restored.Plans.Get(plan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, restoredPlan.StatusHistory.LastStatus)
+	assert.Len(t, restoredPlan.RollbackStack, 2)
+	require.Len(t, restoredPlan.TaskGraphs, 1)
+	// Задачи графа — те же объекты, что и в реестре задач
+	assert.Same(t, task, restoredPlan.TaskGraphs[0].Tasks["task-2"])
+}
+
+func TestNewCore_DeletePersists(t *testing.T) {
+	dir := t.TempDir()
+
+	c := newFileCore(t, dir)
+	_, err := c.Components.Register(&model.Component{ID: "component-1", Type: "mock", Version: "1.0.0"})
+	require.NoError(t, err)
+	require.NoError(t, c.Components.Delete("component-1"))
+
+	restored := newFileCore(t, dir)
+	_, err = restored.Components.Get("component-1")
+	assert.EqualError(t, err, "component not found")
+}
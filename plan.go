@@ -1,6 +1,8 @@
 package inforo
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -12,35 +14,182 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PlanRegistry struct {
-	plans      map[string]*model.Plan
-	Components api.ComponentRegistry
-	Tasks      api.TaskRegistry
+	plans       map[string]*model.Plan
+	runs        map[string]*planRun
+	Components  api.ComponentRegistry
+	Controllers api.ControllerRegistry
+	Tasks       api.TaskRegistry
+	Executions  api.ExecutionRegistry
+	storage     api.Storage
+	// maxParallelism — ограничение по умолчанию для планов без своего MaxParallelism
+	maxParallelism int
 	*StatusManager
 	*Events
+	bus    api.EventBus
+	tracer trace.Tracer
 	mu     *sync.RWMutex
 	logger *logrus.Logger
 }
 
+// planRun holds the control handles of a plan execution in progress.
+type planRun struct {
+	executionID string
+	cancel      context.CancelFunc
+	gate        *pauseGate
+	done        chan struct{}
+	// slots ограничивает параллелизм всего плана; nil — без ограничения
+	slots chan struct{}
+	// resume — пропускать задачи, уже завершённые успешно в предыдущем запуске
+	resume bool
+}
+
 type PlanRegistryOptions struct {
 	Logger        *logrus.Logger
 	Components    api.ComponentRegistry
+	Controllers   api.ControllerRegistry
 	Tasks         api.TaskRegistry
+	Executions    api.ExecutionRegistry
+	Storage       api.Storage
 	StatusManager *StatusManager
 	EventManager  *Events
+	Bus           api.EventBus // Получает изменения статусов и событий, если задан
+	// MaxParallelism limits concurrent tasks of plans that do not set their
+	// own limit; 0 means unlimited
+	MaxParallelism int
+	// TracerProvider creates the spans of plan executions, the global
+	// provider of otel by default
+	TracerProvider trace.TracerProvider
 }
 
 func NewPlanRegistry(opts PlanRegistryOptions) (api.PlanRegistry, error) {
-	return &PlanRegistry{
-		mu:            &sync.RWMutex{},
-		logger:        opts.Logger,
-		StatusManager: opts.StatusManager,
-		plans:         make(map[string]*model.Plan),
-		Components:    opts.Components,
-		Tasks:         opts.Tasks,
-	}, nil
+	if opts.Executions == nil {
+		opts.Executions, _ = NewExecutionRegistry(ExecutionRegistryOptions{Logger: opts.Logger})
+	}
+	pr := &PlanRegistry{
+		mu:             &sync.RWMutex{},
+		logger:         opts.Logger,
+		StatusManager:  opts.StatusManager,
+		bus:            opts.Bus,
+		tracer:         newTracer(opts.TracerProvider),
+		plans:          make(map[string]*model.Plan),
+		runs:           make(map[string]*planRun),
+		Components:     opts.Components,
+		Controllers:    opts.Controllers,
+		Tasks:          opts.Tasks,
+		Executions:     opts.Executions,
+		storage:        opts.Storage,
+		maxParallelism: opts.MaxParallelism,
+	}
+	if err := pr.load(); err != nil {
+		return pr, err
+	}
+	return pr, nil
+}
+
+// planRecord is the persisted form of a plan. Tasks are persisted by the
+// task registry, so graphs only reference them by ID.
+type planRecord struct {
+	ID             string                      `json:"ID"`
+	TaskGraphs     []*graphRecord              `json:"TaskGraphs"`
+	RollbackStack  []*model.RollbackCheckpoint `json:"RollbackStack,omitempty"`
+	MaxParallelism int                         `json:"MaxParallelism,omitempty"`
+	StatusHistory  *model.StatusHistory        `json:"StatusHistory,omitempty"`
+	EventHistory   *model.EventHistory         `json:"EventHistory,omitempty"`
+}
+
+type graphRecord struct {
+	RootTaskID     string              `json:"RootTaskID"`
+	TaskIDs        []string            `json:"TaskIDs"`
+	Dependencies   map[string][]string `json:"Dependencies"`
+	Dependents     map[string][]string `json:"Dependents"`
+	MaxParallelism int                 `json:"MaxParallelism,omitempty"`
+}
+
+// load restores the plans saved in the storage, resolving their tasks
+// through the task registry.
+func (pr *PlanRegistry) load() error {
+	return loadDocuments(pr.storage, plansCollection, func(id string, data []byte) error {
+		record := &planRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return err
+		}
+
+		plan := &model.Plan{
+			ID:             record.ID,
+			RollbackStack:  record.RollbackStack,
+			MaxParallelism: record.MaxParallelism,
+			StatusHistory:  record.StatusHistory,
+			EventHistory:   record.EventHistory,
+		}
+		if plan.StatusHistory == nil {
+			plan.StatusHistory = pr.NewStatus(model.StatusCreated)
+		}
+		if plan.EventHistory == nil {
+			plan.EventHistory = &model.EventHistory{}
+		}
+
+		for _, g := range record.TaskGraphs {
+			graph := &model.TaskGraph{
+				RootTaskID:     g.RootTaskID,
+				Tasks:          make(map[string]*model.Task, len(g.TaskIDs)),
+				Dependencies:   g.Dependencies,
+				Dependents:     g.Dependents,
+				MaxParallelism: g.MaxParallelism,
+			}
+			for _, taskID := range g.TaskIDs {
+				task, err := pr.Tasks.Get(taskID)
+				if err != nil {
+					return fmt.Errorf("task %s of plan %s: %w", taskID, record.ID, err)
+				}
+				graph.Tasks[taskID] = task
+			}
+			plan.TaskGraphs = append(plan.TaskGraphs, graph)
+		}
+
+		pr.plans[plan.ID] = plan
+		return nil
+	})
+}
+
+// save persists the plan; callers hold pr.mu.
+func (pr *PlanRegistry) save(plan *model.Plan) error {
+	record := &planRecord{
+		ID:             plan.ID,
+		RollbackStack:  plan.RollbackStack,
+		MaxParallelism: plan.MaxParallelism,
+		StatusHistory:  plan.StatusHistory,
+		EventHistory:   plan.EventHistory,
+	}
+	for _, graph := range plan.TaskGraphs {
+		g := &graphRecord{
+			RootTaskID:     graph.RootTaskID,
+			TaskIDs:        make([]string, 0, len(graph.Tasks)),
+			Dependencies:   graph.Dependencies,
+			Dependents:     graph.Dependents,
+			MaxParallelism: graph.MaxParallelism,
+		}
+		for taskID := range graph.Tasks {
+			g.TaskIDs = append(g.TaskIDs, taskID)
+		}
+		sort.Strings(g.TaskIDs)
+		record.TaskGraphs = append(record.TaskGraphs, g)
+	}
+
+	plan.EventHistory.MU.RLock()
+	defer plan.EventHistory.MU.RUnlock()
+	return saveDocument(pr.storage, plansCollection, plan.ID, record)
+}
+
+// saveState persists the plan after a state change, logging failures
+// instead of interrupting the execution; callers hold pr.mu.
+func (pr *PlanRegistry) saveState(plan *model.Plan) {
+	if err := pr.save(plan); err != nil {
+		pr.logger.Errorf("PlanRegistry.saveState() - plan.ID: %s, error: %v", plan.ID, err)
+	}
 }
 
 func (pr *PlanRegistry) Register(tasks []*model.Task) (*model.Plan, error) {
@@ -105,9 +254,13 @@ func (pr *PlanRegistry) Register(tasks []*model.Task) (*model.Plan, error) {
 	}
 
 	// 5. Сохранение и логирование
+	if err := pr.save(plan); err != nil {
+		return nil, fmt.Errorf("failed to save plan: %w", err)
+	}
 	pr.plans[plan.ID] = plan
 	pr.logger.Infof("Created new plan %s with %d independent task graphs",
 		plan.ID, len(graphs))
+	publishStatus(pr.bus, model.EntityPlan, plan.ID, "", plan.StatusHistory)
 
 	return plan, nil
 }
@@ -213,7 +366,7 @@ func (pr *PlanRegistry) Get(id string) (*model.Plan, error) {
 }
 
 // UpdatePlan updates tasks in a plan (e.g. reordering, changing metadata).
-func (pr *PlanRegistry) Update(id string, updated model.Plan) error {
+func (pr *PlanRegistry) Update(id string, updated *model.Plan) error {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
 
@@ -222,12 +375,12 @@ func (pr *PlanRegistry) Update(id string, updated model.Plan) error {
 		return errors.New("plan not found")
 	}
 
-	if updated.StatusHistory.LastStatus != "" {
-		plan.StatusHistory = pr.StatusManager.NextStatus(updated.StatusHistory.LastStatus, plan.StatusHistory)
+	if updated != nil && updated.StatusHistory != nil && updated.StatusHistory.LastStatus != "" {
+		pr.setStatus(plan, updated.StatusHistory.LastStatus)
 	}
 
 	pr.plans[id] = plan
-	return nil
+	return pr.save(plan)
 }
 
 // DeletePlan removes a plan from storage.
@@ -238,6 +391,9 @@ func (pr *PlanRegistry) Delete(id string) error {
 	if _, exists := pr.plans[id]; !exists {
 		return errors.New("plan not found")
 	}
+	if err := deleteDocument(pr.storage, plansCollection, id); err != nil {
+		return err
+	}
 	delete(pr.plans, id)
 	return nil
 }
@@ -262,10 +418,13 @@ func (pr *PlanRegistry) RunAsync(planID string, executionID string) (string, err
 	pr.mu.Lock()
 
 	_, exists := pr.plans[planID]
+	pr.mu.Unlock()
 	if !exists {
 		return "", errors.New("plan not found")
 	}
-	pr.mu.Unlock()
+	if err := pr.Executions.Reserve(executionID, model.PlanExecution, planID, ""); err != nil {
+		return "", err
+	}
 	// Start execution in a goroutine
 	go pr.Run(planID, executionID)
 
@@ -274,29 +433,87 @@ func (pr *PlanRegistry) RunAsync(planID string, executionID string) (string, err
 }
 
 func (pr *PlanRegistry) Run(planID string, executionID string) (string, error) {
+	return pr.RunContext(context.Background(), planID, executionID)
+}
+
+// RunContext executes the plan like Run; cancelling ctx aborts every task
+// graph and the controller calls currently in flight.
+func (pr *PlanRegistry) RunContext(ctx context.Context, planID string, executionID string) (string, error) {
 	if executionID == "" {
 		executionID = uuid.New().String()
 	}
 	pr.logger.Infof("[%s] PlanRegistry.Run() - call()", executionID)
 
-	plan, err := pr.Get(planID)
+	owner := pr.Executions.Start(executionID, model.PlanExecution, planID, "")
+	run, ctx, err := pr.startRun(ctx, planID, executionID, false)
 	if err != nil {
-		pr.logger.Errorf("[%s] Plan not found during execution", executionID)
+		if owner {
+			pr.Executions.Finish(executionID, model.StatusFailed, err)
+		}
 		return "", err
 	}
+	return pr.execute(ctx, planID, run)
+}
+
+// startRun checks that the plan may be started, moves it to running and
+// registers the control handles of the new execution.
+func (pr *PlanRegistry) startRun(ctx context.Context, planID, executionID string, resume bool) (*planRun, context.Context, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	plan, exists := pr.plans[planID]
+	if !exists {
+		pr.logger.Errorf("[%s] Plan not found during execution", executionID)
+		return nil, nil, errors.New("plan not found")
+	}
 	// Check if plan is already running
 	currentStatus := plan.StatusHistory.LastStatus
-	if currentStatus == model.StatusRunning {
-		return "", errors.New("plan is already running")
+	if _, active := pr.runs[planID]; active || currentStatus == model.StatusRunning {
+		return nil, nil, errors.New("plan is already running")
 	}
 	if currentStatus == model.StatusSuccess {
-		return "", errors.New("cannot run already completed plan")
+		return nil, nil, errors.New("cannot run already completed plan")
 	}
-	pr.mu.Lock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	run := &planRun{
+		executionID: executionID,
+		cancel:      cancel,
+		gate:        newPauseGate(),
+		done:        make(chan struct{}),
+		resume:      resume,
+	}
+	limit := plan.MaxParallelism
+	if limit == 0 {
+		limit = pr.maxParallelism
+	}
+	if limit > 0 {
+		run.slots = make(chan struct{}, limit)
+	}
+	pr.runs[planID] = run
+
 	// Update plan status
-	plan.StatusHistory = pr.StatusManager.NextStatus(model.StatusRunning, plan.StatusHistory)
+	pr.setStatus(plan, model.StatusRunning)
 	pr.plans[planID] = plan
-	pr.mu.Unlock()
+	pr.saveState(plan)
+	return run, ctx, nil
+}
+
+// execute runs every task graph of the plan and records the final status.
+func (pr *PlanRegistry) execute(ctx context.Context, planID string, run *planRun) (_ string, executionErr error) {
+	executionID := run.executionID
+	defer run.cancel()
+
+	ctx, span := pr.tracer.Start(ctx, "PlanRegistry.Run", trace.WithAttributes(
+		attrPlanID.String(planID), attrExecutionID.String(executionID)))
+	defer func() { endSpan(span, executionErr) }()
+
+	plan, err := pr.Get(planID)
+	if err != nil {
+		pr.finishRun(planID)
+		pr.Executions.Finish(executionID, model.StatusFailed, err)
+		return "", err
+	}
 
 	// Канал для обработки ошибок выполнения
 	errChan := make(chan error, len(plan.TaskGraphs))
@@ -307,7 +524,7 @@ func (pr *PlanRegistry) Run(planID string, executionID string) (string, error) {
 		wg.Add(1)
 		go func(g *model.TaskGraph) {
 			defer wg.Done()
-			if err := pr.executeTaskGraph(planID, executionID, g); err != nil {
+			if err := pr.executeTaskGraph(ctx, planID, run, g); err != nil {
 				errChan <- fmt.Errorf("graph %s failed: %w", g.RootTaskID, err)
 			}
 		}(graph)
@@ -320,7 +537,6 @@ func (pr *PlanRegistry) Run(planID string, executionID string) (string, error) {
 	}()
 
 	// Обрабатываем результаты выполнения
-	var executionErr error
 	for err := range errChan {
 		if executionErr == nil {
 			executionErr = err
@@ -332,150 +548,343 @@ func (pr *PlanRegistry) Run(planID string, executionID string) (string, error) {
 	// Обновляем статус плана
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
+	delete(pr.runs, planID)
+	defer close(run.done)
 
 	plan = pr.plans[planID] // Перечитываем план, так как он мог измениться
-	if executionErr != nil {
-		plan.StatusHistory = pr.StatusManager.NextStatus(model.StatusFailed, plan.StatusHistory)
+	if executionErr != nil && ctx.Err() != nil {
+		// Stop уже выставил статус stopped — не дублируем переход
+		if plan.StatusHistory.LastStatus != model.StatusStopped {
+			pr.setStatus(plan, model.StatusStopped)
+		}
+		pr.logger.Warnf("[%s] Plan execution cancelled: %v", executionID, executionErr)
+	} else if executionErr != nil {
+		pr.setStatus(plan, model.StatusFailed)
 		pr.logger.Errorf("[%s] Plan execution failed: %v", executionID, executionErr)
 	} else {
-		plan.StatusHistory = pr.StatusManager.NextStatus(model.StatusSuccess, plan.StatusHistory)
+		pr.setStatus(plan, model.StatusSuccess)
 		pr.logger.Infof("[%s] Plan executed successfully", executionID)
 	}
 	pr.plans[planID] = plan
+	pr.saveState(plan)
+	pr.Executions.Finish(executionID, plan.StatusHistory.LastStatus, executionErr)
+
+	return executionID, executionErr
+}
 
-	return "", nil
+// finishRun drops the control handles of a plan execution.
+func (pr *PlanRegistry) finishRun(planID string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if run, active := pr.runs[planID]; active {
+		delete(pr.runs, planID)
+		close(run.done)
+	}
 }
 
-func (pr *PlanRegistry) executeTaskGraph(planID, executionID string, graph *model.TaskGraph) error {
+func (pr *PlanRegistry) executeTaskGraph(ctx context.Context, planID string, run *planRun, graph *model.TaskGraph) (err error) {
+	executionID := run.executionID
+	ctx, span := pr.tracer.Start(ctx, "PlanRegistry.executeTaskGraph", trace.WithAttributes(
+		attrPlanID.String(planID), attrGraphID.String(graph.RootTaskID)))
+	defer func() { endSpan(span, err) }()
 	pr.logger.Infof("[%s] PlanRegistry.executeTaskGraph() Executing task graph with root %s", executionID, graph.RootTaskID)
 
-	// Получаем топологический порядок выполнения задач
-	executionOrder, err := pr.getExecutionOrder(graph.Dependencies)
-	if err != nil {
-		return fmt.Errorf("failed to get execution order: %w", err)
+	// Сколько незавершённых зависимостей у каждой задачи
+	waiting := make(map[string]int, len(graph.Tasks))
+	var ready []string
+	for taskID := range graph.Tasks {
+		waiting[taskID] = len(graph.Dependencies[taskID])
+		if waiting[taskID] == 0 {
+			ready = append(ready, taskID)
+		}
 	}
-	pr.logger.Infof("[%s] PlanRegistry.executeTaskGraph() executionOrder %s", executionID, executionOrder)
 
-	// Выполняем задачи в порядке зависимостей
-	for _, taskID := range executionOrder {
-		pr.logger.Infof("[%s] PlanRegistry.executeTaskGraph() Exec task %s", executionID, taskID)
-		task := graph.Tasks[taskID]
-
-		// Создаем точку отката перед выполнением задачи
-		checkpoint := &model.RollbackCheckpoint{
-			GraphID: graph.RootTaskID,
-			TaskID:  task.ID,
-			//State:     pr.getCurrentState(task.Components),
-			Timestamp: time.Now(),
+	type taskResult struct {
+		taskID string
+		err    error
+	}
+	results := make(chan taskResult)
+	running := 0
+	// completed — задачи в порядке завершения, откат идёт в обратном порядке
+	var completed []string
+	succeeded := make(map[string]bool, len(graph.Tasks))
+	var failedTasks []string
+	var graphErr error
+
+	// Задача завершена (с любым результатом): освобождаем зависимые от неё
+	finished := func(taskID string) {
+		for _, dependentID := range graph.Dependents[taskID] {
+			waiting[dependentID]--
+			if waiting[dependentID] == 0 {
+				ready = append(ready, dependentID)
+			}
 		}
+	}
 
-		// Выполняем задачу
-		if _, err := pr.Tasks.Fork(task.ID, executionID); err != nil {
-			pr.logger.Errorf("[%s] Task %s failed: %v", executionID, taskID, err)
+	for {
+		// Запускаем все готовые задачи в пределах ограничения графа
+		for len(ready) > 0 && ctx.Err() == nil {
+			if graph.MaxParallelism > 0 && running >= graph.MaxParallelism {
+				break
+			}
+			// Чтобы порядок запуска был детерминированным
+			sort.Strings(ready)
+			taskID := ready[0]
+			ready = ready[1:]
+			task := graph.Tasks[taskID]
+
+			// Strict-зависимость не выполнена — задачу не запускаем
+			if dependencyID := failedStrictDependency(task, succeeded); dependencyID != "" {
+				pr.logger.Warnf("[%s] PlanRegistry.executeTaskGraph() Skip task %s: strict dependency %s did not succeed", executionID, taskID, dependencyID)
+				graphErr = errors.Join(graphErr, fmt.Errorf("task %s skipped: strict dependency %s did not succeed", taskID, dependencyID))
+				pr.markSkipped(task, dependencyID)
+				finished(taskID)
+				continue
+			}
 
-			// Пытаемся откатить выполненные задачи
-			if rollbackErr := pr.rollbackGraph(planID, executionID, graph, taskID); rollbackErr != nil {
-				return fmt.Errorf("execution failed: %v, rollback failed: %w", err, rollbackErr)
+			if run.resume && (taskStatus(task) == model.StatusSuccess || pr.hasCheckpoint(planID, graph.RootTaskID, taskID)) {
+				pr.logger.Infof("[%s] PlanRegistry.executeTaskGraph() Skip finished task %s", executionID, taskID)
+				// Без точки отката откатывать нечего
+				if pr.hasCheckpoint(planID, graph.RootTaskID, taskID) {
+					completed = append(completed, taskID)
+				}
+				succeeded[taskID] = true
+				finished(taskID)
+				continue
 			}
-			return fmt.Errorf("task %s failed: %w", taskID, err)
+
+			running++
+			go func() {
+				results <- taskResult{taskID: taskID, err: pr.runScheduledTask(ctx, planID, run, graph.RootTaskID, task)}
+			}()
 		}
 
-		// Сохраняем точку отката
-		pr.saveCheckpoint(planID, checkpoint)
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		if result.err == nil {
+			completed = append(completed, result.taskID)
+			succeeded[result.taskID] = true
+		} else {
+			pr.logger.Errorf("[%s] Task %s failed: %v", executionID, result.taskID, result.err)
+			failedTasks = append(failedTasks, result.taskID)
+			graphErr = errors.Join(graphErr, fmt.Errorf("task %s failed: %w", result.taskID, result.err))
+		}
+		// Нестрогие зависимые задачи выполняются и после ошибки
+		finished(result.taskID)
 	}
 
-	return nil
-}
+	// Остановленный план не откатываем — его можно продолжить через Resume
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("graph execution interrupted: %w", errors.Join(graphErr, err))
+	}
 
-// getExecutionOrder возвращает задачи в топологическом порядке (алгоритм Кана)
-func (pr *PlanRegistry) getExecutionOrder(dependencies map[string][]string) ([]string, error) {
-	inDegree := make(map[string]int)
-	var queue []string
-	var order []string
+	if graphErr == nil {
+		for taskID, count := range waiting {
+			if count > 0 {
+				return fmt.Errorf("task %s never became ready: cycle detected in dependency graph", taskID)
+			}
+		}
+		return nil
+	}
 
-	// Инициализация входящих степеней
-	for node := range dependencies {
-		inDegree[node] = 0
+	// Пытаемся откатить выполненные задачи
+	if rollbackErr := pr.rollbackGraph(ctx, planID, executionID, graph, failedTasks, completed); rollbackErr != nil {
+		return fmt.Errorf("execution failed: %v, rollback failed: %w", graphErr, rollbackErr)
 	}
+	return graphErr
+}
 
-	// Вычисление входящих степеней (сколько задач зависят от данной)
-	for _, deps := range dependencies {
-		for _, dep := range deps {
-			inDegree[dep]++
+// failedStrictDependency returns the first strict dependency of the task
+// that did not succeed in this run, or "" if there is none.
+func failedStrictDependency(task *model.Task, succeeded map[string]bool) string {
+	for _, depends := range task.DependsOn {
+		if (depends.Type == model.Strict || depends.Type == "") && !succeeded[depends.ID] {
+			return depends.ID
 		}
 	}
+	return ""
+}
 
-	// Добавляем в очередь задачи без входящих зависимостей
-	for node, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, node)
+// runScheduledTask waits at the task boundary (pause, stop, free slot of the
+// plan) and runs the task.
+func (pr *PlanRegistry) runScheduledTask(ctx context.Context, planID string, run *planRun, graphID string, task *model.Task) error {
+	// Граница задачи: здесь ждём снятия паузы и проверяем остановку
+	if err := run.gate.Wait(ctx); err != nil {
+		return fmt.Errorf("interrupted before start: %w", err)
+	}
+	if run.slots != nil {
+		select {
+		case run.slots <- struct{}{}:
+			defer func() { <-run.slots }()
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted before start: %w", ctx.Err())
 		}
 	}
+	pr.logger.Infof("[%s] PlanRegistry.executeTaskGraph() Exec task %s", run.executionID, task.ID)
+	// Зависимости уже учтены планировщиком графа
+	return pr.runGraphTask(withDependsResolved(ctx), planID, run.executionID, graphID, task)
+}
 
-	// Обработка очереди
-	for len(queue) > 0 {
-		// Чтобы порядок был детерминированным, сортируем задачи с одинаковым inDegree
-		sort.Strings(queue)
-		u := queue[0]
-		queue = queue[1:]
-		order = append(order, u)
+// runGraphTask snapshots the components of the task, runs it and saves the
+// rollback checkpoint once the task succeeded.
+func (pr *PlanRegistry) runGraphTask(ctx context.Context, planID, executionID, graphID string, task *model.Task) error {
+	// Создаем точку отката перед выполнением задачи
+	state, err := pr.captureState(ctx, task.Components)
+	if err != nil {
+		return err
+	}
+	checkpoint := &model.RollbackCheckpoint{
+		GraphID:   graphID,
+		TaskID:    task.ID,
+		State:     state,
+		Timestamp: time.Now(),
+	}
 
-		// Уменьшаем степень для зависимых задач
-		for _, v := range dependencies[u] {
-			inDegree[v]--
-			if inDegree[v] == 0 {
-				queue = append(queue, v)
+	// Выполняем задачу
+	if _, err := pr.Tasks.ForkContext(ctx, task.ID, executionID); err != nil {
+		return err
+	}
+
+	// Сохраняем точку отката
+	pr.saveCheckpoint(planID, checkpoint)
+	return nil
+}
+
+// captureState snapshots version and metadata of the components, plus the
+// controller state when the controller implements api.SnapshotController.
+func (pr *PlanRegistry) captureState(ctx context.Context, componentIDs []string) (map[string]*model.ComponentState, error) {
+	state := make(map[string]*model.ComponentState, len(componentIDs))
+	for _, compID := range componentIDs {
+		comp, err := pr.Components.Get(compID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot component %s: %w", compID, err)
+		}
+		compState := &model.ComponentState{
+			Version:  comp.Version,
+			Metadata: copyMeta(comp.Metadata),
+		}
+		if controller := pr.snapshotController(comp.Type); controller != nil {
+			compState.Controller, err = controller.Snapshot(ctx, comp.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to snapshot component %s: %w", compID, err)
 			}
 		}
+		state[compID] = compState
 	}
+	return state, nil
+}
 
-	// Проверка на циклы
-	if len(order) != len(dependencies) {
-		return nil, errors.New("cycle detected in dependency graph")
+// restoreState returns the components to the captured state.
+func (pr *PlanRegistry) restoreState(ctx context.Context, state map[string]*model.ComponentState) error {
+	compIDs := make([]string, 0, len(state))
+	for compID := range state {
+		compIDs = append(compIDs, compID)
 	}
+	sort.Strings(compIDs)
 
-	// Разворачиваем порядок, чтобы зависимости выполнялись первыми
-	reverse(order)
+	for _, compID := range compIDs {
+		compState := state[compID]
+		comp, err := pr.Components.Get(compID)
+		if err != nil {
+			return fmt.Errorf("failed to restore component %s: %w", compID, err)
+		}
+		if controller := pr.snapshotController(comp.Type); controller != nil && compState.Controller != nil {
+			if err := controller.Restore(ctx, compState.Metadata, compState.Controller); err != nil {
+				return fmt.Errorf("failed to restore component %s: %w", compID, err)
+			}
+		}
+		restored := &model.Component{
+			ID:       comp.ID,
+			Name:     comp.Name,
+			Type:     comp.Type,
+			Version:  compState.Version,
+			Metadata: copyMeta(compState.Metadata),
+		}
+		if err := pr.Components.Update(compID, restored); err != nil {
+			return fmt.Errorf("failed to restore component %s: %w", compID, err)
+		}
+		pr.logger.Infof("PlanRegistry.restoreState() - component %s restored to version %s", compID, compState.Version)
+	}
+	return nil
+}
 
-	return order, nil
+// taskStatusUpdater is implemented by TaskRegistry, which persists and
+// publishes the new status.
+type taskStatusUpdater interface {
+	UpdateTaskStatus(task *model.Task, status model.Status) error
 }
 
-func reverse(a []string) {
-	for i := len(a)/2 - 1; i >= 0; i-- {
-		opp := len(a) - 1 - i
-		a[i], a[opp] = a[opp], a[i]
+func (pr *PlanRegistry) markRolledBack(taskID string) {
+	task, err := pr.Tasks.Get(taskID)
+	if err != nil {
+		pr.logger.Warnf("PlanRegistry.markRolledBack() - task %s: %v", taskID, err)
+		return
 	}
+	pr.setTaskStatus(task, model.StatusRollBack)
 }
 
-func (pr *PlanRegistry) rollbackGraph(planID, executionID string, graph *model.TaskGraph, failedTaskID string) error {
-	pr.logger.Infof("[%s] Starting rollback for graph %s after task %s failure",
-		executionID, graph.RootTaskID, failedTaskID)
+// markSkipped moves a task the plan does not run because of a failed strict
+// dependency to skipped, so it does not keep the status of a previous run.
+func (pr *PlanRegistry) markSkipped(task *model.Task, dependencyID string) {
+	pr.setTaskStatus(task, model.StatusSkipped)
+	message := fmt.Sprintf("Skipped: strict dependency %s did not succeed", dependencyID)
+	pr.AddEvent(task.EventHistory, message)
+	publishEvent(pr.bus, model.EntityTask, task.ID, "", message)
+}
 
-	// Получаем порядок отката (обратный порядку выполнения)
-	executionOrder, err := pr.getExecutionOrder(graph.Dependencies)
+func (pr *PlanRegistry) setTaskStatus(task *model.Task, status model.Status) {
+	if updater, ok := pr.Tasks.(taskStatusUpdater); ok {
+		updater.UpdateTaskStatus(task, status)
+		return
+	}
+	task.MU.Lock()
+	task.StatusHistory = pr.Tasks.NextStatus(status, task.StatusHistory)
+	task.MU.Unlock()
+}
+
+func (pr *PlanRegistry) snapshotController(componentType string) api.SnapshotController {
+	if pr.Controllers == nil {
+		return nil
+	}
+	controller, err := pr.Controllers.Get(componentType)
 	if err != nil {
-		return fmt.Errorf("failed to get rollback order: %w", err)
+		return nil
 	}
+	snapshotter, _ := controller.(api.SnapshotController)
+	return snapshotter
+}
 
-	// Ищем с какой задачи начать откат
-	startRollback := false
-	for i := len(executionOrder) - 1; i >= 0; i-- {
-		taskID := executionOrder[i]
+func copyMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	result := make(map[string]string, len(meta))
+	for k, v := range meta {
+		result[k] = v
+	}
+	return result
+}
 
-		if taskID == failedTaskID {
-			startRollback = true
-			continue
-		}
+func (pr *PlanRegistry) rollbackGraph(ctx context.Context, planID, executionID string, graph *model.TaskGraph, failedTaskIDs []string, completed []string) (err error) {
+	ctx, span := pr.tracer.Start(ctx, "PlanRegistry.rollbackGraph", trace.WithAttributes(
+		attrPlanID.String(planID), attrGraphID.String(graph.RootTaskID)))
+	defer func() { endSpan(span, err) }()
 
-		if !startRollback {
-			continue
-		}
+	pr.logger.Infof("[%s] Starting rollback for graph %s after tasks %v failure",
+		executionID, graph.RootTaskID, failedTaskIDs)
+
+	// Откатываем в порядке, обратном завершению задач
+	for i := len(completed) - 1; i >= 0; i-- {
+		taskID := completed[i]
 
 		// Восстанавливаем состояние из точки отката
-		if err := pr.restoreCheckpoint(planID, graph.RootTaskID, taskID); err != nil {
+		if err := pr.restoreCheckpoint(ctx, planID, graph.RootTaskID, taskID); err != nil {
 			return fmt.Errorf("failed to rollback task %s: %w", taskID, err)
 		}
-
 	}
 
 	return nil
@@ -488,32 +897,191 @@ func (pr *PlanRegistry) saveCheckpoint(planID string, checkpoint *model.Rollback
 	defer pr.mu.Unlock()
 
 	plan := pr.plans[planID]
+	plan.MU.Lock()
 	plan.RollbackStack = append(plan.RollbackStack, checkpoint)
+	plan.MU.Unlock()
+	pr.saveState(plan)
+}
+
+func (pr *PlanRegistry) restoreCheckpoint(ctx context.Context, planID, graphID, taskID string) error {
+	// Ищем последнюю точку отката для задачи
+	pr.mu.RLock()
+	var checkpoint *model.RollbackCheckpoint
+	stack := pr.plans[planID].RollbackStack
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].GraphID == graphID && stack[i].TaskID == taskID {
+			checkpoint = stack[i]
+			break
+		}
+	}
+	pr.mu.RUnlock()
+
+	if checkpoint == nil {
+		return fmt.Errorf("checkpoint not found for task %s", taskID)
+	}
+
+	// Восстанавливаем состояние компонентов; откат не прерывается остановкой плана
+	if err := pr.restoreState(context.WithoutCancel(ctx), checkpoint.State); err != nil {
+		return err
+	}
+	// Откатанная задача больше не успешна — Resume выполнит её заново
+	pr.markRolledBack(taskID)
+
+	// Восстановленная точка снимается со стека
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	plan := pr.plans[planID]
+	plan.MU.Lock()
+	for i, cp := range plan.RollbackStack {
+		if cp == checkpoint {
+			plan.RollbackStack = append(plan.RollbackStack[:i], plan.RollbackStack[i+1:]...)
+			break
+		}
+	}
+	plan.MU.Unlock()
+	pr.saveState(plan)
+	return nil
 }
 
-func (pr *PlanRegistry) restoreCheckpoint(planID, graphID, taskID string) error {
+// SetMaxParallelism limits how many tasks of the plan run at the same time.
+// With an empty graphID the limit applies to the whole plan, otherwise to
+// the graph with that root task. A limit of 0 removes the restriction; it
+// takes effect from the next run.
+func (pr *PlanRegistry) SetMaxParallelism(planID string, graphID string, limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid max parallelism %d", limit)
+	}
+
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
 
-	plan := pr.plans[planID]
+	plan, exists := pr.plans[planID]
+	if !exists {
+		return errors.New("plan not found")
+	}
+	if graphID == "" {
+		plan.MU.Lock()
+		plan.MaxParallelism = limit
+		plan.MU.Unlock()
+		return pr.save(plan)
+	}
+	for _, graph := range plan.TaskGraphs {
+		if graph.RootTaskID == graphID {
+			plan.MU.Lock()
+			graph.MaxParallelism = limit
+			plan.MU.Unlock()
+			return pr.save(plan)
+		}
+	}
+	return fmt.Errorf("graph %s not found in plan %s", graphID, planID)
+}
 
-	// Ищем последнюю точку отката для задачи
-	for i := len(plan.RollbackStack) - 1; i >= 0; i-- {
-		cp := plan.RollbackStack[i]
+// hasCheckpoint reports whether the task has a rollback checkpoint, i.e.
+// it completed in an earlier execution of the plan.
+func (pr *PlanRegistry) hasCheckpoint(planID, graphID, taskID string) bool {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	for _, cp := range pr.plans[planID].RollbackStack {
 		if cp.GraphID == graphID && cp.TaskID == taskID {
-			// Восстанавливаем состояние компонентов
-			for compID, _ := range cp.State {
-				if _, err := pr.Components.Get(compID); err == nil {
-					//if err := comp.Restore(state); err != nil {
-					return fmt.Errorf("failed to restore component %s: %w", compID, err)
-					//}
-				}
-			}
-			return nil
+			return true
+		}
+	}
+	return false
+}
+
+// Recover marks plans left running by a previous process as interrupted and
+// applies the recovery policy to each interrupted plan, including the ones
+// marked by an earlier call. Paused plans stay paused.
+//
+// Returns:
+// []string - IDs of the interrupted plans
+func (pr *PlanRegistry) Recover(policy model.RecoveryPolicy) ([]string, error) {
+	switch policy {
+	case model.RecoverNone, model.RecoverResume, model.RecoverRollBack, model.RecoverFail:
+	default:
+		return nil, fmt.Errorf("unknown recovery policy '%s'", policy)
+	}
+
+	pr.mu.Lock()
+	interrupted := make([]string, 0)
+	for planID, plan := range pr.plans {
+		if _, active := pr.runs[planID]; active {
+			continue
+		}
+		switch status := plan.StatusHistory.LastStatus; status {
+		case model.StatusRunning, model.StatusPending:
+			pr.setStatus(plan, model.StatusInterrupted)
+			pr.event(plan, fmt.Sprintf("Plan interrupted while %s!", status))
+			pr.saveState(plan)
+		case model.StatusInterrupted:
+			// Уже помечен, например NewCore, политика ещё не применялась
+		default:
+			continue
 		}
+		interrupted = append(interrupted, planID)
 	}
+	pr.mu.Unlock()
+	sort.Strings(interrupted)
 
-	return fmt.Errorf("checkpoint not found for task %s", taskID)
+	var recoverErr error
+	for _, planID := range interrupted {
+		pr.logger.Warnf("PlanRegistry.Recover() - plan %s interrupted, policy: '%s'", planID, policy)
+		if err := pr.recoverPlan(planID, policy); err != nil {
+			recoverErr = errors.Join(recoverErr, fmt.Errorf("plan %s: %w", planID, err))
+		}
+	}
+	return interrupted, recoverErr
+}
+
+func (pr *PlanRegistry) recoverPlan(planID string, policy model.RecoveryPolicy) error {
+	switch policy {
+	case model.RecoverResume:
+		_, err := pr.Resume(planID)
+		return err
+	case model.RecoverRollBack:
+		return pr.rollbackPlan(planID)
+	case model.RecoverFail:
+		pr.mu.Lock()
+		defer pr.mu.Unlock()
+		plan := pr.plans[planID]
+		pr.setStatus(plan, model.StatusFailed)
+		pr.saveState(plan)
+		return nil
+	}
+	return nil
+}
+
+// rollbackPlan restores every checkpoint of the plan, newest first.
+func (pr *PlanRegistry) rollbackPlan(planID string) error {
+	executionID := uuid.New().String()
+	pr.Executions.Start(executionID, model.RollBackExecution, planID, "")
+
+	pr.mu.RLock()
+	stack := append([]*model.RollbackCheckpoint(nil), pr.plans[planID].RollbackStack...)
+	pr.mu.RUnlock()
+
+	var rollbackErr error
+	for i := len(stack) - 1; i >= 0; i-- {
+		cp := stack[i]
+		pr.logger.Infof("[%s] Rolling back task %s of plan %s", executionID, cp.TaskID, planID)
+		if err := pr.restoreCheckpoint(context.Background(), planID, cp.GraphID, cp.TaskID); err != nil {
+			rollbackErr = fmt.Errorf("failed to rollback task %s: %w", cp.TaskID, err)
+			break
+		}
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	plan := pr.plans[planID]
+	if rollbackErr != nil {
+		pr.setStatus(plan, model.StatusFailed)
+	} else {
+		pr.setStatus(plan, model.StatusRollBack)
+	}
+	pr.saveState(plan)
+	pr.Executions.Finish(executionID, plan.StatusHistory.LastStatus, rollbackErr)
+	return rollbackErr
 }
 
 // Status returns the current status of a plan
@@ -529,7 +1097,8 @@ func (pr *PlanRegistry) Status(planID string) (model.Status, error) {
 	return plan.StatusHistory.LastStatus, nil
 }
 
-// Stop terminates execution of a running plan
+// Stop terminates execution of a running plan. Tasks in flight are
+// cancelled through their context and no further task is started.
 func (pr *PlanRegistry) Stop(planID string) error {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
@@ -544,19 +1113,20 @@ func (pr *PlanRegistry) Stop(planID string) error {
 		return fmt.Errorf("cannot stop plan in status '%s'", currentStatus)
 	}
 
-	plan.StatusHistory = pr.StatusManager.NextStatus(model.StatusStopped, plan.StatusHistory)
+	pr.setStatus(plan, model.StatusStopped)
 	pr.plans[planID] = plan
+	pr.saveState(plan)
 
-	// In a real implementation, you would also:
-	// 1. Cancel any running tasks
-	// 2. Clean up resources
-	// 3. Notify dependent systems
+	if run, active := pr.runs[planID]; active {
+		run.cancel()
+	}
 
 	pr.logger.Infof("Plan '%s' stopped", planID)
 	return nil
 }
 
-// Pause temporarily halts execution of a running plan
+// Pause holds a running plan at the next task boundary. Tasks that are
+// already running are allowed to finish.
 func (pr *PlanRegistry) Pause(planID string) error {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
@@ -570,17 +1140,77 @@ func (pr *PlanRegistry) Pause(planID string) error {
 		return fmt.Errorf("cannot pause plan in status '%s'", plan.StatusHistory.LastStatus)
 	}
 
-	plan.StatusHistory = pr.StatusManager.NextStatus(model.StatusPaused, plan.StatusHistory)
-	pr.plans[planID] = plan
+	run, active := pr.runs[planID]
+	if !active {
+		return fmt.Errorf("plan '%s' has no active execution", planID)
+	}
+	run.gate.Pause()
 
-	// In a real implementation, you would:
-	// 1. Pause any running tasks
-	// 2. Save state for resumption
+	pr.setStatus(plan, model.StatusPaused)
+	pr.plans[planID] = plan
+	pr.saveState(plan)
 
 	pr.logger.Infof("Plan '%s' paused", planID)
 	return nil
 }
 
+// Resume continues a paused, stopped or failed plan. A paused execution is
+// released in place; otherwise a new execution is started that skips every
+// task already finished successfully.
+//
+// Returns:
+// string - ID of the execution that continues the plan
+func (pr *PlanRegistry) Resume(planID string) (string, error) {
+	pr.mu.Lock()
+	plan, exists := pr.plans[planID]
+	if !exists {
+		pr.mu.Unlock()
+		return "", errors.New("plan not found")
+	}
+
+	currentStatus := plan.StatusHistory.LastStatus
+	if run, active := pr.runs[planID]; active && currentStatus == model.StatusStopped {
+		// Остановленное выполнение ещё сворачивается — дожидаемся его
+		pr.mu.Unlock()
+		<-run.done
+		pr.mu.Lock()
+		currentStatus = plan.StatusHistory.LastStatus
+	}
+	if run, active := pr.runs[planID]; active {
+		if currentStatus != model.StatusPaused {
+			pr.mu.Unlock()
+			return "", fmt.Errorf("cannot resume plan in status '%s'", currentStatus)
+		}
+		pr.setStatus(plan, model.StatusRunning)
+		pr.plans[planID] = plan
+		pr.saveState(plan)
+		run.gate.Resume()
+		pr.mu.Unlock()
+
+		pr.logger.Infof("[%s] Plan '%s' resumed", run.executionID, planID)
+		return run.executionID, nil
+	}
+	pr.mu.Unlock()
+
+	switch currentStatus {
+	case model.StatusPaused, model.StatusStopped, model.StatusFailed, model.StatusInterrupted:
+	default:
+		return "", fmt.Errorf("cannot resume plan in status '%s'", currentStatus)
+	}
+
+	executionID := uuid.New().String()
+	pr.Executions.Start(executionID, model.PlanExecution, planID, "")
+	run, ctx, err := pr.startRun(context.Background(), planID, executionID, true)
+	if err != nil {
+		pr.Executions.Finish(executionID, model.StatusFailed, err)
+		return "", err
+	}
+	go pr.execute(ctx, planID, run)
+
+	pr.logger.Infof("[%s] Plan '%s' resumed from the first unfinished task", executionID, planID)
+	return executionID, nil
+}
+
 // detectCycles checks for circular dependencies using Kahn's algorithm
 func (pr *PlanRegistry) detectCycles(graph map[string][]string) error {
 	// Implementation of cycle detection
@@ -625,3 +1255,20 @@ func (pr *PlanRegistry) detectCycles(graph map[string][]string) error {
 	}
 	return nil
 }
+
+// setStatus moves the plan to the status and publishes the transition. The
+// caller holds pr.mu; the plan is changed under plan.MU as well, which is
+// what readers outside the registry, such as the server, lock.
+func (pr *PlanRegistry) setStatus(plan *model.Plan, status model.Status) {
+	history := pr.NextStatus(status, plan.StatusHistory)
+	plan.MU.Lock()
+	plan.StatusHistory = history
+	plan.MU.Unlock()
+	publishStatus(pr.bus, model.EntityPlan, plan.ID, "", history)
+}
+
+// event adds an entry to the event history of the plan and publishes it.
+func (pr *PlanRegistry) event(plan *model.Plan, message string) {
+	pr.AddEvent(plan.EventHistory, message)
+	publishEvent(pr.bus, model.EntityPlan, plan.ID, "", message)
+}
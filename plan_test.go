@@ -0,0 +1,398 @@
+package inforo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- stepping controller: every RunTask reports its start and waits for a release ---
+type steppingController struct {
+	mockController
+	started chan string
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newSteppingController() *steppingController {
+	return &steppingController{
+		started: make(chan string, 16),
+		release: make(chan struct{}),
+		calls:   make(map[string]int),
+	}
+}
+
+func (s *steppingController) RunTask(r map[string]string, p map[string]string) error {
+	s.mu.Lock()
+	s.calls[p["name"]]++
+	s.mu.Unlock()
+
+	s.started <- p["name"]
+	<-s.release
+	return nil
+}
+
+func (s *steppingController) Calls(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[name]
+}
+
+func setupSteppingPlan(t *testing.T) (*inforo.Core, *steppingController, *model.Plan) {
+	c := inforo.NewDefaultCore()
+	ctl := newSteppingController()
+	require.NoError(t, c.Controllers.Register("stepping", ctl))
+
+	for _, id := range []string{"first", "second"} {
+		_, err := c.Components.Register(&model.Component{
+			ID:       id,
+			Type:     "stepping",
+			Version:  "1.0.0",
+			Metadata: map[string]string{"name": id},
+		})
+		require.NoError(t, err)
+	}
+
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"first"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"second"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+	return c, ctl, plan
+}
+
+func waitStarted(t *testing.T, ctl *steppingController, name string) {
+	select {
+	case got := <-ctl.started:
+		require.Equal(t, name, got)
+	case <-time.After(time.Second):
+		t.Fatalf("task on component %s did not start", name)
+	}
+}
+
+func waitPlanStatus(t *testing.T, c *inforo.Core, planID string, want model.Status) {
+	assert.Eventually(t, func() bool {
+		status, _ := c.Plans.Status(planID)
+		return status == want
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPlanPause_HoldsNextTask(t *testing.T) {
+	c, ctl, plan := setupSteppingPlan(t)
+
+	_, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	require.NoError(t, c.Plans.Pause(plan.ID))
+	ctl.release <- struct{}{}
+
+	// Первая задача завершилась, вторая не должна стартовать
+	select {
+	case name := <-ctl.started:
+		t.Fatalf("task on component %s started while plan is paused", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+	status, _ := c.Plans.Status(plan.ID)
+	assert.Equal(t, model.StatusPaused, status)
+
+	_, err = c.Plans.Resume(plan.ID)
+	require.NoError(t, err)
+	waitStarted(t, ctl, "second")
+	ctl.release <- struct{}{}
+
+	waitPlanStatus(t, c, plan.ID, model.StatusSuccess)
+}
+
+func TestPlanStop_CancelsRunningTask(t *testing.T) {
+	c, ctl, plan := setupSteppingPlan(t)
+	defer close(ctl.release)
+
+	_, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	require.NoError(t, c.Plans.Stop(plan.ID))
+	waitPlanStatus(t, c, plan.ID, model.StatusStopped)
+
+	assert.Eventually(t, func() bool {
+		status, _, _ := c.Tasks.Status("task-1")
+		return status == model.StatusStopped
+	}, time.Second, 5*time.Millisecond)
+
+	status, _, _ := c.Tasks.Status("task-2")
+	assert.Equal(t, model.StatusCreated, status)
+}
+
+func TestPlanResume_SkipsFinishedTasks(t *testing.T) {
+	c, ctl, plan := setupSteppingPlan(t)
+
+	_, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+	ctl.release <- struct{}{}
+	waitStarted(t, ctl, "second")
+
+	require.NoError(t, c.Plans.Stop(plan.ID))
+	waitPlanStatus(t, c, plan.ID, model.StatusStopped)
+
+	_, err = c.Plans.Resume(plan.ID)
+	require.NoError(t, err)
+	waitStarted(t, ctl, "second")
+	// Отпускаем и новый вызов, и прерванный остановкой
+	close(ctl.release)
+
+	waitPlanStatus(t, c, plan.ID, model.StatusSuccess)
+	assert.Equal(t, 1, ctl.Calls("first"))
+	assert.Equal(t, 2, ctl.Calls("second"))
+}
+
+func TestPlanResume_InvalidStatus(t *testing.T) {
+	c, _, plan := setupSteppingPlan(t)
+
+	_, err := c.Plans.Resume(plan.ID)
+	assert.EqualError(t, err, "cannot resume plan in status 'created'")
+}
+
+func TestPlanUpdate_WithoutStatus(t *testing.T) {
+	c, _, plan := setupSteppingPlan(t)
+
+	// План без истории статусов статус не меняет
+	require.NoError(t, c.Plans.Update(plan.ID, &model.Plan{}))
+	require.NoError(t, c.Plans.Update(plan.ID, nil))
+	status, err := c.Plans.Status(plan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusCreated, status)
+
+	require.NoError(t, c.Plans.Update(plan.ID, &model.Plan{StatusHistory: &model.StatusHistory{LastStatus: model.StatusPaused}}))
+	status, err = c.Plans.Status(plan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPaused, status)
+}
+
+// --- snapshot controller: deploys version 2.0.0, fails on component "second" ---
+type snapshotController struct {
+	mockController
+	components api.ComponentRegistry
+
+	mu       sync.Mutex
+	deployed map[string]string
+	fixed    bool // "second" deploys too
+}
+
+func (s *snapshotController) RunTask(r map[string]string, p map[string]string) error {
+	s.mu.Lock()
+	if p["name"] == "second" && !s.fixed {
+		s.mu.Unlock()
+		return errors.New("deploy failed")
+	}
+	s.deployed[p["name"]] = "2.0.0"
+	s.mu.Unlock()
+
+	comp, err := s.components.Get(p["name"])
+	if err != nil {
+		return err
+	}
+	return s.components.Update(comp.ID, &model.Component{
+		ID:       comp.ID,
+		Type:     comp.Type,
+		Version:  "2.0.0",
+		Metadata: map[string]string{"name": p["name"], "image": "app:2.0.0"},
+	})
+}
+
+func (s *snapshotController) Snapshot(ctx context.Context, meta map[string]string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]string{"deployed": s.deployed[meta["name"]]}, nil
+}
+
+func (s *snapshotController) Restore(ctx context.Context, meta map[string]string, state map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deployed[meta["name"]] = state["deployed"]
+	return nil
+}
+
+// setupSnapshotPlan: task-1 on "first", then task-2 on "second", which fails
+// to deploy until the controller is fixed.
+func setupSnapshotPlan(t *testing.T) (*inforo.Core, *snapshotController, *model.Plan) {
+	c := inforo.NewDefaultCore()
+	ctl := &snapshotController{
+		components: c.Components,
+		deployed:   map[string]string{"first": "1.0.0", "second": "1.0.0"},
+	}
+	require.NoError(t, c.Controllers.Register("snapshot", ctl))
+	for _, id := range []string{"first", "second"} {
+		_, err := c.Components.Register(&model.Component{
+			ID:       id,
+			Type:     "snapshot",
+			Version:  "1.0.0",
+			Metadata: map[string]string{"name": id, "image": "app:1.0.0"},
+		})
+		require.NoError(t, err)
+	}
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"first"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"second"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+	return c, ctl, plan
+}
+
+func TestPlanRollBack_RestoresComponentState(t *testing.T) {
+	c, ctl, plan := setupSnapshotPlan(t)
+
+	_, err := c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+
+	// Компонент первой задачи вернулся к исходной версии и метаданным
+	comp, err := c.Components.Get("first")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", comp.Version)
+	assert.Equal(t, "app:1.0.0", comp.Metadata["image"])
+	assert.Equal(t, "1.0.0", ctl.deployed["first"])
+
+	restored, err := c.Plans.Get(plan.ID)
+	require.NoError(t, err)
+	assert.Empty(t, restored.RollbackStack)
+	status, _, err := c.Tasks.Status("task-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRollBack, status)
+}
+
+func TestPlanResume_RerunsRolledBackTasks(t *testing.T) {
+	c, ctl, plan := setupSnapshotPlan(t)
+	_, err := c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+
+	ctl.mu.Lock()
+	ctl.fixed = true
+	ctl.mu.Unlock()
+	executionID, err := c.Plans.Resume(plan.ID)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	execution, err := c.Executions.Wait(ctx, executionID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+
+	// Откатанная первая задача выполнена заново, а не пропущена
+	comp, err := c.Components.Get("first")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", comp.Version)
+	status, _, err := c.Tasks.Status("task-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, status)
+}
+
+// setupFanOutPlan: task-1 on "first", then task-2 on "second" and task-3 on
+// "third", both depending only on task-1.
+func setupFanOutPlan(t *testing.T) (*inforo.Core, *steppingController, *model.Plan) {
+	c := inforo.NewDefaultCore()
+	ctl := newSteppingController()
+	require.NoError(t, c.Controllers.Register("stepping", ctl))
+
+	for _, id := range []string{"first", "second", "third"} {
+		_, err := c.Components.Register(&model.Component{
+			ID:       id,
+			Type:     "stepping",
+			Version:  "1.0.0",
+			Metadata: map[string]string{"name": id},
+		})
+		require.NoError(t, err)
+	}
+
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"first"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"second"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+		{ID: "task-3", Type: model.UpdateTask, Components: []string{"third"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.TaskGraphs, 1)
+	return c, ctl, plan
+}
+
+func TestPlanRun_ParallelTasks(t *testing.T) {
+	c, ctl, plan := setupFanOutPlan(t)
+
+	_, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+	ctl.release <- struct{}{}
+
+	// Обе зависимые задачи стартуют, не дожидаясь друг друга
+	started := []string{}
+	for range 2 {
+		select {
+		case name := <-ctl.started:
+			started = append(started, name)
+		case <-time.After(time.Second):
+			t.Fatalf("independent tasks did not start in parallel, started: %v", started)
+		}
+	}
+	assert.ElementsMatch(t, []string{"second", "third"}, started)
+
+	close(ctl.release)
+	waitPlanStatus(t, c, plan.ID, model.StatusSuccess)
+}
+
+func TestPlanRun_MaxParallelism(t *testing.T) {
+	for name, graphID := range map[string]string{"plan": "", "graph": "task-1"} {
+		t.Run(name, func(t *testing.T) {
+			c, ctl, plan := setupFanOutPlan(t)
+			require.NoError(t, c.Plans.SetMaxParallelism(plan.ID, graphID, 1))
+
+			_, err := c.Plans.RunAsync(plan.ID, "")
+			require.NoError(t, err)
+			waitStarted(t, ctl, "first")
+			ctl.release <- struct{}{}
+
+			// При ограничении плана задачи борются за слот — порядок не важен
+			var first string
+			select {
+			case first = <-ctl.started:
+			case <-time.After(time.Second):
+				t.Fatal("no task started after task-1")
+			}
+			select {
+			case name := <-ctl.started:
+				t.Fatalf("task on component %s started over the limit", name)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			ctl.release <- struct{}{}
+			if first == "second" {
+				waitStarted(t, ctl, "third")
+			} else {
+				waitStarted(t, ctl, "second")
+			}
+			ctl.release <- struct{}{}
+			waitPlanStatus(t, c, plan.ID, model.StatusSuccess)
+		})
+	}
+}
+
+func TestPlanSetMaxParallelism_Invalid(t *testing.T) {
+	c, _, plan := setupFanOutPlan(t)
+
+	assert.EqualError(t, c.Plans.SetMaxParallelism(plan.ID, "", -1), "invalid max parallelism -1")
+	assert.EqualError(t, c.Plans.SetMaxParallelism(plan.ID, "task-2", 1),
+		"graph task-2 not found in plan "+plan.ID)
+	assert.EqualError(t, c.Plans.SetMaxParallelism("missing", "", 1), "plan not found")
+}
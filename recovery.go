@@ -0,0 +1,31 @@
+package inforo
+
+import (
+	"errors"
+
+	"github.com/laplasd/inforo/model"
+)
+
+// Recover marks tasks and plans left running by a previous process as
+// interrupted and applies the policy to the interrupted plans.
+//
+// NewCore already marks them when it restores a storage. Call Recover once
+// the controllers and monitoring controllers are registered to resume, roll
+// back or fail the interrupted plans: resuming and rolling back run them.
+//
+// Parameters:
+//   - policy: what to do with interrupted plans (resume, rollback, fail or nothing)
+//
+// Returns:
+// error - if recovery of any task or plan failed
+func (c *Core) Recover(policy model.RecoveryPolicy) error {
+	tasks, taskErr := c.Tasks.Recover()
+	if len(tasks) > 0 {
+		c.Logger.Warnf("Core.Recover() - interrupted tasks: %v", tasks)
+	}
+	plans, planErr := c.Plans.Recover(policy)
+	if len(plans) > 0 {
+		c.Logger.Warnf("Core.Recover() - interrupted plans: %v, policy: '%s'", plans, policy)
+	}
+	return errors.Join(taskErr, planErr)
+}
@@ -0,0 +1,141 @@
+package inforo_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crashedPlan leaves a copy of the storage taken while the second task of
+// the plan was running, as if the process died at that moment.
+func crashedPlan(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	store, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+
+	c := inforo.NewCore(inforo.CoreOptions{Storage: store})
+	ctl := newSteppingController()
+	require.NoError(t, c.Controllers.Register("stepping", ctl))
+	for _, id := range []string{"first", "second"} {
+		_, err := c.Components.Register(&model.Component{
+			ID: id, Type: "stepping", Version: "1.0.0", Metadata: map[string]string{"name": id},
+		})
+		require.NoError(t, err)
+	}
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "task-1", Type: model.UpdateTask, Components: []string{"first"}},
+		{ID: "task-2", Type: model.UpdateTask, Components: []string{"second"},
+			DependsOn: []model.Depends{{Type: model.Ordered, ID: "task-1"}}},
+	})
+	require.NoError(t, err)
+
+	executionID, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+	ctl.release <- struct{}{}
+	waitStarted(t, ctl, "second")
+
+	crashed := t.TempDir()
+	require.NoError(t, os.CopyFS(crashed, os.DirFS(dir)))
+	// Исходный запуск дорабатывает в своём каталоге, копия остаётся «упавшей»
+	close(ctl.release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = c.Executions.Wait(ctx, executionID)
+	require.NoError(t, err)
+	return crashed, plan.ID
+}
+
+func recoverCore(t *testing.T, dir string, policy model.RecoveryPolicy) (*inforo.Core, *steppingController) {
+	store, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+
+	c := inforo.NewCore(inforo.CoreOptions{Storage: store})
+	ctl := newSteppingController()
+	close(ctl.release)
+	require.NoError(t, c.Controllers.Register("stepping", ctl))
+	require.NoError(t, c.Recover(policy))
+	return c, ctl
+}
+
+func TestNewCore_DetectsInterrupted(t *testing.T) {
+	dir, planID := crashedPlan(t)
+	store, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+
+	// NewCore помечает прерванные выполнения, но ничего не запускает
+	c := inforo.NewCore(inforo.CoreOptions{Storage: store})
+	status, err := c.Plans.Status(planID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusInterrupted, status)
+
+	task, err := c.Tasks.Get("task-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusInterrupted, task.StatusHistory.LastStatus)
+
+	// Политика применяется к уже помеченному плану
+	require.NoError(t, c.Recover(model.RecoverFail))
+	status, err = c.Plans.Status(planID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, status)
+}
+
+func TestRecover_MarksInterrupted(t *testing.T) {
+	dir, planID := crashedPlan(t)
+	c, _ := recoverCore(t, dir, model.RecoverNone)
+
+	status, err := c.Plans.Status(planID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusInterrupted, status)
+
+	task, err := c.Tasks.Get("task-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusInterrupted, task.StatusHistory.LastStatus)
+
+	task, err = c.Tasks.Get("task-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+}
+
+func TestRecover_Resume(t *testing.T) {
+	dir, planID := crashedPlan(t)
+	c, ctl := recoverCore(t, dir, model.RecoverResume)
+
+	waitPlanStatus(t, c, planID, model.StatusSuccess)
+	// Завершённая до падения задача не перезапускается
+	assert.Equal(t, 0, ctl.Calls("first"))
+	assert.Equal(t, 1, ctl.Calls("second"))
+}
+
+func TestRecover_RollBack(t *testing.T) {
+	dir, planID := crashedPlan(t)
+	c, _ := recoverCore(t, dir, model.RecoverRollBack)
+
+	status, err := c.Plans.Status(planID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRollBack, status)
+
+	plan, err := c.Plans.Get(planID)
+	require.NoError(t, err)
+	assert.Empty(t, plan.RollbackStack)
+}
+
+func TestRecover_Fail(t *testing.T) {
+	dir, planID := crashedPlan(t)
+	c, _ := recoverCore(t, dir, model.RecoverFail)
+
+	status, err := c.Plans.Status(planID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, status)
+
+	_, err = c.Plans.Recover("unknown")
+	assert.EqualError(t, err, "unknown recovery policy 'unknown'")
+}
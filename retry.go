@@ -0,0 +1,149 @@
+package inforo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// validateRetryPolicy checks the settings of a retry policy; nil is valid.
+func validateRetryPolicy(policy *model.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAttempts < 0 {
+		return fmt.Errorf("invalid retry max attempts %d", policy.MaxAttempts)
+	}
+	switch policy.Backoff {
+	case model.FixedBackoff, model.ExponentialBackoff, "":
+	default:
+		return fmt.Errorf("invalid retry backoff '%s'", policy.Backoff)
+	}
+	if policy.Delay < 0 || policy.MaxDelay < 0 {
+		return errors.New("retry delay must not be negative")
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return fmt.Errorf("invalid retry multiplier %v", policy.Multiplier)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter %v", policy.Jitter)
+	}
+	return nil
+}
+
+// maxAttempts returns how many times the policy allows to run an action.
+func maxAttempts(policy *model.RetryPolicy) int {
+	if policy == nil || policy.MaxAttempts < 1 {
+		return 1
+	}
+	return policy.MaxAttempts
+}
+
+// retryable reports whether another attempt may fix err: errors wrapped with
+// api.Permanent and errors reporting Temporary() == false are final, and a
+// policy with RetryOn only retries errors containing one of its substrings.
+func retryable(policy *model.RetryPolicy, err error) bool {
+	var permanent *api.PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && !temporary.Temporary() {
+		return false
+	}
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, pattern := range policy.RetryOn {
+		if strings.Contains(err.Error(), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the pause after the given failed attempt.
+func retryDelay(policy *model.RetryPolicy, attempt int) time.Duration {
+	delay := float64(policy.Delay)
+	if policy.Backoff == model.ExponentialBackoff {
+		multiplier := policy.Multiplier
+		if multiplier == 0 {
+			multiplier = 2
+		}
+		delay *= math.Pow(multiplier, float64(attempt-1))
+	}
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// withRetry calls fn until it succeeds, the policy runs out of attempts or
+// the error is not retryable. onRetry is called before every pause.
+func withRetry(ctx context.Context, policy *model.RetryPolicy, fn func(attempt int) error, onRetry func(attempt int, err error, delay time.Duration)) error {
+	attempts := maxAttempts(policy)
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= attempts || !retryable(policy, err) {
+			if attempt > 1 {
+				return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := retryDelay(policy, attempt)
+		onRetry(attempt, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Retry calls fn with the retry policy the way tasks and checks are retried:
+// errors wrapped with api.Permanent are not retried, and ctx interrupts the
+// pause between attempts.
+func Retry(ctx context.Context, policy *model.RetryPolicy, fn func(attempt int) error) error {
+	return withRetry(ctx, policy, fn, func(int, error, time.Duration) {})
+}
+
+// retry runs an action of the task with the retry policy, recording every
+// attempt in the task events. retrying is called when the action waits for
+// the next attempt and attempting before every attempt, so that the caller
+// reflects the retry in the status of what it retries.
+func (ts *TaskRegistry) retry(ctx context.Context, task *model.Task, policy *model.RetryPolicy, action string, retrying func(), attempting func(attempt int), fn func() error) error {
+	attempts := maxAttempts(policy)
+
+	return withRetry(ctx, policy, func(attempt int) error {
+		attempting(attempt)
+		err := fn()
+		if attempts > 1 {
+			if err != nil {
+				ts.event(task, fmt.Sprintf("Attempt %d/%d of %s failed: %v", attempt, attempts, action, err))
+			} else {
+				ts.event(task, fmt.Sprintf("Attempt %d/%d of %s succeeded", attempt, attempts, action))
+			}
+		}
+		return err
+	}, func(attempt int, err error, delay time.Duration) {
+		ts.logger.Warnf("TaskRegistry.retry() - task %s: %s failed on attempt %d/%d, retrying in %s: %v", task.ID, action, attempt, attempts, delay, err)
+		retrying()
+		ts.event(task, fmt.Sprintf("Retrying %s in %s", action, delay))
+	})
+}
@@ -0,0 +1,176 @@
+package inforo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- flaky controller: fails the first failures calls with err ---
+type flakyController struct {
+	mockController
+	failures int
+	err      error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *flakyController) RunTask(r map[string]string, p map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func forkFlaky(t *testing.T, ctl *flakyController, policy *model.RetryPolicy) (*model.Task, error) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("flaky", ctl))
+	_, err := c.Components.Register(&model.Component{ID: "component-1", Type: "flaky", Version: "1.0.0"})
+	require.NoError(t, err)
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}, Retry: policy})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	return task, err
+}
+
+func eventsContaining(task *model.Task, substr string) int {
+	task.EventHistory.MU.RLock()
+	defer task.EventHistory.MU.RUnlock()
+	count := 0
+	for _, event := range task.EventHistory.Event {
+		if strings.Contains(event.Message, substr) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRetry_SucceedsAfterFailures(t *testing.T) {
+	ctl := &flakyController{failures: 2, err: errors.New("connection refused")}
+	task, err := forkFlaky(t, ctl, &model.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     model.ExponentialBackoff,
+		Delay:       model.Duration(time.Millisecond),
+		Jitter:      0.5,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, ctl.calls)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+	assert.Equal(t, 2, eventsContaining(task, "of component component-1 failed: connection refused"))
+	assert.Equal(t, 1, eventsContaining(task, "Attempt 3/3 of component component-1 succeeded"))
+
+	result := task.ComponentResults["component-1"]
+	require.NotNil(t, result)
+	assert.Equal(t, model.StatusSuccess, result.Status)
+	assert.Equal(t, 3, result.Attempts)
+	assert.False(t, wentThrough(task, model.StatusRetry), "retries of a component must not change the task status")
+}
+
+func wentThrough(task *model.Task, status model.Status) bool {
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	for _, previous := range task.StatusHistory.Previous {
+		if previous.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetry_ParallelBatch(t *testing.T) {
+	ctl := &flakyController{failures: 1, err: errors.New("connection refused")}
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("flaky", ctl))
+	for _, id := range []string{"component-1", "component-2"} {
+		_, err := c.Components.Register(&model.Component{ID: id, Type: "flaky", Version: "1.0.0"})
+		require.NoError(t, err)
+	}
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask,
+		Components: []string{"component-1", "component-2"},
+		Strategy:   &model.RolloutStrategy{Type: model.RollingRollout, BatchSize: 2},
+		Retry:      &model.RetryPolicy{MaxAttempts: 2, Delay: model.Duration(time.Millisecond)}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Fork("task-1", "")
+	require.NoError(t, err)
+
+	// Один из двух компонентов упал и был повторён, статус задачи при этом не менялся
+	assert.Equal(t, 3, ctl.calls)
+	attempts := 0
+	for _, id := range task.Components {
+		result := task.ComponentResults[id]
+		require.NotNil(t, result)
+		assert.Equal(t, model.StatusSuccess, result.Status)
+		attempts += result.Attempts
+	}
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+	assert.False(t, wentThrough(task, model.StatusRetry))
+}
+
+func TestRetry_Exhausted(t *testing.T) {
+	ctl := &flakyController{failures: 5, err: errors.New("connection refused")}
+	task, err := forkFlaky(t, ctl, &model.RetryPolicy{MaxAttempts: 3, Delay: model.Duration(time.Millisecond)})
+
+	assert.EqualError(t, err, "failed after 3 attempts: connection refused")
+	assert.Equal(t, 3, ctl.calls)
+	assert.Equal(t, model.StatusFailed, task.StatusHistory.LastStatus)
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		policy *model.RetryPolicy
+	}{
+		{name: "permanent", err: api.Permanent(errors.New("invalid image")),
+			policy: &model.RetryPolicy{MaxAttempts: 3}},
+		{name: "retry on mismatch", err: errors.New("invalid image"),
+			policy: &model.RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}}},
+		{name: "no policy", err: errors.New("timeout"), policy: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl := &flakyController{failures: 5, err: tt.err}
+			_, err := forkFlaky(t, ctl, tt.policy)
+
+			assert.EqualError(t, err, tt.err.Error())
+			assert.Equal(t, 1, ctl.calls)
+		})
+	}
+}
+
+func TestRetry_InvalidPolicy(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Retry: &model.RetryPolicy{MaxAttempts: 3, Jitter: 2}})
+	assert.EqualError(t, err, "invalid retry jitter 2")
+}
+
+func TestRetryPolicy_JSON(t *testing.T) {
+	policy := &model.RetryPolicy{}
+	require.NoError(t, json.Unmarshal([]byte(`{"MaxAttempts":3,"Delay":"1.5s","MaxDelay":"1m"}`), policy))
+	assert.Equal(t, model.Duration(1500*time.Millisecond), policy.Delay)
+	assert.Equal(t, model.Duration(time.Minute), policy.MaxDelay)
+
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Delay":"1.5s"`)
+}
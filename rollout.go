@@ -0,0 +1,156 @@
+package inforo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo/model"
+)
+
+func validateRolloutStrategy(task *model.Task) error {
+	strategy := task.Strategy
+	if strategy == nil {
+		return nil
+	}
+	if task.Type != model.UpdateTask {
+		return fmt.Errorf("rollout strategy is only supported for update tasks")
+	}
+	switch strategy.Type {
+	case model.SequentialRollout, model.RollingRollout, model.CanaryRollout:
+	default:
+		return fmt.Errorf("invalid rollout strategy '%s'", strategy.Type)
+	}
+	if strategy.BatchSize < 0 || strategy.CanarySize < 0 || strategy.Pause < 0 {
+		return fmt.Errorf("rollout batch size, canary size and pause must not be negative")
+	}
+	if strategy.BatchPercent < 0 || strategy.BatchPercent > 100 {
+		return fmt.Errorf("invalid rollout batch percent %d, expected 0-100", strategy.BatchPercent)
+	}
+	if strategy.BatchSize > 0 && strategy.BatchPercent > 0 {
+		return fmt.Errorf("rollout batch size and batch percent are mutually exclusive")
+	}
+	return nil
+}
+
+// rolloutBatches splits the components of a task into the batches of its
+// rollout strategy. Without a strategy each component is its own batch.
+func rolloutBatches(strategy *model.RolloutStrategy, components []taskComponent) [][]taskComponent {
+	if strategy == nil || strategy.Type == model.SequentialRollout || strategy.Type == "" {
+		return splitBatches(components, 1)
+	}
+
+	if strategy.Type == model.CanaryRollout {
+		canary := strategy.CanarySize
+		if canary <= 0 {
+			canary = 1
+		}
+		if canary >= len(components) {
+			return [][]taskComponent{components}
+		}
+		// Без размера пачки канарейка продвигается на все остальные компоненты сразу
+		rest := components[canary:]
+		size := batchSize(strategy, len(rest))
+		if size == 0 {
+			size = len(rest)
+		}
+		return append([][]taskComponent{components[:canary]}, splitBatches(rest, size)...)
+	}
+
+	size := batchSize(strategy, len(components))
+	if size == 0 {
+		size = 1
+	}
+	return splitBatches(components, size)
+}
+
+// batchSize returns the batch size of the strategy for n components, 0 if
+// the strategy sets none.
+func batchSize(strategy *model.RolloutStrategy, n int) int {
+	if strategy.BatchSize > 0 {
+		return strategy.BatchSize
+	}
+	if strategy.BatchPercent > 0 {
+		size := (n*strategy.BatchPercent + 99) / 100
+		if size < 1 {
+			size = 1
+		}
+		return size
+	}
+	return 0
+}
+
+func splitBatches(components []taskComponent, size int) [][]taskComponent {
+	batches := make([][]taskComponent, 0, (len(components)+size-1)/size)
+	for start := 0; start < len(components); start += size {
+		end := min(start+size, len(components))
+		batches = append(batches, components[start:end])
+	}
+	return batches
+}
+
+// runBatch runs the task on every component of the batch in parallel and
+// returns the errors in the order of the batch.
+func (ts *TaskRegistry) runBatch(ctx context.Context, task *model.Task, batch []taskComponent) []error {
+	errs := make([]error, len(batch))
+	if len(batch) == 1 {
+		errs[0] = ts.runComponent(ctx, task, batch[0])
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for i, tc := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = ts.runComponent(ctx, task, tc)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// beforeBatch runs between two batches of a rollout: it checks the batches
+// already updated with the PostChecks of the task, waits for the pause of the
+// strategy and holds while the task is paused.
+func (ts *TaskRegistry) beforeBatch(ctx context.Context, task *model.Task, batch int, batches [][]taskComponent) error {
+	strategy := task.Strategy
+	if strategy == nil {
+		return nil
+	}
+
+	if task.PostChecks != nil {
+		if err := ts.runChecks(ctx, task, task.PostChecks); err != nil {
+			ts.event(task, fmt.Sprintf("Rollout aborted after batch %d/%d!", batch, len(batches)))
+			return fmt.Errorf("rollout aborted after batch %d/%d: %w", batch, len(batches), err)
+		}
+	}
+	if strategy.Type == model.CanaryRollout && batch == 1 {
+		promoted := 0
+		for _, rest := range batches[1:] {
+			promoted += len(rest)
+		}
+		ts.event(task, fmt.Sprintf("Canary passed, promoting to %d components", promoted))
+	}
+
+	if strategy.Pause > 0 {
+		ts.event(task, fmt.Sprintf("Pausing %s before batch %d/%d", time.Duration(strategy.Pause), batch+1, len(batches)))
+		timer := time.NewTimer(time.Duration(strategy.Pause))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	// Pause на запущенной раскатке останавливает её на границе пачек
+	if err := ts.waitPaused(ctx, task); err != nil {
+		return err
+	}
+	if taskStatus(task) != model.StatusRunning {
+		return ts.UpdateTaskStatus(task, model.StatusRunning)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package inforo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollout_RollingBatches(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{
+		Strategy: &model.RolloutStrategy{Type: model.RollingRollout, BatchPercent: 50, Pause: model.Duration(10 * time.Millisecond)},
+	})
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, controller.maxActive)
+
+	task, err := c.Tasks.Get("fleet-update")
+	require.NoError(t, err)
+	assert.Equal(t, 1, eventsContaining(task, "Pausing 10ms before batch 2/2"))
+}
+
+func TestRollout_RollingAbortsOnCheckFailure(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{
+		Strategy:   &model.RolloutStrategy{Type: model.RollingRollout, BatchSize: 2},
+		PostChecks: []*model.Check{newCheck("error-rate", "", "always")},
+	})
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	assert.EqualError(t, err, "rollout aborted after batch 1/2: check error-rate failed: error rate 5% above 1%")
+
+	assert.ElementsMatch(t, []string{"node-1", "node-2"}, controller.rolledBack)
+	assert.Equal(t, map[string]model.Status{
+		"node-1": model.StatusRollBack,
+		"node-2": model.StatusRollBack,
+		"node-3": model.StatusSkipped,
+		"node-4": model.StatusSkipped,
+	}, componentStatuses(t, c))
+}
+
+func TestRollout_CanaryPromotes(t *testing.T) {
+	c, _ := setupFleet(t, &model.Task{
+		Strategy:   &model.RolloutStrategy{Type: model.CanaryRollout},
+		PostChecks: []*model.Check{newCheck("error-rate", "", "")},
+	})
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	require.NoError(t, err)
+
+	task, err := c.Tasks.Get("fleet-update")
+	require.NoError(t, err)
+	assert.Equal(t, 1, eventsContaining(task, "Canary passed, promoting to 3 components"))
+	assert.Equal(t, model.StatusSuccess, task.StatusHistory.LastStatus)
+	// Проверки после канарейки и после всей раскатки
+	assert.Equal(t, 2, eventsContaining(task, "Check error-rate passed"))
+}
+
+func TestRollout_CanaryFailureAbortsAnyPolicy(t *testing.T) {
+	c, controller := setupFleet(t, &model.Task{
+		FailurePolicy: model.ContinueOnError,
+		Strategy:      &model.RolloutStrategy{Type: model.CanaryRollout},
+	}, "node-1")
+
+	_, err := c.Tasks.Fork("fleet-update", "")
+	assert.EqualError(t, err, "update failed")
+	assert.Equal(t, []string{"node-1"}, controller.rolledBack)
+
+	statuses := componentStatuses(t, c)
+	assert.Equal(t, model.StatusSkipped, statuses["node-2"])
+	assert.Equal(t, model.StatusSkipped, statuses["node-4"])
+}
+
+func TestRollout_PauseHoldsBetweenBatches(t *testing.T) {
+	c, _ := setupFleet(t, &model.Task{
+		Strategy: &model.RolloutStrategy{Type: model.RollingRollout, BatchSize: 2, Pause: model.Duration(50 * time.Millisecond)},
+	})
+
+	executionID, err := c.Tasks.ForkAsync("fleet-update", "")
+	require.NoError(t, err)
+	// Ставим на паузу уже запущенную раскатку, пока первая пачка обновляется
+	require.Eventually(t, func() bool {
+		return componentStatuses(t, c)["node-1"] == model.StatusSuccess
+	}, time.Second, time.Millisecond)
+	require.NoError(t, c.Tasks.Pause("fleet-update"))
+
+	require.Eventually(t, func() bool {
+		status, _, err := c.Tasks.Status("fleet-update")
+		return err == nil && status == model.StatusPaused
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, model.StatusPending, componentStatuses(t, c)["node-3"])
+
+	require.NoError(t, c.Tasks.Resume("fleet-update"))
+	execution, err := c.Executions.Wait(context.Background(), executionID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+	assert.Equal(t, model.StatusSuccess, componentStatuses(t, c)["node-3"])
+}
+
+func TestRollout_InvalidStrategy(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Strategy: &model.RolloutStrategy{Type: "blue-green"}})
+	assert.EqualError(t, err, "invalid rollout strategy 'blue-green'")
+
+	_, err = c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Strategy: &model.RolloutStrategy{Type: model.RollingRollout, BatchSize: 2, BatchPercent: 50}})
+	assert.EqualError(t, err, "rollout batch size and batch percent are mutually exclusive")
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/laplasd/inforo/model"
+)
+
+// BearerTokens authenticates requests by the token of their
+// "Authorization: Bearer" header.
+func BearerTokens(tokens map[string]*model.Identity) func(r *http.Request) (*model.Identity, error) {
+	return func(r *http.Request) (*model.Identity, error) {
+		header := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return nil, errors.New("missing bearer token")
+		}
+		// Сравниваем все токены за постоянное время, чтобы не выдать совпадение по таймингу
+		var identity *model.Identity
+		for known, id := range tokens {
+			if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+				identity = id
+			}
+		}
+		if identity == nil {
+			return nil, errors.New("invalid bearer token")
+		}
+		return identity, nil
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/laplasd/inforo/model"
+)
+
+// ClientOptions provides configuration options for initializing a Client.
+type ClientOptions struct {
+	URL        string       // Base URL of the API, e.g. "http://127.0.0.1:8080"
+	HTTPClient *http.Client // Custom HTTP client, http.DefaultClient by default
+	Actor      string       // Sent in the X-Inforo-Actor header, unverified by the server
+	Token      string       // Sent as a bearer token to a server with authentication
+}
+
+// Client calls the API served by Server.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	actor   string
+	token   string
+}
+
+// APIError is returned when the API answers with an error status.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func NewClient(opts ClientOptions) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.New("client requires a server URL")
+	}
+	if _, err := url.Parse(opts.URL); err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(opts.URL, "/"),
+		http:    opts.HTTPClient,
+		actor:   opts.Actor,
+		token:   opts.Token,
+	}, nil
+}
+
+// do sends body as JSON and decodes the response into out, if any.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.actor != "" {
+		req.Header.Set(ActorHeader, c.actor)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		errResp := &ErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Error == "" {
+			errResp.Error = resp.Status
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: errResp.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) RegisterComponent(ctx context.Context, comp *model.Component) (*model.Component, error) {
+	registered := &model.Component{}
+	return registered, c.do(ctx, http.MethodPost, "/components", comp, registered)
+}
+
+func (c *Client) GetComponent(ctx context.Context, id string) (*model.Component, error) {
+	comp := &model.Component{}
+	return comp, c.do(ctx, http.MethodGet, "/components/"+url.PathEscape(id), nil, comp)
+}
+
+func (c *Client) RegisterMonitoring(ctx context.Context, m *model.Monitoring) (*model.Monitoring, error) {
+	registered := &model.Monitoring{}
+	return registered, c.do(ctx, http.MethodPost, "/monitorings", m, registered)
+}
+
+func (c *Client) RegisterTask(ctx context.Context, task *model.Task) (*model.Task, error) {
+	registered := &model.Task{}
+	return registered, c.do(ctx, http.MethodPost, "/tasks", task, registered)
+}
+
+func (c *Client) GetTask(ctx context.Context, id string) (*model.Task, error) {
+	task := &model.Task{}
+	return task, c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, task)
+}
+
+// ForkTask starts the task and returns the ID of its execution.
+func (c *Client) ForkTask(ctx context.Context, id string) (string, error) {
+	started := &ExecutionResponse{}
+	err := c.do(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id)+"/fork", nil, started)
+	return started.ExecutionID, err
+}
+
+func (c *Client) RegisterPlan(ctx context.Context, tasks []*model.Task) (*model.Plan, error) {
+	plan := &model.Plan{}
+	return plan, c.do(ctx, http.MethodPost, "/plans", tasks, plan)
+}
+
+func (c *Client) GetPlan(ctx context.Context, id string) (*model.Plan, error) {
+	plan := &model.Plan{}
+	return plan, c.do(ctx, http.MethodGet, "/plans/"+url.PathEscape(id), nil, plan)
+}
+
+// SetMaxParallelism sets the limit of the graph, or of the whole plan with an
+// empty graphID.
+func (c *Client) SetMaxParallelism(ctx context.Context, planID string, graphID string, limit int) error {
+	return c.do(ctx, http.MethodPut, "/plans/"+url.PathEscape(planID)+"/parallelism",
+		ParallelismRequest{GraphID: graphID, Limit: limit}, nil)
+}
+
+// RunPlan starts the plan and returns the ID of its execution.
+func (c *Client) RunPlan(ctx context.Context, id string) (string, error) {
+	started := &ExecutionResponse{}
+	err := c.do(ctx, http.MethodPost, "/plans/"+url.PathEscape(id)+"/run", nil, started)
+	return started.ExecutionID, err
+}
+
+func (c *Client) StopPlan(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/plans/"+url.PathEscape(id)+"/stop", nil, nil)
+}
+
+func (c *Client) GetExecution(ctx context.Context, id string) (*model.Execution, error) {
+	execution := &model.Execution{}
+	return execution, c.do(ctx, http.MethodGet, "/executions/"+url.PathEscape(id), nil, execution)
+}
+
+// WaitExecution blocks until the execution finishes or ctx is done.
+func (c *Client) WaitExecution(ctx context.Context, id string) (*model.Execution, error) {
+	execution := &model.Execution{}
+	return execution, c.do(ctx, http.MethodGet, "/executions/"+url.PathEscape(id)+"/wait", nil, execution)
+}
+
+// QueryAudit returns the audit records of the server matching the query.
+func (c *Client) QueryAudit(ctx context.Context, query model.AuditQuery) ([]*model.AuditRecord, error) {
+	params := url.Values{}
+	if query.Entity != "" {
+		params.Set("entity", string(query.Entity))
+	}
+	if query.EntityID != "" {
+		params.Set("id", query.EntityID)
+	}
+	if query.Actor != "" {
+		params.Set("actor", query.Actor)
+	}
+	if !query.Since.IsZero() {
+		params.Set("since", query.Since.Format(time.RFC3339Nano))
+	}
+	if !query.Until.IsZero() {
+		params.Set("until", query.Until.Format(time.RFC3339Nano))
+	}
+	records := make([]*model.AuditRecord, 0)
+	return records, c.do(ctx, http.MethodGet, "/audit?"+params.Encode(), nil, &records)
+}
+
+// Identity returns the identity the server authenticated the client as.
+func (c *Client) Identity(ctx context.Context) (*model.Identity, error) {
+	identity := &model.Identity{}
+	return identity, c.do(ctx, http.MethodGet, "/identity", nil, identity)
+}
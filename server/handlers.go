@@ -0,0 +1,617 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/model"
+)
+
+// objectLocks returns the read locks guarding an object and its events.
+func objectLocks(mu *sync.RWMutex, events *model.EventHistory) []sync.Locker {
+	locks := []sync.Locker{mu.RLocker()}
+	if events != nil {
+		locks = append(locks, events.MU.RLocker())
+	}
+	return locks
+}
+
+// taskLocks returns the read locks guarding the tasks, their events and the
+// results of their checks.
+func taskLocks(tasks ...*model.Task) []sync.Locker {
+	locks := make([]sync.Locker, 0, 2*len(tasks))
+	for _, task := range tasks {
+		locks = append(locks, objectLocks(&task.MU, task.EventHistory)...)
+		for _, check := range task.PreChecks {
+			locks = append(locks, check.MU.RLocker())
+		}
+		for _, check := range task.PostChecks {
+			locks = append(locks, check.MU.RLocker())
+		}
+	}
+	return locks
+}
+
+func planLocks(plans ...*model.Plan) []sync.Locker {
+	locks := make([]sync.Locker, 0)
+	for _, plan := range plans {
+		locks = append(locks, objectLocks(&plan.MU, plan.EventHistory)...)
+		for _, graph := range plan.TaskGraphs {
+			ids := make([]string, 0, len(graph.Tasks))
+			for id := range graph.Tasks {
+				ids = append(ids, id)
+			}
+			// Порядок захвата блокировок одинаков во всех запросах
+			sort.Strings(ids)
+			for _, id := range ids {
+				locks = append(locks, taskLocks(graph.Tasks[id])...)
+			}
+		}
+	}
+	return locks
+}
+
+func eventsOf(history *model.EventHistory) []model.Event {
+	if history == nil {
+		return []model.Event{}
+	}
+	history.MU.RLock()
+	defer history.MU.RUnlock()
+	return append([]model.Event{}, history.Event...)
+}
+
+// --- Components ---
+
+func (s *Server) listComponents(w http.ResponseWriter, r *http.Request) {
+	components, err := s.coreOf(r).Components.List()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].ID < components[j].ID })
+
+	locks := make([]sync.Locker, 0, 2*len(components))
+	for _, comp := range components {
+		locks = append(locks, objectLocks(&comp.MU, comp.EventHistory)...)
+	}
+	s.writeJSON(w, http.StatusOK, components, locks...)
+}
+
+func (s *Server) registerComponent(w http.ResponseWriter, r *http.Request) {
+	req := &model.Component{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	comp, err := s.coreOf(r).Components.Register(&model.Component{
+		ID:       req.ID,
+		Name:     req.Name,
+		Type:     req.Type,
+		Version:  req.Version,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, comp, objectLocks(&comp.MU, comp.EventHistory)...)
+}
+
+func (s *Server) getComponent(w http.ResponseWriter, r *http.Request) {
+	comp, err := s.coreOf(r).Components.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, comp, objectLocks(&comp.MU, comp.EventHistory)...)
+}
+
+func (s *Server) updateComponent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.coreOf(r).Components.Get(id); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	req := &model.Component{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.coreOf(r).Components.Update(id, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.getComponent(w, r)
+}
+
+func (s *Server) deleteComponent(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Components.Delete(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) disableComponent(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Components.Disable(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.getComponent(w, r)
+}
+
+func (s *Server) enableComponent(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Components.Enable(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.getComponent(w, r)
+}
+
+// --- Monitorings ---
+
+func (s *Server) listMonitorings(w http.ResponseWriter, r *http.Request) {
+	monitorings, err := s.coreOf(r).Monitorings.List()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(monitorings, func(i, j int) bool { return monitorings[i].ID < monitorings[j].ID })
+
+	locks := make([]sync.Locker, 0, 2*len(monitorings))
+	for _, m := range monitorings {
+		locks = append(locks, objectLocks(&m.MU, m.EventHistory)...)
+	}
+	s.writeJSON(w, http.StatusOK, monitorings, locks...)
+}
+
+func (s *Server) registerMonitoring(w http.ResponseWriter, r *http.Request) {
+	req := &model.Monitoring{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	m, err := s.coreOf(r).Monitorings.Register(req.Type, req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, m, objectLocks(&m.MU, m.EventHistory)...)
+}
+
+func (s *Server) getMonitoring(w http.ResponseWriter, r *http.Request) {
+	m, err := s.coreOf(r).Monitorings.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, m, objectLocks(&m.MU, m.EventHistory)...)
+}
+
+func (s *Server) updateMonitoring(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.coreOf(r).Monitorings.Get(id); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	req := &model.Monitoring{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.coreOf(r).Monitorings.Update(id, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.getMonitoring(w, r)
+}
+
+func (s *Server) deleteMonitoring(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Monitorings.Delete(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Tasks ---
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.coreOf(r).Tasks.List()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	s.writeJSON(w, http.StatusOK, tasks, taskLocks(tasks...)...)
+}
+
+func (s *Server) registerTask(w http.ResponseWriter, r *http.Request) {
+	req := &model.Task{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	task, err := s.coreOf(r).Tasks.Register(req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, task, taskLocks(task)...)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	task, err := s.coreOf(r).Tasks.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, task, taskLocks(task)...)
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.coreOf(r).Tasks.Get(id); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	req := &model.Task{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.coreOf(r).Tasks.Update(id, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.getTask(w, r)
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Tasks.Delete(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// taskAction resolves the task of the request and runs action on it. A
+// missing task is reported as 404, a failed action as 409.
+func (s *Server) taskAction(w http.ResponseWriter, r *http.Request, action func(taskID string) (string, error)) {
+	taskID := r.PathValue("id")
+	if _, err := s.coreOf(r).Tasks.Get(taskID); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	executionID, err := action(taskID)
+	if err != nil {
+		s.writeError(w, http.StatusConflict, err)
+		return
+	}
+	if executionID == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, ExecutionResponse{ExecutionID: executionID})
+}
+
+func (s *Server) forkTask(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, func(taskID string) (string, error) {
+		return s.coreOf(r).Tasks.ForkAsync(taskID, r.URL.Query().Get("execution"))
+	})
+}
+
+func (s *Server) rollBackTask(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, func(taskID string) (string, error) {
+		return s.coreOf(r).Tasks.RollBackAsync(taskID, r.URL.Query().Get("execution"))
+	})
+}
+
+// approveRollBack runs the approved rollback to the end, so it answers with
+// the resulting task status instead of 202.
+func (s *Server) approveRollBack(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	if _, err := s.coreOf(r).Tasks.Get(taskID); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	executionID, err := s.coreOf(r).Tasks.ApproveRollBack(taskID, r.URL.Query().Get("execution"))
+	if err != nil {
+		s.writeError(w, http.StatusConflict, err)
+		return
+	}
+	status, _, err := s.coreOf(r).Tasks.Status(taskID)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, StatusResponse{Status: string(status), ExecutionID: executionID})
+}
+
+func (s *Server) stopTask(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, func(taskID string) (string, error) {
+		return "", s.coreOf(r).Tasks.Stop(taskID)
+	})
+}
+
+func (s *Server) pauseTask(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, func(taskID string) (string, error) {
+		return "", s.coreOf(r).Tasks.Pause(taskID)
+	})
+}
+
+func (s *Server) resumeTask(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, func(taskID string) (string, error) {
+		return "", s.coreOf(r).Tasks.Resume(taskID)
+	})
+}
+
+func (s *Server) taskStatus(w http.ResponseWriter, r *http.Request) {
+	status, executionID, err := s.coreOf(r).Tasks.Status(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, StatusResponse{Status: string(status), ExecutionID: executionID})
+}
+
+func (s *Server) taskHistory(w http.ResponseWriter, r *http.Request) {
+	task, err := s.coreOf(r).Tasks.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	task.MU.RLock()
+	history := task.StatusHistory
+	task.MU.RUnlock()
+	s.writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) taskEvents(w http.ResponseWriter, r *http.Request) {
+	task, err := s.coreOf(r).Tasks.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, eventsOf(task.EventHistory))
+}
+
+func (s *Server) taskExecutions(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	if _, err := s.coreOf(r).Tasks.Get(taskID); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	executions, err := s.coreOf(r).Executions.ListByTask(taskID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeExecutions(w, executions)
+}
+
+// --- Plans ---
+
+func (s *Server) listPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := s.coreOf(r).Plans.List()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].ID < plans[j].ID })
+	s.writeJSON(w, http.StatusOK, plans, planLocks(plans...)...)
+}
+
+func (s *Server) registerPlan(w http.ResponseWriter, r *http.Request) {
+	tasks := make([]*model.Task, 0)
+	if err := readJSON(r, &tasks); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	plan, err := s.coreOf(r).Plans.Register(tasks)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, plan, planLocks(plan)...)
+}
+
+func (s *Server) getPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.coreOf(r).Plans.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, plan, planLocks(plan)...)
+}
+
+func (s *Server) deletePlan(w http.ResponseWriter, r *http.Request) {
+	if err := s.coreOf(r).Plans.Delete(r.PathValue("id")); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// planAction resolves the plan of the request and runs action on it. A
+// missing plan is reported as 404, a failed action as 409.
+func (s *Server) planAction(w http.ResponseWriter, r *http.Request, action func(planID string) (string, error)) {
+	planID := r.PathValue("id")
+	if _, err := s.coreOf(r).Plans.Get(planID); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	executionID, err := action(planID)
+	if err != nil {
+		s.writeError(w, http.StatusConflict, err)
+		return
+	}
+	if executionID == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, ExecutionResponse{ExecutionID: executionID})
+}
+
+func (s *Server) setPlanParallelism(w http.ResponseWriter, r *http.Request) {
+	req := &ParallelismRequest{}
+	if err := readJSON(r, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.planAction(w, r, func(planID string) (string, error) {
+		return "", s.coreOf(r).Plans.SetMaxParallelism(planID, req.GraphID, req.Limit)
+	})
+}
+
+func (s *Server) runPlan(w http.ResponseWriter, r *http.Request) {
+	s.planAction(w, r, func(planID string) (string, error) {
+		return s.coreOf(r).Plans.RunAsync(planID, r.URL.Query().Get("execution"))
+	})
+}
+
+func (s *Server) stopPlan(w http.ResponseWriter, r *http.Request) {
+	s.planAction(w, r, func(planID string) (string, error) {
+		return "", s.coreOf(r).Plans.Stop(planID)
+	})
+}
+
+func (s *Server) pausePlan(w http.ResponseWriter, r *http.Request) {
+	s.planAction(w, r, func(planID string) (string, error) {
+		return "", s.coreOf(r).Plans.Pause(planID)
+	})
+}
+
+func (s *Server) resumePlan(w http.ResponseWriter, r *http.Request) {
+	s.planAction(w, r, s.coreOf(r).Plans.Resume)
+}
+
+func (s *Server) planStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.coreOf(r).Plans.Status(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, StatusResponse{Status: string(status)})
+}
+
+func (s *Server) planHistory(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.coreOf(r).Plans.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	plan.MU.RLock()
+	history := plan.StatusHistory
+	plan.MU.RUnlock()
+	s.writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) planEvents(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.coreOf(r).Plans.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, eventsOf(plan.EventHistory))
+}
+
+func (s *Server) planExecutions(w http.ResponseWriter, r *http.Request) {
+	planID := r.PathValue("id")
+	if _, err := s.coreOf(r).Plans.Get(planID); err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	executions, err := s.coreOf(r).Executions.ListByPlan(planID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeExecutions(w, executions)
+}
+
+// --- Executions ---
+
+// Реестр выполнений отдаёт снимки, их можно кодировать без блокировок
+func (s *Server) writeExecutions(w http.ResponseWriter, executions []*model.Execution) {
+	s.writeJSON(w, http.StatusOK, executions)
+}
+
+func (s *Server) listExecutions(w http.ResponseWriter, r *http.Request) {
+	executions, err := s.coreOf(r).Executions.List()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeExecutions(w, executions)
+}
+
+func (s *Server) getExecution(w http.ResponseWriter, r *http.Request) {
+	execution, err := s.coreOf(r).Executions.Get(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, execution)
+}
+
+// waitExecution blocks until the execution finishes or the client goes away.
+func (s *Server) waitExecution(w http.ResponseWriter, r *http.Request) {
+	execution, err := s.coreOf(r).Executions.Wait(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, r.Context().Err()) {
+			s.writeError(w, http.StatusRequestTimeout, err)
+			return
+		}
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, execution)
+}
+
+// --- Audit ---
+
+// queryAudit returns the audit records matching the entity, id, actor,
+// since and until (RFC 3339) query parameters.
+// With authentication only admins may read it.
+func (s *Server) queryAudit(w http.ResponseWriter, r *http.Request) {
+	if identity, ok := s.identityOf(r); ok && !identity.HasRole(model.RoleAdmin) {
+		s.writeError(w, http.StatusForbidden, &api.ForbiddenError{Actor: identity.Name,
+			Operation: string(model.AccessRead), Entity: "audit", Reason: "requires role admin"})
+		return
+	}
+	params := r.URL.Query()
+	query := model.AuditQuery{
+		Entity:   model.EntityType(params.Get("entity")),
+		EntityID: params.Get("id"),
+		Actor:    params.Get("actor"),
+	}
+	for name, bound := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", name, err))
+				return
+			}
+			*bound = t
+		}
+	}
+
+	records, err := s.audit.Query(query)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, records)
+}
+
+// getIdentity returns the authenticated caller.
+func (s *Server) getIdentity(w http.ResponseWriter, r *http.Request) {
+	identity, _ := s.identityOf(r)
+	s.writeJSON(w, http.StatusOK, identity)
+}
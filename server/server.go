@@ -0,0 +1,280 @@
+// Package server exposes a Core over a local HTTP/JSON API.
+//
+// Resources are encoded with the model types. Process methods (fork, run,
+// rollback) start asynchronous executions and return their IDs; the
+// executions endpoints report and wait for their results.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/audit"
+	"github.com/laplasd/inforo/authz"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServerOptions provides configuration options for initializing a Server.
+type ServerOptions struct {
+	Core   *inforo.Core   // Core served by the API, required
+	Logger *logrus.Logger // Custom logger instance, the logger of the Core by default
+	// Metrics is served on GET /metrics if set, e.g. metrics.Metrics.Handler()
+	Metrics http.Handler
+	// Audit records the changes made through the API and is served on
+	// GET /audit if set. The actor is the authenticated identity. Without
+	// Authenticate the actor is not authenticated: it is recorded as
+	// "unverified:" followed by the X-Inforo-Actor header, or "anonymous".
+	Audit api.AuditLog
+	// Authenticate identifies the caller of every request, e.g.
+	// BearerTokens(tokens). If set, requests act through an authorized Core
+	// of the identity: an error answers 401, a forbidden operation 403.
+	Authenticate func(r *http.Request) (*model.Identity, error)
+	// Policy holds the access rules of Authenticate, roles only if nil
+	Policy *authz.Policy
+}
+
+// Server is an http.Handler serving the API of a Core.
+type Server struct {
+	core         *inforo.Core
+	audit        api.AuditLog
+	authenticate func(r *http.Request) (*model.Identity, error)
+	policy       *authz.Policy
+	logger       *logrus.Logger
+	mux          *http.ServeMux
+}
+
+// ActorHeader names the caller of a request in the audit log. The server
+// does not verify it: without Authenticate the actor is recorded with the
+// "unverified:" prefix.
+const ActorHeader = "X-Inforo-Actor"
+
+// coreKey — ключ Core запроса в контексте
+type coreKey struct{}
+
+// identityKey — ключ аутентифицированного вызывающего в контексте
+type identityKey struct{}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"Error"`
+}
+
+// ExecutionResponse is returned by the methods that start an execution.
+type ExecutionResponse struct {
+	ExecutionID string `json:"ExecutionID"`
+}
+
+// StatusResponse is returned by the status endpoints.
+type StatusResponse struct {
+	Status      string `json:"Status"`
+	ExecutionID string `json:"ExecutionID,omitempty"`
+}
+
+// ParallelismRequest is the body of PUT /plans/{id}/parallelism. An empty
+// GraphID sets the limit of the whole plan.
+type ParallelismRequest struct {
+	GraphID string `json:"GraphID,omitempty"`
+	Limit   int    `json:"Limit"`
+}
+
+func NewServer(opts ServerOptions) (*Server, error) {
+	if opts.Core == nil {
+		return nil, errors.New("server requires a core")
+	}
+	if opts.Logger == nil {
+		opts.Logger = opts.Core.Logger
+	}
+	if opts.Logger == nil {
+		opts.Logger = inforo.NewNullLogger()
+	}
+
+	s := &Server{
+		core:         opts.Core,
+		audit:        opts.Audit,
+		authenticate: opts.Authenticate,
+		policy:       opts.Policy,
+		logger:       opts.Logger,
+		mux:          http.NewServeMux(),
+	}
+	s.routes()
+	if opts.Authenticate != nil {
+		s.mux.HandleFunc("GET /identity", s.getIdentity)
+	}
+	if opts.Metrics != nil {
+		s.mux.Handle("GET /metrics", opts.Metrics)
+	}
+	if opts.Audit != nil {
+		s.mux.HandleFunc("GET /audit", s.queryAudit)
+	}
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debugf("Server.ServeHTTP() - %s %s", r.Method, r.URL.Path)
+	core := s.core
+	actor := "anonymous"
+	if header := r.Header.Get(ActorHeader); header != "" {
+		// Заголовок задаёт сам вызывающий, в журнале это видно
+		actor = "unverified:" + header
+	}
+	if s.authenticate != nil {
+		identity, err := s.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		core, err = authz.NewAuthorizedCore(authz.AuthzOptions{Core: core, Policy: s.policy, Identity: identity})
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		actor = identity.Name
+		r = r.WithContext(context.WithValue(r.Context(), identityKey{}, identity))
+	}
+	if s.audit != nil {
+		// Аудит снаружи авторизации: запрещённые попытки тоже попадают в журнал
+		var err error
+		core, err = audit.NewAuditedCore(audit.AuditOptions{Core: core, Sink: s.audit, Actor: actor, Logger: s.logger})
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if core != s.core {
+		r = r.WithContext(context.WithValue(r.Context(), coreKey{}, core))
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// coreOf returns the Core serving the request: the Core authorized for the
+// caller and audited, when the server does so.
+func (s *Server) coreOf(r *http.Request) *inforo.Core {
+	if core, ok := r.Context().Value(coreKey{}).(*inforo.Core); ok {
+		return core
+	}
+	return s.core
+}
+
+// identityOf returns the authenticated caller of the request, if any.
+func (s *Server) identityOf(r *http.Request) (*model.Identity, bool) {
+	identity, ok := r.Context().Value(identityKey{}).(*model.Identity)
+	return identity, ok
+}
+
+// ListenAndServe serves the API on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	s.logger.Infof("Server listening on %s", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /components", s.listComponents)
+	s.mux.HandleFunc("POST /components", s.registerComponent)
+	s.mux.HandleFunc("GET /components/{id}", s.getComponent)
+	s.mux.HandleFunc("PUT /components/{id}", s.updateComponent)
+	s.mux.HandleFunc("DELETE /components/{id}", s.deleteComponent)
+	s.mux.HandleFunc("POST /components/{id}/disable", s.disableComponent)
+	s.mux.HandleFunc("POST /components/{id}/enable", s.enableComponent)
+
+	s.mux.HandleFunc("GET /monitorings", s.listMonitorings)
+	s.mux.HandleFunc("POST /monitorings", s.registerMonitoring)
+	s.mux.HandleFunc("GET /monitorings/{id}", s.getMonitoring)
+	s.mux.HandleFunc("PUT /monitorings/{id}", s.updateMonitoring)
+	s.mux.HandleFunc("DELETE /monitorings/{id}", s.deleteMonitoring)
+
+	s.mux.HandleFunc("GET /tasks", s.listTasks)
+	s.mux.HandleFunc("POST /tasks", s.registerTask)
+	s.mux.HandleFunc("GET /tasks/{id}", s.getTask)
+	s.mux.HandleFunc("PUT /tasks/{id}", s.updateTask)
+	s.mux.HandleFunc("DELETE /tasks/{id}", s.deleteTask)
+	s.mux.HandleFunc("POST /tasks/{id}/fork", s.forkTask)
+	s.mux.HandleFunc("POST /tasks/{id}/rollback", s.rollBackTask)
+	s.mux.HandleFunc("POST /tasks/{id}/approve-rollback", s.approveRollBack)
+	s.mux.HandleFunc("POST /tasks/{id}/stop", s.stopTask)
+	s.mux.HandleFunc("POST /tasks/{id}/pause", s.pauseTask)
+	s.mux.HandleFunc("POST /tasks/{id}/resume", s.resumeTask)
+	s.mux.HandleFunc("GET /tasks/{id}/status", s.taskStatus)
+	s.mux.HandleFunc("GET /tasks/{id}/history", s.taskHistory)
+	s.mux.HandleFunc("GET /tasks/{id}/events", s.taskEvents)
+	s.mux.HandleFunc("GET /tasks/{id}/executions", s.taskExecutions)
+
+	s.mux.HandleFunc("GET /plans", s.listPlans)
+	s.mux.HandleFunc("POST /plans", s.registerPlan)
+	s.mux.HandleFunc("GET /plans/{id}", s.getPlan)
+	s.mux.HandleFunc("DELETE /plans/{id}", s.deletePlan)
+	s.mux.HandleFunc("PUT /plans/{id}/parallelism", s.setPlanParallelism)
+	s.mux.HandleFunc("POST /plans/{id}/run", s.runPlan)
+	s.mux.HandleFunc("POST /plans/{id}/stop", s.stopPlan)
+	s.mux.HandleFunc("POST /plans/{id}/pause", s.pausePlan)
+	s.mux.HandleFunc("POST /plans/{id}/resume", s.resumePlan)
+	s.mux.HandleFunc("GET /plans/{id}/status", s.planStatus)
+	s.mux.HandleFunc("GET /plans/{id}/history", s.planHistory)
+	s.mux.HandleFunc("GET /plans/{id}/events", s.planEvents)
+	s.mux.HandleFunc("GET /plans/{id}/executions", s.planExecutions)
+
+	s.mux.HandleFunc("GET /executions", s.listExecutions)
+	s.mux.HandleFunc("GET /executions/{id}", s.getExecution)
+	s.mux.HandleFunc("GET /executions/{id}/wait", s.waitExecution)
+}
+
+// writeJSON encodes v as the response body. The locks are read locks of the
+// objects in v: executions keep changing them while the response is written.
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}, locks ...sync.Locker) {
+	for _, lock := range locks {
+		lock.Lock()
+	}
+	data, err := json.Marshal(v)
+	for i := len(locks) - 1; i >= 0; i-- {
+		locks[i].Unlock()
+	}
+	if err != nil {
+		s.logger.Errorf("Server.writeJSON() - failed to encode response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// writeError answers 403 to forbidden operations whatever the status.
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	var forbidden *api.ForbiddenError
+	if errors.As(err, &forbidden) {
+		status = http.StatusForbidden
+	}
+	s.logger.Debugf("Server.writeError() - %d: %v", status, err)
+	s.writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// readJSON decodes the request body into v, rejecting unknown fields.
+func readJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return errors.New("invalid request body: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/audit"
+	"github.com/laplasd/inforo/model"
+	"github.com/laplasd/inforo/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- deploy controller: fails to deploy the "broken" image ---
+type deployController struct{}
+
+func (d *deployController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "broken" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+func (d *deployController) ValideTask(r map[string]string) error      { return nil }
+func (d *deployController) ValideComponent(m map[string]string) error { return nil }
+func (d *deployController) CheckComponent(m map[string]string) error  { return nil }
+
+// --- blocking controller: holds every run until released ---
+type blockingController struct {
+	deployController
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingController) RunTask(r map[string]string, p map[string]string) error {
+	b.started <- struct{}{}
+	<-b.release
+	return nil
+}
+
+// --- passing monitoring controller: every check passes ---
+type passingMonitoringController struct{}
+
+func (p *passingMonitoringController) RunCheck(meta map[string]string) error             { return nil }
+func (p *passingMonitoringController) CheckMonitoring(config map[string]string) error    { return nil }
+func (p *passingMonitoringController) ValidateCheck(meta map[string]string) error        { return nil }
+func (p *passingMonitoringController) ValidateMonitoring(config map[string]string) error { return nil }
+
+func setupServer(t *testing.T) (*inforo.Core, *httptest.Server) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+
+	srv, err := server.NewServer(server.ServerOptions{Core: c})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return c, ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path string, body interface{}, out interface{}) int {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func registerComponent(t *testing.T, ts *httptest.Server) {
+	code := do(t, ts, http.MethodPost, "/components", map[string]interface{}{
+		"ID": "web", "Name": "Web", "Type": "deploy", "Version": "1.0.0",
+	}, nil)
+	require.Equal(t, http.StatusCreated, code)
+}
+
+func TestNewServer_RequiresCore(t *testing.T) {
+	_, err := server.NewServer(server.ServerOptions{})
+	assert.EqualError(t, err, "server requires a core")
+}
+
+func TestServer_ComponentsCRUD(t *testing.T) {
+	_, ts := setupServer(t)
+	registerComponent(t, ts)
+
+	components := []*model.Component{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/components", nil, &components))
+	require.Len(t, components, 1)
+	assert.Equal(t, "web", components[0].ID)
+
+	updated := &model.Component{}
+	code := do(t, ts, http.MethodPut, "/components/web", map[string]interface{}{
+		"Name": "Web", "Type": "deploy", "Version": "1.1.0",
+	}, updated)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1.1.0", updated.Version)
+
+	disabled := &model.Component{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodPost, "/components/web/disable", nil, disabled))
+	assert.Equal(t, model.StatusDisable, disabled.StatusHistory.LastStatus)
+
+	assert.Equal(t, http.StatusNoContent, do(t, ts, http.MethodDelete, "/components/web", nil, nil))
+
+	errResp := &server.ErrorResponse{}
+	assert.Equal(t, http.StatusNotFound, do(t, ts, http.MethodGet, "/components/web", nil, errResp))
+	assert.Equal(t, "component not found", errResp.Error)
+}
+
+func TestServer_RejectsInvalidBody(t *testing.T) {
+	_, ts := setupServer(t)
+
+	errResp := &server.ErrorResponse{}
+	code := do(t, ts, http.MethodPost, "/components", map[string]interface{}{"Unknown": true}, errResp)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, errResp.Error, "invalid request body")
+}
+
+func TestServer_ForkTaskAndWait(t *testing.T) {
+	_, ts := setupServer(t)
+	registerComponent(t, ts)
+
+	task := &model.Task{}
+	code := do(t, ts, http.MethodPost, "/tasks", map[string]interface{}{
+		"ID": "deploy-web", "Type": "update", "Components": []string{"web"},
+		"MetaData": map[string]string{"image": "app:2.0.0"},
+	}, task)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, model.StatusCreated, task.StatusHistory.LastStatus)
+
+	started := &server.ExecutionResponse{}
+	require.Equal(t, http.StatusAccepted, do(t, ts, http.MethodPost, "/tasks/deploy-web/fork", nil, started))
+	require.NotEmpty(t, started.ExecutionID)
+
+	execution := &model.Execution{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/executions/"+started.ExecutionID+"/wait", nil, execution))
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+	assert.Equal(t, "deploy-web", execution.TaskID)
+
+	status := &server.StatusResponse{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/tasks/deploy-web/status", nil, status))
+	assert.Equal(t, string(model.StatusSuccess), status.Status)
+
+	events := []model.Event{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/tasks/deploy-web/events", nil, &events))
+	require.NotEmpty(t, events)
+	assert.Equal(t, "Created task!", events[0].Message)
+
+	executions := []*model.Execution{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/tasks/deploy-web/executions", nil, &executions))
+	assert.Len(t, executions, 1)
+}
+
+func TestServer_TaskActionErrors(t *testing.T) {
+	_, ts := setupServer(t)
+	registerComponent(t, ts)
+	require.Equal(t, http.StatusCreated, do(t, ts, http.MethodPost, "/tasks", map[string]interface{}{
+		"ID": "deploy-web", "Type": "update", "Components": []string{"web"},
+	}, nil))
+
+	errResp := &server.ErrorResponse{}
+	assert.Equal(t, http.StatusNotFound, do(t, ts, http.MethodPost, "/tasks/missing/fork", nil, errResp))
+
+	assert.Equal(t, http.StatusConflict, do(t, ts, http.MethodPost, "/tasks/deploy-web/resume", nil, errResp))
+	assert.Equal(t, "task 'deploy-web' is not paused", errResp.Error)
+
+	assert.Equal(t, http.StatusNoContent, do(t, ts, http.MethodPost, "/tasks/deploy-web/pause", nil, nil))
+	assert.Equal(t, http.StatusNoContent, do(t, ts, http.MethodPost, "/tasks/deploy-web/resume", nil, nil))
+}
+
+func TestServer_ApproveRollBack(t *testing.T) {
+	_, ts := setupServer(t)
+	registerComponent(t, ts)
+	require.Equal(t, http.StatusCreated, do(t, ts, http.MethodPost, "/tasks", map[string]interface{}{
+		"ID": "deploy-web", "Type": "update", "Components": []string{"web"},
+		"MetaData": map[string]string{"image": "broken"},
+		"RollBack": map[string]interface{}{"Type": "manual", "MetaData": map[string]string{"image": "app:1.0.0"}},
+	}, nil))
+
+	started := &server.ExecutionResponse{}
+	require.Equal(t, http.StatusAccepted, do(t, ts, http.MethodPost, "/tasks/deploy-web/fork", nil, started))
+	require.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/executions/"+started.ExecutionID+"/wait", nil, nil))
+
+	// Откат выполняется синхронно, ответ несёт его результат
+	status := &server.StatusResponse{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodPost, "/tasks/deploy-web/approve-rollback", nil, status))
+	assert.Equal(t, string(model.StatusRollBack), status.Status)
+	assert.NotEmpty(t, status.ExecutionID)
+
+	errResp := &server.ErrorResponse{}
+	assert.Equal(t, http.StatusConflict, do(t, ts, http.MethodPost, "/tasks/deploy-web/approve-rollback", nil, errResp))
+	assert.Equal(t, "task is not awaiting rollback approval, status 'rollback'", errResp.Error)
+}
+
+func TestServer_RunPlan(t *testing.T) {
+	_, ts := setupServer(t)
+	registerComponent(t, ts)
+
+	plan := &model.Plan{}
+	code := do(t, ts, http.MethodPost, "/plans", []map[string]interface{}{
+		{"ID": "prepare", "Type": "update", "Components": []string{"web"}, "MetaData": map[string]string{}},
+		{"ID": "deploy", "Type": "update", "Components": []string{"web"}, "MetaData": map[string]string{"image": "broken"},
+			"DependsOn": []map[string]string{{"ID": "prepare", "Type": "strict"}}},
+	}, plan)
+	require.Equal(t, http.StatusCreated, code)
+	require.NotEmpty(t, plan.ID)
+
+	assert.Equal(t, http.StatusNoContent, do(t, ts, http.MethodPut, "/plans/"+plan.ID+"/parallelism",
+		server.ParallelismRequest{Limit: 1}, nil))
+
+	started := &server.ExecutionResponse{}
+	require.Equal(t, http.StatusAccepted, do(t, ts, http.MethodPost, "/plans/"+plan.ID+"/run", nil, started))
+
+	execution := &model.Execution{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/executions/"+started.ExecutionID+"/wait", nil, execution))
+	assert.Equal(t, model.StatusFailed, execution.Status)
+	assert.Contains(t, execution.Error, "deploy failed")
+
+	status := &server.StatusResponse{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/plans/"+plan.ID+"/status", nil, status))
+	assert.Equal(t, string(model.StatusFailed), status.Status)
+
+	got := &model.Plan{}
+	assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/plans/"+plan.ID, nil, got))
+	assert.Equal(t, 1, got.MaxParallelism)
+}
+
+func TestServer_ReadPlanWhileControlled(t *testing.T) {
+	c, ts := setupServer(t)
+	ctl := &blockingController{started: make(chan struct{}, 1), release: make(chan struct{})}
+	require.NoError(t, c.Controllers.Register("blocking", ctl))
+	_, err := c.Components.Register(&model.Component{ID: "slow", Type: "blocking", Version: "1.0.0"})
+	require.NoError(t, err)
+	plan, err := c.Plans.Register([]*model.Task{{ID: "deploy", Type: model.UpdateTask, Components: []string{"slow"}}})
+	require.NoError(t, err)
+	executionID, err := c.Plans.RunAsync(plan.ID, "")
+	require.NoError(t, err)
+	<-ctl.started
+
+	// Чтение плана через API идёт параллельно с изменением его статуса
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			assert.NoError(t, c.Plans.Pause(plan.ID))
+			assert.NoError(t, c.Plans.SetMaxParallelism(plan.ID, "", i%4))
+			_, err := c.Plans.Resume(plan.ID)
+			assert.NoError(t, err)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/plans/"+plan.ID, nil, &model.Plan{}))
+	}
+	close(done)
+	wg.Wait()
+
+	close(ctl.release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	execution, err := c.Executions.Wait(ctx, executionID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+}
+
+func TestServer_ReadTaskAndComponentWhileChanged(t *testing.T) {
+	c, ts := setupServer(t)
+	registerComponent(t, ts)
+	_, err := c.Components.Register(&model.Component{ID: "db", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	require.NoError(t, c.MonitorControllers.Register("passing", &passingMonitoringController{}))
+	_, err = c.Monitorings.Register("passing", &model.Monitoring{ID: "prometheus", Type: "passing"})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "deploy", Type: model.UpdateTask, Components: []string{"web"},
+		PostChecks: []*model.Check{{ID: "error-rate", MonitoringID: "prometheus"}}})
+	require.NoError(t, err)
+
+	// Проверки задачи и статус компонента меняются, пока их читают через API
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_, err := c.Tasks.Fork("deploy", "")
+			assert.NoError(t, err)
+			assert.NoError(t, c.Components.Disable("db"))
+			assert.NoError(t, c.Components.Enable("db"))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/tasks/deploy", nil, &model.Task{}))
+		assert.Equal(t, http.StatusOK, do(t, ts, http.MethodGet, "/components/db", nil, &model.Component{}))
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestServer_AuditLog(t *testing.T) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	srv, err := server.NewServer(server.ServerOptions{Core: c, Audit: audit.NewMemoryLog()})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	client, err := server.NewClient(server.ClientOptions{URL: ts.URL, Actor: "alice"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = client.RegisterComponent(ctx, &model.Component{ID: "web", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	_, err = client.RegisterTask(ctx, &model.Task{ID: "deploy-web", Type: model.UpdateTask, Components: []string{"web"},
+		Metadata: map[string]string{"image": "app:2.0.0"}})
+	require.NoError(t, err)
+	executionID, err := client.ForkTask(ctx, "deploy-web")
+	require.NoError(t, err)
+	_, err = client.WaitExecution(ctx, executionID)
+	require.NoError(t, err)
+
+	records, err := client.QueryAudit(ctx, model.AuditQuery{Entity: model.EntityTask, EntityID: "deploy-web"})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, model.AuditRegister, records[0].Operation)
+	assert.Equal(t, model.AuditFork, records[1].Operation)
+	assert.Equal(t, "unverified:alice", records[1].Actor)
+	assert.Equal(t, executionID, records[1].ExecutionID)
+
+	// Без заголовка вызывающий анонимен
+	require.Equal(t, http.StatusOK, do(t, ts, http.MethodPost, "/components/web/disable", nil, nil))
+	records, err = client.QueryAudit(ctx, model.AuditQuery{Actor: "anonymous"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, model.AuditDisable, records[0].Operation)
+
+	assert.Equal(t, http.StatusBadRequest, do(t, ts, http.MethodGet, "/audit?since=yesterday", nil, nil))
+}
+
+func TestServer_Authorization(t *testing.T) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("deploy", &deployController{}))
+	auditLog := audit.NewMemoryLog()
+	srv, err := server.NewServer(server.ServerOptions{
+		Core:  c,
+		Audit: auditLog,
+		Authenticate: server.BearerTokens(map[string]*model.Identity{
+			"admin-token":    {Name: "alice", Roles: []model.Role{model.RoleAdmin}},
+			"operator-token": {Name: "oscar", Roles: []model.Role{model.RoleOperator}},
+		}),
+	})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	// Без токена или с чужим токеном запрос не аутентифицирован
+	assert.Equal(t, http.StatusUnauthorized, do(t, ts, http.MethodGet, "/components", nil, nil))
+	stranger, err := server.NewClient(server.ClientOptions{URL: ts.URL, Token: "guess"})
+	require.NoError(t, err)
+	_, err = stranger.Identity(ctx)
+	var apiErr *server.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	operator, err := server.NewClient(server.ClientOptions{URL: ts.URL, Token: "operator-token", Actor: "mallory"})
+	require.NoError(t, err)
+	identity, err := operator.Identity(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "oscar", identity.Name)
+
+	_, err = operator.RegisterComponent(ctx, &model.Component{ID: "web", Type: "deploy", Version: "1.0.0"})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "forbidden: oscar may not register component web: requires role admin", apiErr.Message)
+	_, err = operator.QueryAudit(ctx, model.AuditQuery{})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	admin, err := server.NewClient(server.ClientOptions{URL: ts.URL, Token: "admin-token"})
+	require.NoError(t, err)
+	_, err = admin.RegisterComponent(ctx, &model.Component{ID: "web", Type: "deploy", Version: "1.0.0"})
+	require.NoError(t, err)
+	_, err = admin.RegisterTask(ctx, &model.Task{ID: "deploy-web", Type: model.UpdateTask, Components: []string{"web"}})
+	require.NoError(t, err)
+	_, err = operator.ForkTask(ctx, "deploy-web")
+	require.NoError(t, err)
+
+	// В журнал попадает аутентифицированное имя, а не заголовок, и запрещённые попытки
+	records, err := admin.QueryAudit(ctx, model.AuditQuery{Actor: "oscar"})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, model.AuditRegister, records[0].Operation)
+	assert.Equal(t, model.AuditFailure, records[0].Result)
+	assert.Equal(t, model.AuditFork, records[1].Operation)
+	assert.Equal(t, model.AuditSuccess, records[1].Result)
+}
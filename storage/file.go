@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/laplasd/inforo/api"
+)
+
+const fileExt = ".json"
+
+// FileStorage keeps every document in its own JSON file:
+//
+//	<dir>/<collection>/<id>.json
+//
+// Writes go to a temporary file that is synced and renamed over the
+// previous version, so a crash never leaves a half-written document.
+type FileStorage struct {
+	dir string
+	mu  *sync.RWMutex
+}
+
+// NewFileStorage opens (and creates if needed) a file storage rooted at dir.
+func NewFileStorage(dir string) (api.Storage, error) {
+	if dir == "" {
+		return nil, errors.New("storage directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FileStorage{
+		dir: dir,
+		mu:  &sync.RWMutex{},
+	}, nil
+}
+
+func (fs *FileStorage) Put(collection string, id string, value []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	dir := filepath.Join(fs.dir, url.PathEscape(collection))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", collection, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", collection, id, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s/%s: %w", collection, id, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s/%s: %w", collection, id, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", collection, id, err)
+	}
+
+	if err := os.Rename(tmp.Name(), fs.path(collection, id)); err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", collection, id, err)
+	}
+	return nil
+}
+
+func (fs *FileStorage) Delete(collection string, id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	err := os.Remove(fs.path(collection, id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s/%s: %w", collection, id, err)
+	}
+	return nil
+}
+
+func (fs *FileStorage) List(collection string) (map[string][]byte, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	result := make(map[string][]byte)
+
+	dir := filepath.Join(fs.dir, url.PathEscape(collection))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection %s: %w", collection, err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, fileExt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid document name %s in collection %s: %w", name, collection, err)
+		}
+		value, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s/%s: %w", collection, id, err)
+		}
+		result[id] = value
+	}
+	return result, nil
+}
+
+func (fs *FileStorage) Close() error {
+	return nil
+}
+
+func (fs *FileStorage) path(collection string, id string) string {
+	return filepath.Join(fs.dir, url.PathEscape(collection), url.PathEscape(id)+fileExt)
+}
@@ -0,0 +1,59 @@
+// Package storage provides api.Storage implementations used to persist the
+// state of the inforo registries.
+package storage
+
+import (
+	"sync"
+
+	"github.com/laplasd/inforo/api"
+)
+
+// MemoryStorage keeps documents in process memory. State does not survive a
+// restart; it is the default storage of the registries.
+type MemoryStorage struct {
+	collections map[string]map[string][]byte
+	mu          *sync.RWMutex
+}
+
+func NewMemoryStorage() api.Storage {
+	return &MemoryStorage{
+		collections: make(map[string]map[string][]byte),
+		mu:          &sync.RWMutex{},
+	}
+}
+
+func (ms *MemoryStorage) Put(collection string, id string, value []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	docs, exists := ms.collections[collection]
+	if !exists {
+		docs = make(map[string][]byte)
+		ms.collections[collection] = docs
+	}
+	docs[id] = append([]byte(nil), value...)
+	return nil
+}
+
+func (ms *MemoryStorage) Delete(collection string, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.collections[collection], id)
+	return nil
+}
+
+func (ms *MemoryStorage) List(collection string) (map[string][]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	result := make(map[string][]byte, len(ms.collections[collection]))
+	for id, value := range ms.collections[collection] {
+		result[id] = append([]byte(nil), value...)
+	}
+	return result, nil
+}
+
+func (ms *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laplasd/inforo/api"
+	"github.com/laplasd/inforo/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStorage(t *testing.T, s api.Storage) {
+	t.Helper()
+
+	docs, err := s.List("components")
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	require.NoError(t, s.Put("components", "web/1", []byte(`{"ID":"web/1"}`)))
+	require.NoError(t, s.Put("components", "db", []byte(`{"ID":"db"}`)))
+	require.NoError(t, s.Put("components", "db", []byte(`{"ID":"db","Version":"2"}`)))
+	require.NoError(t, s.Put("tasks", "db", []byte(`{"ID":"task"}`)))
+
+	docs, err = s.List("components")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"web/1": []byte(`{"ID":"web/1"}`),
+		"db":    []byte(`{"ID":"db","Version":"2"}`),
+	}, docs)
+
+	require.NoError(t, s.Delete("components", "web/1"))
+	require.NoError(t, s.Delete("components", "missing"))
+
+	docs, err = s.List("components")
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	docs, err = s.List("tasks")
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	assert.NoError(t, s.Close())
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, storage.NewMemoryStorage())
+}
+
+func TestFileStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	// Документы переживают повторное открытие каталога
+	reopened, err := storage.NewFileStorage(dir)
+	require.NoError(t, err)
+	docs, err := reopened.List("components")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"ID":"db","Version":"2"}`), docs["db"])
+
+	// Временные файлы не остаются после записи
+	entries, err := os.ReadDir(filepath.Join(dir, "components"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileStorage_EmptyDir(t *testing.T) {
+	_, err := storage.NewFileStorage("")
+	assert.EqualError(t, err, "storage directory is empty")
+}
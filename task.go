@@ -1,51 +1,125 @@
 package inforo
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/laplasd/inforo/api"
 	"github.com/laplasd/inforo/model"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TaskRegistry struct {
 	tasks              map[string]*model.Task
+	runs               map[string]*taskRun
+	lastRuns           map[string]string // ID выполнения, в котором задача завершилась последний раз
+	paused             map[string]*pauseGate
 	Components         api.ComponentRegistry
 	Controllers        api.ControllerRegistry
 	Monitoring         api.MonitoringRegistry
 	MonitorControllers api.MonitoringControllerRegistry
+	Executions         api.ExecutionRegistry
+	storage            api.Storage
 	*StatusManager
 	*Events
+	bus    api.EventBus
+	tracer trace.Tracer
 	MU     *sync.RWMutex
 	logger *logrus.Logger
 }
 
+// taskRun holds the control handles of a task execution in progress.
+type taskRun struct {
+	executionID string
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
 type TaskRegistryOptions struct {
 	Logger             *logrus.Logger
 	Components         api.ComponentRegistry
 	Controllers        api.ControllerRegistry
 	Monitoring         api.MonitoringRegistry
 	MonitorControllers api.MonitoringControllerRegistry
+	Executions         api.ExecutionRegistry
+	Storage            api.Storage
 	StatusManager      *StatusManager
 	EventManager       *Events
+	Bus                api.EventBus // Получает изменения статусов и событий, если задан
+	// TracerProvider creates the spans of task executions, the global
+	// provider of otel by default
+	TracerProvider trace.TracerProvider
 }
 
 func NewTaskRegistry(opts TaskRegistryOptions) (api.TaskRegistry, error) {
-	return &TaskRegistry{
+	if opts.Executions == nil {
+		opts.Executions, _ = NewExecutionRegistry(ExecutionRegistryOptions{Logger: opts.Logger})
+	}
+	ts := &TaskRegistry{
 		MU:                 &sync.RWMutex{},
 		Components:         opts.Components,
 		Controllers:        opts.Controllers,
 		Monitoring:         opts.Monitoring,
 		MonitorControllers: opts.MonitorControllers,
+		Executions:         opts.Executions,
+		storage:            opts.Storage,
 		logger:             opts.Logger,
 		StatusManager:      opts.StatusManager,
 		Events:             opts.EventManager,
+		bus:                opts.Bus,
+		tracer:             newTracer(opts.TracerProvider),
 		tasks:              make(map[string]*model.Task),
-	}, nil
+		runs:               make(map[string]*taskRun),
+		lastRuns:           make(map[string]string),
+		paused:             make(map[string]*pauseGate),
+	}
+	if err := ts.load(); err != nil {
+		return ts, err
+	}
+	return ts, nil
+}
+
+// load restores the tasks saved in the storage.
+func (ts *TaskRegistry) load() error {
+	return loadDocuments(ts.storage, tasksCollection, func(id string, data []byte) error {
+		task := &model.Task{}
+		if err := json.Unmarshal(data, task); err != nil {
+			return err
+		}
+		if task.StatusHistory == nil {
+			task.StatusHistory = ts.NewStatus(model.StatusCreated)
+		}
+		if task.EventHistory == nil {
+			task.EventHistory = &model.EventHistory{}
+		}
+		ts.tasks[task.ID] = task
+		return nil
+	})
+}
+
+// save persists the task.
+func (ts *TaskRegistry) save(task *model.Task) error {
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	task.EventHistory.MU.RLock()
+	defer task.EventHistory.MU.RUnlock()
+	return saveDocument(ts.storage, tasksCollection, task.ID, task)
+}
+
+// saveState persists the task after a state change, logging failures
+// instead of interrupting the execution.
+func (ts *TaskRegistry) saveState(task *model.Task) {
+	if err := ts.save(task); err != nil {
+		ts.logger.Errorf("TaskRegistry.saveState() - task.ID: %s, error: %v", task.ID, err)
+	}
 }
 
 func (ts *TaskRegistry) Validate(task *model.Task) error {
@@ -57,6 +131,9 @@ func (ts *TaskRegistry) Validate(task *model.Task) error {
 		if task.RollBack.Type == "" {
 			return fmt.Errorf("rollback type not found")
 		}
+		if task.RollBack.Type != model.ManualRollBack && task.RollBack.Type != model.TriggerRollBack {
+			return fmt.Errorf("invalid rollback type '%s'", task.RollBack.Type)
+		}
 		if (task.Components != nil && task.Metadata == nil) || (task.Components == nil && task.Metadata != nil) {
 			return errors.New("both Components and Metadata must be either set or not set together")
 		}
@@ -73,12 +150,30 @@ func (ts *TaskRegistry) Validate(task *model.Task) error {
 			return err
 		}
 	}
+	if task.PostChecks != nil {
+		if err := ts.IsValidCheck(task.PostChecks); err != nil {
+			return err
+		}
+	}
 
 	if !isValidTaskType(task.Type) {
 		return errors.New("invalid task type")
 	}
 
+	if err := validateRetryPolicy(task.Retry); err != nil {
+		return err
+	}
+	if err := validateFailurePolicy(task.FailurePolicy, task.MinSuccessRatio); err != nil {
+		return err
+	}
+	if err := validateRolloutStrategy(task); err != nil {
+		return err
+	}
+
 	for _, depends := range task.DependsOn {
+		if !isValidDepensType(depends.Type) {
+			return fmt.Errorf("invalid dependency type '%s' for '%s'", depends.Type, depends.ID)
+		}
 		if _, exists := ts.tasks[depends.ID]; !exists {
 			return fmt.Errorf("Dependency '%s' not found", depends.ID)
 		}
@@ -99,21 +194,30 @@ func (ts *TaskRegistry) Register(task *model.Task) (*model.Task, error) {
 	}
 
 	fullTask := &model.Task{
-		ID:            task.ID,
-		Name:          task.Name,
-		Type:          task.Type,
-		Components:    task.Components,
-		DependsOn:     task.DependsOn,
-		PreChecks:     task.PreChecks,
-		PostChecks:    task.PostChecks,
-		StatusHistory: ts.NewStatus(model.StatusCreated),
-		EventHistory:  &model.EventHistory{},
-		Metadata:      task.Metadata,
-		RollBack:      task.RollBack,
+		ID:              task.ID,
+		Name:            task.Name,
+		Type:            task.Type,
+		Components:      task.Components,
+		DependsOn:       normalizeDepends(task.DependsOn),
+		PreChecks:       task.PreChecks,
+		PostChecks:      task.PostChecks,
+		Retry:           task.Retry,
+		FailurePolicy:   task.FailurePolicy,
+		MinSuccessRatio: task.MinSuccessRatio,
+		Strategy:        task.Strategy,
+		StatusHistory:   ts.NewStatus(model.StatusCreated),
+		EventHistory:    &model.EventHistory{},
+		Metadata:        task.Metadata,
+		RollBack:        task.RollBack,
 	}
 	ts.AddEvent(fullTask.EventHistory, "Created task!")
 
+	if err := ts.save(fullTask); err != nil {
+		return nil, err
+	}
 	ts.tasks[fullTask.ID] = fullTask
+	publishStatus(ts.bus, model.EntityTask, fullTask.ID, "", fullTask.StatusHistory)
+	publishEvent(ts.bus, model.EntityTask, fullTask.ID, "", "Created task!")
 	return fullTask, nil
 }
 
@@ -154,7 +258,12 @@ func (ts *TaskRegistry) Update(id string, updated *model.Task) error {
 		task.Metadata = updated.Metadata
 	}
 	if updated.DependsOn != nil {
-		task.DependsOn = updated.DependsOn
+		for _, depends := range updated.DependsOn {
+			if !isValidDepensType(depends.Type) {
+				return fmt.Errorf("invalid dependency type '%s' for '%s'", depends.Type, depends.ID)
+			}
+		}
+		task.DependsOn = normalizeDepends(updated.DependsOn)
 	}
 	if updated.PreChecks != nil {
 		task.PreChecks = updated.PreChecks
@@ -162,6 +271,25 @@ func (ts *TaskRegistry) Update(id string, updated *model.Task) error {
 	if updated.PostChecks != nil {
 		task.PostChecks = updated.PostChecks
 	}
+	if updated.FailurePolicy != "" {
+		if err := validateFailurePolicy(updated.FailurePolicy, updated.MinSuccessRatio); err != nil {
+			return err
+		}
+		task.FailurePolicy = updated.FailurePolicy
+		task.MinSuccessRatio = updated.MinSuccessRatio
+	}
+	if updated.Strategy != nil {
+		if err := validateRolloutStrategy(&model.Task{Type: task.Type, Strategy: updated.Strategy}); err != nil {
+			return err
+		}
+		task.Strategy = updated.Strategy
+	}
+	if updated.Retry != nil {
+		if err := validateRetryPolicy(updated.Retry); err != nil {
+			return err
+		}
+		task.Retry = updated.Retry
+	}
 	if updated.StatusHistory != nil {
 		task.StatusHistory = updated.StatusHistory
 	}
@@ -169,7 +297,7 @@ func (ts *TaskRegistry) Update(id string, updated *model.Task) error {
 	// сохраняем обновлённую задачу обратно в мапу (по сути не обязательно, тк task — ссылка)
 	ts.tasks[id] = task
 
-	return nil
+	return ts.save(task)
 }
 
 func (ts *TaskRegistry) Delete(id string) error {
@@ -179,6 +307,9 @@ func (ts *TaskRegistry) Delete(id string) error {
 	if _, exists := ts.tasks[id]; !exists {
 		return errors.New("task not found")
 	}
+	if err := deleteDocument(ts.storage, tasksCollection, id); err != nil {
+		return err
+	}
 	delete(ts.tasks, id)
 	return nil
 }
@@ -210,6 +341,11 @@ func (ts *TaskRegistry) ForkAsync(taskID string, executionID string) (string, er
 		return "", fmt.Errorf("task not found")
 	}
 
+	// Резервируем запись заранее, чтобы executionID сразу был доступен для запросов
+	if err := ts.Executions.Reserve(executionID, model.TaskExecution, "", taskID); err != nil {
+		return "", err
+	}
+
 	go ts.Fork(taskID, executionID)
 	ts.logger.Debugf("[%s] TaskRegistry.ForkAsync() - started forkAsync()", executionID)
 
@@ -217,6 +353,12 @@ func (ts *TaskRegistry) ForkAsync(taskID string, executionID string) (string, er
 }
 
 func (ts *TaskRegistry) Fork(taskID string, executionID string) (string, error) {
+	return ts.ForkContext(context.Background(), taskID, executionID)
+}
+
+// ForkContext runs the task like Fork, aborting dependency resolution, checks
+// and controller calls as soon as ctx is done.
+func (ts *TaskRegistry) ForkContext(ctx context.Context, taskID string, executionID string) (_ string, err error) {
 
 	if executionID == "" {
 		executionID = uuid.New().String()
@@ -224,22 +366,45 @@ func (ts *TaskRegistry) Fork(taskID string, executionID string) (string, error)
 
 	ts.logger.Debugf("[%s] TaskRegistry.Fork() - taskID: %s", executionID, taskID)
 
+	ctx, span := ts.tracer.Start(ctx, "TaskRegistry.Fork", trace.WithAttributes(
+		attrTaskID.String(taskID), attrExecutionID.String(executionID)))
+	defer func() { endSpan(span, err) }()
+
+	// Регистрируем выполнение; завершает его только тот, кто его начал
+	if ts.registerExecution(executionID, model.TaskExecution, taskID) {
+		defer func() { ts.unregisterExecution(ctx, executionID, err) }()
+	}
+
+	// Занимаем задачу до смены статуса, чтобы параллельный запуск его не затёр
+	runCtx, release, err := ts.startRun(ctx, taskID, executionID)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	ctx = runCtx
+
 	// Первым делом сообщаем о статусе запуска
 	task, err := ts.prepareTask(taskID)
 	if err != nil {
 		return "", err
 	}
-	ts.AddEvent(task.EventHistory, "Fork task!")
-	// Регистрируем выполнение в registry (нужно реализовать этот функционал)
-	ts.registerExecution(executionID, taskID)
-	defer ts.unregisterExecution(executionID)
+	span.SetAttributes(attrTaskType.String(string(task.Type)))
+	ts.event(task, "Fork task!")
+	defer ts.saveState(task)
+
+	// Поставленная на паузу задача не стартует до вызова Resume
+	if err = ts.waitPaused(ctx, task); err != nil {
+		ts.failTask(ctx, task, err)
+		return "", err
+	}
 
 	// Обрабатываем зависимости
-	ts.logger.Debugf("[%s] TaskRegistry.Fork() - DependsOn: %c", executionID, len(task.DependsOn))
-	if len(task.DependsOn) != 0 {
-		err = ts.resolveDepens(task.DependsOn, executionID)
+	ts.logger.Debugf("[%s] TaskRegistry.Fork() - DependsOn: %d", executionID, len(task.DependsOn))
+	if len(task.DependsOn) != 0 && !dependsResolved(ctx) {
+		err = ts.resolveDepens(ctx, task, executionID)
 	}
 	if err != nil {
+		ts.failTask(ctx, task, err)
 		return "", err
 	}
 
@@ -247,21 +412,17 @@ func (ts *TaskRegistry) Fork(taskID string, executionID string) (string, error)
 	if err != nil {
 		return "", err
 	}
-	ts.AddEvent(task.EventHistory, "Running task!")
+	ts.event(task, "Running task!")
 
 	if task.PreChecks != nil {
-		err = ts.runChecks(task.PreChecks)
+		err = ts.runChecks(ctx, task, task.PreChecks)
 		if err != nil {
-			ts.UpdateTaskStatus(task, model.StatusFailed)
+			ts.failTask(ctx, task, err)
 			return "", err
 		}
 	}
 
-	type TaskComponent struct {
-		Component  *model.Component
-		Controller api.Controller
-	}
-	components := make([]TaskComponent, 0, len(task.Components))
+	components := make([]taskComponent, 0, len(task.Components))
 	for _, component := range task.Components {
 		component, err := ts.Components.Get(component)
 		if err != nil {
@@ -273,71 +434,318 @@ func (ts *TaskRegistry) Fork(taskID string, executionID string) (string, error)
 			ts.UpdateTaskStatus(task, model.StatusFailed)
 			return "", err
 		}
-		components = append(components, TaskComponent{
+		components = append(components, taskComponent{
 			Component:  component,
-			Controller: controller,
+			Controller: ControllerWithContext(controller),
 		})
 	}
 
-	for _, tc := range components {
-		err = tc.Controller.RunTask(task.Metadata, tc.Component.Metadata)
+	if err = ts.runComponents(ctx, task, components); err != nil {
+		return "", ts.failTaskWithRollBack(ctx, task, executionID, err)
+	}
+	err = ts.UpdateTaskStatus(task, model.StatusSuccess)
+	if err != nil {
+		return "", err
+	}
+	ts.event(task, "Success task!")
+
+	if task.PostChecks != nil {
+		err = ts.runChecks(ctx, task, task.PostChecks)
 		if err != nil {
-			ts.UpdateTaskStatus(task, model.StatusFailed)
-			return "", err
+			return "", ts.failTaskWithRollBack(ctx, task, executionID, err)
 		}
+	}
 
-		err = ts.UpdateTaskStatus(task, model.StatusSuccess)
-		if err != nil {
-			return "", err
+	return executionID, nil
+}
+
+type taskComponent struct {
+	Component  *model.Component
+	Controller api.ContextController
+}
+
+// runComponents runs the task on the components batch by batch, as its
+// rollout strategy says, recording a result per component, and applies the
+// failure policy of the task.
+func (ts *TaskRegistry) runComponents(ctx context.Context, task *model.Task, components []taskComponent) error {
+	task.MU.Lock()
+	task.ComponentResults = make(map[string]*model.ComponentResult, len(components))
+	for _, tc := range components {
+		task.ComponentResults[tc.Component.ID] = &model.ComponentResult{Status: model.StatusPending}
+	}
+	task.MU.Unlock()
+
+	batches := rolloutBatches(task.Strategy, components)
+	canary := task.Strategy != nil && task.Strategy.Type == model.CanaryRollout
+
+	var componentErr error
+	failed := 0
+	for b, batch := range batches {
+		if b > 0 {
+			if err := ts.beforeBatch(ctx, task, b, batches); err != nil {
+				ts.skipComponents(task, batches[b:])
+				return err
+			}
+		}
+
+		var batchErr error
+		for i, err := range ts.runBatch(ctx, task, batch) {
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			failed++
+			if batchErr == nil {
+				batchErr = err
+			}
+			componentErr = errors.Join(componentErr, fmt.Errorf("component %s: %w", batch[i].Component.ID, err))
+		}
+		if batchErr == nil {
+			continue
+		}
+
+		// Упавшая канарейка останавливает раскатку при любой политике
+		if canary && b == 0 && len(batches) > 1 {
+			ts.event(task, "Canary failed, aborting rollout!")
+			ts.skipComponents(task, batches[b+1:])
+			return batchErr
+		}
+		if task.FailurePolicy == model.FailFast || task.FailurePolicy == "" {
+			ts.skipComponents(task, batches[b+1:])
+			return batchErr
 		}
-		ts.AddEvent(task.EventHistory, "Success task!")
 	}
 
-	if task.PostChecks != nil {
-		err = ts.runChecks(task.PostChecks)
+	if failed == 0 {
+		return nil
+	}
+	if task.FailurePolicy == model.MinSuccessRatio {
+		ratio := float64(len(components)-failed) / float64(len(components))
+		if ratio >= task.MinSuccessRatio {
+			ts.event(task, fmt.Sprintf("%d of %d components failed, success ratio %.2f meets %.2f", failed, len(components), ratio, task.MinSuccessRatio))
+			return nil
+		}
+		return fmt.Errorf("success ratio %.2f below %.2f: %w", ratio, task.MinSuccessRatio, componentErr)
+	}
+	return componentErr
+}
+
+// runComponent runs the task on a single component and records the result.
+func (ts *TaskRegistry) runComponent(ctx context.Context, task *model.Task, tc taskComponent) error {
+	// Компоненты батча выполняются параллельно, поэтому повторы отражаются
+	// в результате компонента, а не в статусе задачи
+	componentID := tc.Component.ID
+	err := ts.retry(ctx, task, task.Retry, fmt.Sprintf("component %s", componentID),
+		func() { ts.setComponentResult(task, componentID, model.StatusRetry, nil) },
+		func(attempt int) { ts.setComponentAttempt(task, componentID, attempt) },
+		func() error {
+			return ts.runController(ctx, task, tc.Component, tc.Controller, task.Metadata)
+		})
+	switch {
+	case err == nil:
+		ts.setComponentResult(task, tc.Component.ID, model.StatusSuccess, nil)
+		ts.event(task, fmt.Sprintf("Component %s updated", tc.Component.ID))
+	case ctx.Err() != nil:
+		ts.setComponentResult(task, tc.Component.ID, model.StatusStopped, err)
+	default:
+		ts.setComponentResult(task, tc.Component.ID, model.StatusFailed, err)
+		ts.event(task, fmt.Sprintf("Component %s failed: %v", tc.Component.ID, err))
+	}
+	return err
+}
+
+// runController runs the controller on the component and publishes the call
+// with its duration.
+func (ts *TaskRegistry) runController(ctx context.Context, task *model.Task, component *model.Component, controller api.ContextController, taskMeta map[string]string) error {
+	ctx, span := ts.tracer.Start(ctx, "Controller.RunTask", trace.WithAttributes(
+		attrTaskID.String(task.ID), attrComponentID.String(component.ID), attrControllerType.String(component.Type)))
+	started := time.Now()
+	err := controller.RunTaskContext(ctx, taskMeta, component.Metadata)
+	endSpan(span, err)
+	if ts.bus != nil {
+		event := model.BusEvent{
+			Type:           model.ControllerCalled,
+			Entity:         model.EntityComponent,
+			EntityID:       component.ID,
+			TaskID:         task.ID,
+			Status:         model.StatusSuccess,
+			Timestamp:      started,
+			ControllerType: component.Type,
+			Duration:       model.Duration(time.Since(started)),
+		}
 		if err != nil {
-			ts.UpdateTaskStatus(task, model.StatusFailed)
-			return "", err
+			event.Status = model.StatusFailed
+			event.Message = err.Error()
+		}
+		ts.bus.Publish(event)
+	}
+	return err
+}
+
+func (ts *TaskRegistry) skipComponents(task *model.Task, batches [][]taskComponent) {
+	for _, batch := range batches {
+		for _, tc := range batch {
+			ts.setComponentResult(task, tc.Component.ID, model.StatusSkipped, nil)
 		}
 	}
+}
+
+func (ts *TaskRegistry) setComponentResult(task *model.Task, componentID string, status model.Status, err error) {
+	task.MU.Lock()
+	defer task.MU.Unlock()
+
+	result, exists := task.ComponentResults[componentID]
+	if !exists {
+		result = &model.ComponentResult{}
+		task.ComponentResults[componentID] = result
+	}
+	previous := result.Status
+	result.Status = status
+	switch status {
+	case model.StatusRunning:
+		if result.StartedAt.IsZero() {
+			result.StartedAt = time.Now()
+		}
+	case model.StatusSuccess, model.StatusFailed, model.StatusStopped, model.StatusRollBack:
+		result.FinishedAt = time.Now()
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if ts.bus != nil {
+		ts.bus.Publish(model.BusEvent{
+			Type:           model.StatusChanged,
+			Entity:         model.EntityComponent,
+			EntityID:       componentID,
+			TaskID:         task.ID,
+			Status:         status,
+			PreviousStatus: previous,
+			Message:        result.Error,
+			Timestamp:      time.Now(),
+		})
+	}
+}
 
-	return "", nil
+// setComponentAttempt marks the component running the given attempt of the
+// task.
+func (ts *TaskRegistry) setComponentAttempt(task *model.Task, componentID string, attempt int) {
+	ts.setComponentResult(task, componentID, model.StatusRunning, nil)
+	task.MU.Lock()
+	task.ComponentResults[componentID].Attempts = attempt
+	task.MU.Unlock()
 }
 
-func (ts *TaskRegistry) resolveDepens(DependsOn []model.Depends, executionID string) error {
+// changedComponents returns the components the last execution of the task
+// actually ran on. Without recorded results every component is returned.
+func changedComponents(task *model.Task) []string {
+	task.MU.RLock()
+	defer task.MU.RUnlock()
 
-	for _, depends := range DependsOn {
+	if len(task.ComponentResults) == 0 {
+		return task.Components
+	}
+	changed := make([]string, 0, len(task.Components))
+	for _, componentID := range task.Components {
+		result, exists := task.ComponentResults[componentID]
+		if !exists {
+			continue
+		}
+		// Упавший компонент мог измениться частично — откатываем и его
+		switch result.Status {
+		case model.StatusSuccess, model.StatusFailed, model.StatusStopped, model.StatusRunning:
+			changed = append(changed, componentID)
+		}
+	}
+	return changed
+}
+
+// failTaskWithRollBack fails the task after its components were changed and
+// acts on its rollback: a "trigger" rollback runs right away, a "manual" one
+// leaves the task awaiting an operator's approval (see ApproveRollBack).
+func (ts *TaskRegistry) failTaskWithRollBack(ctx context.Context, task *model.Task, executionID string, err error) error {
+	ts.failTask(ctx, task, err)
+	// Остановленную задачу не откатываем
+	if task.RollBack == nil || ctx.Err() != nil {
+		return err
+	}
+
+	switch task.RollBack.Type {
+	case model.TriggerRollBack:
+		ts.event(task, "Task failed, rolling back task...")
+		if rollbackErr := ts.rollBack(ctx, task, executionID); rollbackErr != nil {
+			return fmt.Errorf("%w, rollback failed: %w", err, rollbackErr)
+		}
+	case model.ManualRollBack:
+		ts.UpdateTaskStatus(task, model.StatusAwaitingApproval)
+		ts.event(task, "Task failed, waiting for rollback approval!")
+		ts.logger.Warnf("[%s] TaskRegistry.Fork() - task %s failed, rollback awaits approval", executionID, task.ID)
+	}
+	return err
+}
+
+// failTask marks the task as failed, or as stopped when the failure was
+// caused by the caller cancelling the context.
+func (ts *TaskRegistry) failTask(ctx context.Context, task *model.Task, err error) {
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		ts.UpdateTaskStatus(task, model.StatusStopped)
+		ts.event(task, fmt.Sprintf("Task stopped: %v", err))
+		return
+	}
+	ts.UpdateTaskStatus(task, model.StatusFailed)
+}
+
+// resolveDepens prepares the dependencies of the task according to their
+// type, see model.DepensType.
+func (ts *TaskRegistry) resolveDepens(ctx context.Context, task *model.Task, executionID string) (err error) {
+	ctx, span := ts.tracer.Start(ctx, "TaskRegistry.resolveDepens", trace.WithAttributes(attrTaskID.String(task.ID)))
+	defer func() { endSpan(span, err) }()
+
+	for _, depends := range task.DependsOn {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		ts.logger.Debugf("[%s] TaskRegistry.Fork() - DependsType: %s, DependsID: %s", executionID, depends.Type, depends.ID)
-		task, err := ts.Get(depends.ID)
+		dependency, err := ts.Get(depends.ID)
 		if err != nil {
-			ts.UpdateTaskStatus(task, model.StatusFailed)
 			return err
 		}
 
 		switch depends.Type {
 
-		case model.Ordered:
-			if task.StatusHistory.LastStatus == model.StatusSuccess {
-				continue
+		case model.Strict, "":
+			if err := ts.runDependency(ctx, dependency, executionID); err != nil {
+				return fmt.Errorf("strict dependency %s failed: %w", dependency.ID, err)
 			}
-			ts.AddEvent(task.EventHistory, "Triggered by DependsOn!")
-			_, err = ts.Fork(task.ID, executionID)
-			if err != nil {
-				ts.UpdateTaskStatus(task, model.StatusFailed)
-				return err
+
+		case model.Ordered:
+			if err := ts.runDependency(ctx, dependency, executionID); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				ts.event(task, fmt.Sprintf("Ordered dependency %s failed: %v", dependency.ID, err))
 			}
-		case model.Blocking:
-			if task.StatusHistory.LastStatus == model.StatusSuccess {
-				continue
-			} else {
 
+		case model.Blocking:
+			if err := ts.waitBlocking(ctx, dependency, executionID); err != nil {
+				return err
 			}
 
 		case model.Advisory:
+			if ts.isRunning(dependency.ID) || taskStatus(dependency) == model.StatusSuccess {
+				continue
+			}
+			ts.event(dependency, "Triggered by DependsOn!")
+			if _, err := ts.ForkContext(ctx, dependency.ID, executionID); err != nil && !errors.Is(err, errTaskRunning) {
+				if ctx.Err() != nil {
+					return err
+				}
+				ts.event(task, fmt.Sprintf("Advisory dependency %s failed: %v", dependency.ID, err))
+			}
 
-		case model.Strict:
-			return fmt.Errorf("strict depens")
-
+		default:
+			return fmt.Errorf("unknown dependency type '%s'", depends.Type)
 		}
 
 	}
@@ -345,26 +753,231 @@ func (ts *TaskRegistry) resolveDepens(DependsOn []model.Depends, executionID str
 	return nil
 }
 
-func (ts *TaskRegistry) runChecks(checks []*model.Check) error {
-	for _, check := range checks {
-		monitoring, err := ts.Monitoring.Get(check.MonitoringID)
+// runDependency makes sure the dependency has run successfully: a running
+// dependency is waited for, one that has not succeeded yet is started.
+func (ts *TaskRegistry) runDependency(ctx context.Context, dependency *model.Task, executionID string) error {
+	for {
+		// Уже выполняющуюся зависимость ждём, а не запускаем повторно
+		waited, err := ts.waitRun(ctx, dependency.ID)
 		if err != nil {
 			return err
 		}
-		controller, err := ts.MonitorControllers.Get(monitoring.Type)
-		if err != nil {
+		status := taskStatus(dependency)
+		if status == model.StatusSuccess {
+			return nil
+		}
+		if waited {
+			return fmt.Errorf("finished with status '%s'", status)
+		}
+
+		ts.event(dependency, "Triggered by DependsOn!")
+		_, err = ts.ForkContext(ctx, dependency.ID, executionID)
+		// Зависимость успел запустить кто-то другой — ждём её
+		if !errors.Is(err, errTaskRunning) {
 			return err
 		}
-		controller.RunCheck(check.Metadata)
 	}
-	return nil
+}
+
+// waitBlocking waits for the run of the dependency in progress to end. A
+// dependency that is not running must already have run in this execution:
+// a status left from an earlier one does not count. In a plan the graph runs
+// or skips the dependency before the task.
+func (ts *TaskRegistry) waitBlocking(ctx context.Context, dependency *model.Task, executionID string) error {
+	ts.MU.RLock()
+	run, active := ts.runs[dependency.ID]
+	lastRun := ts.lastRuns[dependency.ID]
+	ts.MU.RUnlock()
+
+	if active {
+		select {
+		case <-run.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if lastRun == executionID || ts.inPlan(executionID) {
+		return nil
+	}
+	return fmt.Errorf("blocking dependency %s is not running", dependency.ID)
+}
+
+// inPlan reports whether the execution runs a plan.
+func (ts *TaskRegistry) inPlan(executionID string) bool {
+	execution, err := ts.Executions.Get(executionID)
+	if err != nil {
+		return false
+	}
+	return execution.PlanID != ""
+}
+
+func (ts *TaskRegistry) isRunning(taskID string) bool {
+	ts.MU.RLock()
+	defer ts.MU.RUnlock()
+	_, active := ts.runs[taskID]
+	return active
+}
+
+func taskStatus(task *model.Task) model.Status {
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	return task.StatusHistory.LastStatus
+}
+
+// runChecks evaluates the checks one by one and returns the failures of the
+// blocking ones; warning and informational failures are only recorded.
+func (ts *TaskRegistry) runChecks(ctx context.Context, task *model.Task, checks []*model.Check) (blockingErr error) {
+	ctx, span := ts.tracer.Start(ctx, "TaskRegistry.runChecks", trace.WithAttributes(attrTaskID.String(task.ID)))
+	defer func() { endSpan(span, blockingErr) }()
+
+	for _, check := range checks {
+		result, err := ts.evaluateCheck(ctx, task, check)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err == nil {
+			ts.event(task, fmt.Sprintf("Check %s passed", check.ID))
+			continue
+		}
+
+		switch result.Severity {
+		case model.SeverityBlocking:
+			ts.event(task, fmt.Sprintf("Check %s failed: %s", check.ID, result.Output))
+			blockingErr = errors.Join(blockingErr, fmt.Errorf("check %s failed: %w", check.ID, err))
+		case model.SeverityWarning:
+			ts.event(task, fmt.Sprintf("Warning: check %s failed: %s", check.ID, result.Output))
+			ts.logger.Warnf("TaskRegistry.runChecks() - task %s: check %s failed: %v", task.ID, check.ID, err)
+		case model.SeverityInfo:
+			ts.event(task, fmt.Sprintf("Info: check %s failed: %s", check.ID, result.Output))
+		}
+	}
+	return blockingErr
+}
+
+// evaluateCheck runs the check through its monitoring controller and records
+// the result on the check.
+func (ts *TaskRegistry) evaluateCheck(ctx context.Context, task *model.Task, check *model.Check) (*model.CheckResult, error) {
+	started := time.Now()
+	runs := 1
+	var err error
+	if check.Mode == model.CheckSoak {
+		runs, err = ts.soakCheck(ctx, task, check)
+	} else {
+		err = ts.runCheck(ctx, task, check)
+	}
+
+	result := &model.CheckResult{
+		Status:    model.StatusSuccess,
+		Severity:  check.Severity,
+		Runs:      runs,
+		Duration:  time.Since(started),
+		Timestamp: started,
+	}
+	if result.Severity == "" {
+		result.Severity = model.SeverityBlocking
+	}
+	if err != nil {
+		result.Status = model.StatusFailed
+		result.Output = err.Error()
+	}
+
+	check.MU.Lock()
+	check.Result = result
+	if check.StatusHistory == nil {
+		check.StatusHistory = ts.NewStatus(result.Status)
+	} else {
+		check.StatusHistory = ts.NextStatus(result.Status, check.StatusHistory)
+	}
+	history := check.StatusHistory
+	check.MU.Unlock()
+	publishStatus(ts.bus, model.EntityCheck, check.ID, task.ID, history)
+	return result, err
+}
+
+// soakCheck re-runs the check every Interval for Duration. It fails as soon
+// as FailureThreshold runs in a row fail and passes early after
+// SuccessThreshold successful runs in a row.
+func (ts *TaskRegistry) soakCheck(ctx context.Context, task *model.Task, check *model.Check) (int, error) {
+	interval := time.Duration(check.Interval)
+	deadline := time.Now().Add(time.Duration(check.Duration))
+	failureThreshold := check.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = 1
+	}
+	ts.event(task, fmt.Sprintf("Soak check %s: watching for %s every %s", check.ID, time.Duration(check.Duration), interval))
+
+	successes, failures := 0, 0
+	for runs := 1; ; runs++ {
+		err := ts.runCheck(ctx, task, check)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return runs, ctxErr
+		}
+		if err != nil {
+			successes = 0
+			failures++
+			ts.logger.Debugf("TaskRegistry.soakCheck() - task %s: check %s run %d failed: %v", task.ID, check.ID, runs, err)
+			if failures >= failureThreshold {
+				return runs, fmt.Errorf("soak failed after %d runs: %w", runs, err)
+			}
+		} else {
+			failures = 0
+			successes++
+			if check.SuccessThreshold > 0 && successes >= check.SuccessThreshold {
+				ts.event(task, fmt.Sprintf("Soak check %s passed early after %d runs", check.ID, runs))
+				return runs, nil
+			}
+		}
+
+		// Следующий запуск уже не помещается в окно
+		if time.Now().Add(interval).After(deadline) {
+			return runs, nil
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return runs, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (ts *TaskRegistry) runCheck(ctx context.Context, task *model.Task, check *model.Check) error {
+	monitoring, err := ts.Monitoring.Get(check.MonitoringID)
+	if err != nil {
+		return err
+	}
+	controller, err := ts.MonitorControllers.Get(monitoring.Type)
+	if err != nil {
+		return err
+	}
+	checkController := MonitoringControllerWithContext(controller)
+	// Проверки идут по одной, так что задача ждёт повтора в StatusRetry
+	status := taskStatus(task)
+	return ts.retry(ctx, task, check.Retry, fmt.Sprintf("check %s", check.ID),
+		func() { ts.UpdateTaskStatus(task, model.StatusRetry) },
+		func(attempt int) {
+			if attempt > 1 {
+				ts.UpdateTaskStatus(task, status)
+			}
+		},
+		func() error {
+			return checkController.RunCheckContext(ctx, check.Metadata)
+		})
 }
 
 // Дополнительные методы для управления выполнениями
-func (ts *TaskRegistry) registerExecution(executionID string, taskID string) {
+
+// registerExecution records the start of an execution and reports whether
+// this call owns it.
+func (ts *TaskRegistry) registerExecution(executionID string, kind model.ExecutionKind, taskID string) bool {
+	return ts.Executions.Start(executionID, kind, "", taskID)
 }
 
-func (ts *TaskRegistry) unregisterExecution(executionID string) {
+// unregisterExecution records the outcome of an owned execution.
+func (ts *TaskRegistry) unregisterExecution(ctx context.Context, executionID string, err error) {
+	ts.Executions.Finish(executionID, executionStatus(ctx, err), err)
 }
 
 func (ts *TaskRegistry) RollBackAsync(taskID string, executionID string) (string, error) {
@@ -383,11 +996,15 @@ func (ts *TaskRegistry) RollBackAsync(taskID string, executionID string) (string
 		return "", fmt.Errorf("task not found")
 	}
 
+	if err := ts.Executions.Reserve(executionID, model.RollBackExecution, "", taskID); err != nil {
+		return "", err
+	}
+
 	go func() {
 		_, err := ts.RollBack(taskID, executionID)
 		if err != nil {
 			ts.logger.Errorf("[%s] TaskRegistry.RollBackAsync() - RollBack failed: %v", executionID, err)
-			ts.AddEvent(task.EventHistory, err.Error())
+			ts.event(task, err.Error())
 		} else {
 			ts.logger.Debugf("[%s] TaskRegistry.RollBackAsync() - RollBack completed successfully", executionID)
 		}
@@ -398,70 +1015,321 @@ func (ts *TaskRegistry) RollBackAsync(taskID string, executionID string) (string
 }
 
 func (ts *TaskRegistry) RollBack(taskID string, executionID string) (string, error) {
+	return ts.RollBackContext(context.Background(), taskID, executionID)
+}
+
+// RollBackContext rolls the task back like RollBack, aborting the controller
+// calls as soon as ctx is done.
+func (ts *TaskRegistry) RollBackContext(ctx context.Context, taskID string, executionID string) (_ string, err error) {
+	if executionID == "" {
+		executionID = uuid.New().String()
+	}
 	ts.logger.Debugf("[%s] TaskRegistry.RollBack() - taskID: %s", executionID, taskID)
 
+	if ts.registerExecution(executionID, model.RollBackExecution, taskID) {
+		defer func() { ts.unregisterExecution(ctx, executionID, err) }()
+	}
+
 	task, err := ts.Get(taskID)
 	if err != nil {
 		return "", err
 	}
-	ts.AddEvent(task.EventHistory, "Rolling back task...")
+	ts.event(task, "Rolling back task...")
+	defer ts.saveState(task)
+
+	runCtx, release, err := ts.startRun(ctx, taskID, executionID)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	ctx = runCtx
+
+	if err := ts.rollBack(ctx, task, executionID); err != nil {
+		return "", err
+	}
+	return executionID, nil
+}
+
+// ApproveRollBack rolls back a task that failed with a manual rollback and
+// is waiting for an operator's approval.
+func (ts *TaskRegistry) ApproveRollBack(taskID string, executionID string) (string, error) {
+	task, err := ts.Get(taskID)
+	if err != nil {
+		return "", err
+	}
+	if status := taskStatus(task); status != model.StatusAwaitingApproval {
+		return "", fmt.Errorf("task is not awaiting rollback approval, status '%s'", status)
+	}
+	ts.event(task, "Rollback approved!")
+	return ts.RollBack(taskID, executionID)
+}
+
+// rollBack runs the rollback metadata of the task on each of its components.
+func (ts *TaskRegistry) rollBack(ctx context.Context, task *model.Task, executionID string) (err error) {
+	ctx, span := ts.tracer.Start(ctx, "TaskRegistry.rollBack", trace.WithAttributes(
+		attrTaskID.String(task.ID), attrExecutionID.String(executionID)))
+	defer func() { endSpan(span, err) }()
+
 	ts.logger.Debugf("[%s] TaskRegistry.RollBack() - check struct", executionID)
+	if task.RollBack == nil {
+		ts.UpdateTaskStatus(task, model.StatusFailed)
+		return fmt.Errorf("task %s has no rollback", task.ID)
+	}
 
 	ts.logger.Debugf("[%s] TaskRegistry.RollBack() - check Components", executionID)
 
-	for _, componentID := range task.Components {
+	// Откатываем только компоненты, которые задача успела изменить
+	for _, componentID := range changedComponents(task) {
 		ts.logger.Debugf("[%s] TaskRegistry.RollBack() - componentID: %s", executionID, componentID)
 		component, err := ts.Components.Get(componentID)
 		if err != nil {
 			ts.UpdateTaskStatus(task, model.StatusFailed)
-			return "", err
+			return err
 		}
 
 		controller, err := ts.Controllers.Get(component.Type)
 		if err != nil {
 			ts.UpdateTaskStatus(task, model.StatusFailed)
-			return "", err
-		}
-		err = controller.RunTask(task.RollBack.Metadata, component.Metadata)
-		if err != nil {
-			ts.UpdateTaskStatus(task, model.StatusFailed)
-			return "", err
+			return err
 		}
-
-		err = ts.UpdateTaskStatus(task, model.StatusRollBack)
+		err = ts.runController(ctx, task, component, ControllerWithContext(controller), task.RollBack.Metadata)
 		if err != nil {
-			return "", err
+			ts.failTask(ctx, task, err)
+			return err
 		}
-		ts.AddEvent(task.EventHistory, "RollBack task!")
+		ts.setComponentResult(task, componentID, model.StatusRollBack, nil)
+		ts.event(task, fmt.Sprintf("RollBack component %s!", componentID))
+	}
 
+	if err := ts.UpdateTaskStatus(task, model.StatusRollBack); err != nil {
+		return err
 	}
-	return executionID, nil
+	ts.event(task, "RollBack task!")
+	return nil
 }
 
-func (ts *TaskRegistry) Status(taskID string) (string, error) {
-	return "", nil
+// Status returns the current status of the task together with the ID of
+// the execution running it, or an empty string when it is idle.
+func (ts *TaskRegistry) Status(taskID string) (model.Status, string, error) {
+	task, err := ts.Get(taskID)
+	if err != nil {
+		return "", "", err
+	}
+
+	ts.MU.RLock()
+	defer ts.MU.RUnlock()
+
+	var executionID string
+	if run, active := ts.runs[taskID]; active {
+		executionID = run.executionID
+	}
+
+	task.MU.RLock()
+	defer task.MU.RUnlock()
+	return task.StatusHistory.LastStatus, executionID, nil
 }
+
+// Stop cancels the controller calls of the running task.
 func (ts *TaskRegistry) Stop(taskID string) error {
+	if _, err := ts.Get(taskID); err != nil {
+		return err
+	}
+
+	ts.MU.RLock()
+	defer ts.MU.RUnlock()
+
+	run, active := ts.runs[taskID]
+	if !active {
+		return fmt.Errorf("task '%s' is not running", taskID)
+	}
+	run.cancel()
+
+	ts.logger.Infof("[%s] Task '%s' stopped", run.executionID, taskID)
 	return nil
 }
+
+// Pause keeps the task from starting when it is next reached by Fork or by
+// a plan; a task that is already running is not interrupted, but a batched
+// rollout holds before its next batch.
 func (ts *TaskRegistry) Pause(taskID string) error {
+	task, err := ts.Get(taskID)
+	if err != nil {
+		return err
+	}
+
+	ts.MU.Lock()
+	defer ts.MU.Unlock()
+
+	if _, exists := ts.paused[taskID]; exists {
+		return fmt.Errorf("task '%s' is already paused", taskID)
+	}
+	gate := newPauseGate()
+	gate.Pause()
+	ts.paused[taskID] = gate
+
+	ts.event(task, "Task paused!")
+	ts.saveState(task)
+	ts.logger.Infof("Task '%s' paused", taskID)
+	return nil
+}
+
+// Resume lets a paused task start again, releasing executions held by Pause.
+func (ts *TaskRegistry) Resume(taskID string) error {
+	task, err := ts.Get(taskID)
+	if err != nil {
+		return err
+	}
+
+	ts.MU.Lock()
+	defer ts.MU.Unlock()
+
+	gate, exists := ts.paused[taskID]
+	if !exists {
+		return fmt.Errorf("task '%s' is not paused", taskID)
+	}
+	delete(ts.paused, taskID)
+	gate.Resume()
 
+	ts.event(task, "Task resumed!")
+	ts.saveState(task)
+	ts.logger.Infof("Task '%s' resumed", taskID)
 	return nil
 }
 
+// Recover marks tasks left pending or running by a previous process as
+// interrupted and returns their IDs.
+func (ts *TaskRegistry) Recover() ([]string, error) {
+	ts.MU.RLock()
+	tasks := make([]*model.Task, 0, len(ts.tasks))
+	for taskID, task := range ts.tasks {
+		if _, active := ts.runs[taskID]; !active {
+			tasks = append(tasks, task)
+		}
+	}
+	ts.MU.RUnlock()
+
+	interrupted := make([]string, 0)
+	for _, task := range tasks {
+		task.MU.RLock()
+		status := task.StatusHistory.LastStatus
+		task.MU.RUnlock()
+
+		switch status {
+		case model.StatusPending, model.StatusCheck, model.StatusRunning, model.StatusRetry, model.StatusPaused:
+		default:
+			continue
+		}
+
+		ts.event(task, fmt.Sprintf("Task interrupted while %s!", status))
+		ts.UpdateTaskStatus(task, model.StatusInterrupted)
+		ts.logger.Warnf("TaskRegistry.Recover() - task %s interrupted while %s", task.ID, status)
+		interrupted = append(interrupted, task.ID)
+	}
+	sort.Strings(interrupted)
+	return interrupted, nil
+}
+
+// errTaskRunning is returned when the task is started while another
+// execution of it is in progress.
+var errTaskRunning = errors.New("task already running")
+
+// startRun registers the control handles of a task execution. A task runs
+// one execution at a time, so that Stop, Pause and Status reach it. The
+// returned function must be called when the execution ends.
+func (ts *TaskRegistry) startRun(ctx context.Context, taskID string, executionID string) (context.Context, func(), error) {
+	ts.MU.Lock()
+	if run, active := ts.runs[taskID]; active {
+		ts.MU.Unlock()
+		return nil, nil, fmt.Errorf("%w in execution %s", errTaskRunning, run.executionID)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	run := &taskRun{executionID: executionID, cancel: cancel, done: make(chan struct{})}
+	ts.runs[taskID] = run
+	ts.MU.Unlock()
+
+	return ctx, func() {
+		cancel()
+		ts.MU.Lock()
+		defer ts.MU.Unlock()
+		if ts.runs[taskID] == run {
+			delete(ts.runs, taskID)
+		}
+		ts.lastRuns[taskID] = executionID
+		close(run.done)
+	}, nil
+}
+
+// waitRun waits for the running execution of the task, if any, and reports
+// whether there was one.
+func (ts *TaskRegistry) waitRun(ctx context.Context, taskID string) (bool, error) {
+	ts.MU.RLock()
+	run, active := ts.runs[taskID]
+	ts.MU.RUnlock()
+	if !active {
+		return false, nil
+	}
+
+	select {
+	case <-run.done:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+// waitPaused holds the execution while the task is paused.
+func (ts *TaskRegistry) waitPaused(ctx context.Context, task *model.Task) error {
+	ts.MU.RLock()
+	gate, paused := ts.paused[task.ID]
+	ts.MU.RUnlock()
+	if !paused || !gate.Paused() {
+		return nil
+	}
+
+	ts.UpdateTaskStatus(task, model.StatusPaused)
+	ts.event(task, "Waiting for resume!")
+	if err := gate.Wait(ctx); err != nil {
+		return err
+	}
+	return ts.UpdateTaskStatus(task, model.StatusPending)
+}
+
 func (ts *TaskRegistry) IsValidCheck(checks []*model.Check) error {
+	for _, check := range checks {
+		switch check.Severity {
+		case model.SeverityBlocking, model.SeverityWarning, model.SeverityInfo, "":
+		default:
+			return fmt.Errorf("check %s: invalid severity '%s'", check.ID, check.Severity)
+		}
+		if err := validateRetryPolicy(check.Retry); err != nil {
+			return fmt.Errorf("check %s: %w", check.ID, err)
+		}
+		switch check.Mode {
+		case model.CheckOnce, "":
+		case model.CheckSoak:
+			if check.Interval <= 0 || check.Duration < check.Interval {
+				return fmt.Errorf("check %s: soak needs a positive interval not longer than its duration", check.ID)
+			}
+		default:
+			return fmt.Errorf("check %s: invalid mode '%s'", check.ID, check.Mode)
+		}
+		if check.SuccessThreshold < 0 || check.FailureThreshold < 0 {
+			return fmt.Errorf("check %s: thresholds must not be negative", check.ID)
+		}
+	}
 	return nil
 }
 
 func (ts *TaskRegistry) UpdateTaskStatus(task *model.Task, status model.Status) error {
 	task.MU.Lock()
-	defer task.MU.Unlock()
+	// Задача хранится в мапе по ссылке — достаточно заменить историю статусов
+	task.StatusHistory = ts.NextStatus(status, task.StatusHistory)
+	history := task.StatusHistory
+	task.MU.Unlock()
+	publishStatus(ts.bus, model.EntityTask, task.ID, "", history)
 
-	// Создаем копию задачи для изменения
-	updatedTask := task
-	updatedTask.StatusHistory = ts.NextStatus(status, task.StatusHistory)
-	ts.tasks[task.ID] = updatedTask
 	ts.logger.Debugf("TaskRegistry.updateTaskStatus() - task.ID: %s, last_status -> %s", task.ID, status)
+	ts.saveState(task)
 	return nil
 }
 
@@ -478,6 +1346,12 @@ func (ts *TaskRegistry) prepareTask(taskID string) (*model.Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	ts.AddEvent(task.EventHistory, "Checking task!")
+	ts.event(task, "Checking task!")
 	return task, nil
 }
+
+// event adds an entry to the event history of the task and publishes it.
+func (ts *TaskRegistry) event(task *model.Task, message string) {
+	ts.AddEvent(task.EventHistory, message)
+	publishEvent(ts.bus, model.EntityTask, task.ID, "", message)
+}
@@ -1,13 +1,17 @@
 package inforo_test
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/laplasd/inforo"
 	"github.com/laplasd/inforo/model"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupCoreWithComponent() *inforo.Core {
@@ -21,7 +25,7 @@ func setupCoreWithComponent() *inforo.Core {
 		logger.Errorln(err)
 	}
 	// Зарегистрируем один компонент
-	_, err = c.Components.Register(model.Component{
+	_, err = c.Components.Register(&model.Component{
 		ID:      "component-1",
 		Name:    "Test Component",
 		Type:    "mock",
@@ -186,3 +190,169 @@ func TestListTasks(t *testing.T) {
 
 	assert.Len(t, list, 2)
 }
+
+func setupSteppingTask(t *testing.T) (*inforo.Core, *steppingController) {
+	c, ctl, _ := setupSteppingPlan(t)
+	_, err := c.Tasks.Register(&model.Task{ID: "single", Type: model.UpdateTask, Components: []string{"first"}})
+	require.NoError(t, err)
+	return c, ctl
+}
+
+func TestTaskStatus_ReportsActiveExecution(t *testing.T) {
+	c, ctl := setupSteppingTask(t)
+
+	status, executionID, err := c.Tasks.Status("single")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusCreated, status)
+	assert.Empty(t, executionID)
+
+	started, err := c.Tasks.ForkAsync("single", "exec-1")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	status, executionID, err = c.Tasks.Status("single")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRunning, status)
+	assert.Equal(t, started, executionID)
+
+	ctl.release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		status, executionID, _ := c.Tasks.Status("single")
+		return status == model.StatusSuccess && executionID == ""
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestTaskStop(t *testing.T) {
+	c, ctl := setupSteppingTask(t)
+	defer close(ctl.release)
+
+	assert.EqualError(t, c.Tasks.Stop("single"), "task 'single' is not running")
+
+	_, err := c.Tasks.ForkAsync("single", "exec-1")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	require.NoError(t, c.Tasks.Stop("single"))
+
+	execution, err := c.Executions.Wait(context.Background(), "exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusStopped, execution.Status)
+
+	status, _, _ := c.Tasks.Status("single")
+	assert.Equal(t, model.StatusStopped, status)
+}
+
+func TestTaskFork_RejectsConcurrentRun(t *testing.T) {
+	c, ctl := setupSteppingTask(t)
+	defer close(ctl.release)
+
+	_, err := c.Tasks.ForkAsync("single", "exec-1")
+	require.NoError(t, err)
+	waitStarted(t, ctl, "first")
+
+	// Второй запуск не перехватывает управление первым
+	_, err = c.Tasks.Fork("single", "exec-2")
+	assert.EqualError(t, err, "task already running in execution exec-1")
+	status, executionID, err := c.Tasks.Status("single")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRunning, status)
+	assert.Equal(t, "exec-1", executionID)
+
+	require.NoError(t, c.Tasks.Stop("single"))
+	execution, err := c.Executions.Wait(context.Background(), "exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusStopped, execution.Status)
+	assert.Equal(t, 1, ctl.Calls("first"))
+}
+
+func TestTaskPause_HoldsUntilResume(t *testing.T) {
+	c, ctl := setupSteppingTask(t)
+
+	require.NoError(t, c.Tasks.Pause("single"))
+	assert.EqualError(t, c.Tasks.Pause("single"), "task 'single' is already paused")
+
+	_, err := c.Tasks.ForkAsync("single", "exec-1")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		status, _, _ := c.Tasks.Status("single")
+		return status == model.StatusPaused
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0, ctl.Calls("first"))
+
+	require.NoError(t, c.Tasks.Resume("single"))
+	waitStarted(t, ctl, "first")
+	ctl.release <- struct{}{}
+
+	execution, err := c.Executions.Wait(context.Background(), "exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusSuccess, execution.Status)
+
+	assert.EqualError(t, c.Tasks.Resume("single"), "task 'single' is not paused")
+}
+
+// --- image controller: fails to deploy app:2.0.0, anything else succeeds ---
+type imageController struct {
+	mockController
+}
+
+func (i *imageController) RunTask(r map[string]string, p map[string]string) error {
+	if r["image"] == "app:2.0.0" {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+
+func registerRollBackTask(t *testing.T, rollbackType model.RollBackType) (*inforo.Core, *model.Task) {
+	c := inforo.NewDefaultCore()
+	require.NoError(t, c.Controllers.Register("image", &imageController{}))
+	_, err := c.Components.Register(&model.Component{ID: "component-1", Type: "image", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	task, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Metadata: map[string]string{"image": "app:2.0.0"},
+		RollBack: &model.Rollback{Type: rollbackType, Metadata: map[string]string{"image": "app:1.0.0"}}})
+	require.NoError(t, err)
+	return c, task
+}
+
+func TestTaskRollBack_Trigger(t *testing.T) {
+	c, task := registerRollBackTask(t, model.TriggerRollBack)
+
+	_, err := c.Tasks.Fork("task-1", "")
+	assert.EqualError(t, err, "deploy failed")
+	assert.Equal(t, model.StatusRollBack, task.StatusHistory.LastStatus)
+	assert.Equal(t, 1, eventsContaining(task, "Task failed, rolling back task..."))
+}
+
+func TestTaskRollBack_ManualAwaitsApproval(t *testing.T) {
+	c, task := registerRollBackTask(t, model.ManualRollBack)
+
+	_, err := c.Tasks.ApproveRollBack("task-1", "")
+	assert.EqualError(t, err, "task is not awaiting rollback approval, status 'created'")
+
+	_, err = c.Tasks.Fork("task-1", "")
+	assert.EqualError(t, err, "deploy failed")
+	assert.Equal(t, model.StatusAwaitingApproval, task.StatusHistory.LastStatus)
+
+	_, err = c.Tasks.ApproveRollBack("task-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRollBack, task.StatusHistory.LastStatus)
+	assert.Equal(t, 1, eventsContaining(task, "Rollback approved!"))
+}
+
+func TestTaskRollBack_InvalidType(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"},
+		Metadata: map[string]string{}, RollBack: &model.Rollback{Type: "auto"}})
+	assert.EqualError(t, err, "invalid rollback type 'auto'")
+}
+
+func TestTaskRollBack_WithoutRollBack(t *testing.T) {
+	c := setupCoreWithComponent()
+	_, err := c.Tasks.Register(&model.Task{ID: "task-1", Type: model.UpdateTask, Components: []string{"component-1"}})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.RollBack("task-1", "")
+	assert.EqualError(t, err, "task task-1 has no rollback")
+}
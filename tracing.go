@@ -0,0 +1,40 @@
+package inforo
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName — инструментирующая библиотека в спанах
+const tracerName = "github.com/laplasd/inforo"
+
+// Атрибуты спанов
+const (
+	attrPlanID         = attribute.Key("inforo.plan.id")
+	attrGraphID        = attribute.Key("inforo.graph.id")
+	attrTaskID         = attribute.Key("inforo.task.id")
+	attrTaskType       = attribute.Key("inforo.task.type")
+	attrComponentID    = attribute.Key("inforo.component.id")
+	attrControllerType = attribute.Key("inforo.controller.type")
+	attrExecutionID    = attribute.Key("inforo.execution.id")
+)
+
+// newTracer returns the tracer of the registries; the global provider of
+// otel is used when tp is nil.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,139 @@
+package inforo_test
+
+import (
+	"testing"
+
+	"github.com/laplasd/inforo"
+	"github.com/laplasd/inforo/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// setupTracedCore returns a core with a "mock" component-1 and an "image"
+// component-2 whose spans go to the returned exporter.
+func setupTracedCore(t *testing.T) (*inforo.Core, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	c := inforo.NewCore(inforo.CoreOptions{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))})
+	require.NoError(t, c.Controllers.Register("mock", &mockController{}))
+	require.NoError(t, c.Controllers.Register("image", &imageController{}))
+	_, err := c.Components.Register(&model.Component{ID: "component-1", Type: "mock", Version: "1.0.0"})
+	require.NoError(t, err)
+	_, err = c.Components.Register(&model.Component{ID: "component-2", Type: "image", Version: "1.0.0"})
+	require.NoError(t, err)
+	return c, exporter
+}
+
+// spansNamed returns the ended spans with the name.
+func spansNamed(exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStubs {
+	spans := make(tracetest.SpanStubs, 0)
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func attributeOf(span tracetest.SpanStub, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == attribute.Key(key) {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing_PlanSpans(t *testing.T) {
+	c, exporter := setupTracedCore(t)
+	plan, err := c.Plans.Register([]*model.Task{
+		{ID: "migrate", Type: model.UpdateTask, Components: []string{"component-1"}},
+		{ID: "deploy", Type: model.UpdateTask, Components: []string{"component-2"},
+			Metadata:  map[string]string{"image": "app:2.0.0"},
+			DependsOn: []model.Depends{{ID: "migrate", Type: model.Strict}}},
+	})
+	require.NoError(t, err)
+	_, err = c.Plans.Run(plan.ID, "")
+	require.Error(t, err)
+
+	runs := spansNamed(exporter, "PlanRegistry.Run")
+	require.Len(t, runs, 1)
+	run := runs[0]
+	assert.Equal(t, plan.ID, attributeOf(run, "inforo.plan.id"))
+	assert.Equal(t, codes.Error, run.Status.Code)
+	assert.False(t, run.Parent.IsValid())
+
+	graphs := spansNamed(exporter, "PlanRegistry.executeTaskGraph")
+	require.Len(t, graphs, 1)
+	graph := graphs[0]
+	assert.Equal(t, run.SpanContext.SpanID(), graph.Parent.SpanID())
+
+	forks := spansNamed(exporter, "TaskRegistry.Fork")
+	require.Len(t, forks, 2)
+	for _, fork := range forks {
+		assert.Equal(t, graph.SpanContext.SpanID(), fork.Parent.SpanID())
+		assert.Equal(t, run.SpanContext.TraceID(), fork.SpanContext.TraceID())
+	}
+
+	calls := spansNamed(exporter, "Controller.RunTask")
+	require.Len(t, calls, 2)
+	for _, call := range calls {
+		switch attributeOf(call, "inforo.task.id") {
+		case "migrate":
+			assert.Equal(t, "component-1", attributeOf(call, "inforo.component.id"))
+			assert.Equal(t, "mock", attributeOf(call, "inforo.controller.type"))
+			assert.Equal(t, codes.Unset, call.Status.Code)
+		case "deploy":
+			assert.Equal(t, "component-2", attributeOf(call, "inforo.component.id"))
+			assert.Equal(t, "image", attributeOf(call, "inforo.controller.type"))
+			assert.Equal(t, codes.Error, call.Status.Code)
+			assert.Equal(t, "deploy failed", call.Status.Description)
+		default:
+			t.Fatalf("unexpected controller call of task %q", attributeOf(call, "inforo.task.id"))
+		}
+	}
+
+	rollbacks := spansNamed(exporter, "PlanRegistry.rollbackGraph")
+	require.Len(t, rollbacks, 1)
+	assert.Equal(t, graph.SpanContext.SpanID(), rollbacks[0].Parent.SpanID())
+	assert.Equal(t, "migrate", attributeOf(rollbacks[0], "inforo.graph.id"))
+}
+
+func TestTracing_TaskDependenciesAndChecks(t *testing.T) {
+	c, exporter := setupTracedCore(t)
+	require.NoError(t, c.MonitorControllers.Register("scripted", &scriptedMonitoringController{calls: make(map[string]int)}))
+	_, err := c.Monitorings.Register("scripted", &model.Monitoring{ID: "prometheus", Type: "scripted"})
+	require.NoError(t, err)
+
+	_, err = c.Tasks.Register(&model.Task{ID: "migrate", Type: model.UpdateTask, Components: []string{"component-1"}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Register(&model.Task{ID: "deploy", Type: model.UpdateTask, Components: []string{"component-1"},
+		DependsOn: []model.Depends{{ID: "migrate", Type: model.Strict}},
+		PreChecks: []*model.Check{newCheck("ready", model.SeverityBlocking, "")}})
+	require.NoError(t, err)
+	_, err = c.Tasks.Fork("deploy", "")
+	require.NoError(t, err)
+
+	forks := make(map[string]tracetest.SpanStub)
+	for _, fork := range spansNamed(exporter, "TaskRegistry.Fork") {
+		forks[attributeOf(fork, "inforo.task.id")] = fork
+	}
+	require.Len(t, forks, 2)
+	deploy := forks["deploy"]
+	assert.Equal(t, "update", attributeOf(deploy, "inforo.task.type"))
+
+	depends := spansNamed(exporter, "TaskRegistry.resolveDepens")
+	require.Len(t, depends, 1)
+	assert.Equal(t, deploy.SpanContext.SpanID(), depends[0].Parent.SpanID())
+	// Зависимость выполняется внутри разрешения зависимостей
+	assert.Equal(t, depends[0].SpanContext.SpanID(), forks["migrate"].Parent.SpanID())
+
+	checks := spansNamed(exporter, "TaskRegistry.runChecks")
+	require.Len(t, checks, 1)
+	assert.Equal(t, deploy.SpanContext.SpanID(), checks[0].Parent.SpanID())
+	assert.Equal(t, "deploy", attributeOf(checks[0], "inforo.task.id"))
+}